@@ -175,6 +175,22 @@ func (wallet *Wallet) AccountNumber(accountName string) (uint32, error) {
 	return wallet.internal.AccountNumber(wallet.shutdownContext(), accountName)
 }
 
+// AccountExtendedPubKey returns the extended public key of the account
+// `accountNumber`, which can be used to create a watch-only wallet for the
+// account.
+func (wallet *Wallet) AccountExtendedPubKey(accountNumber int32) (string, error) {
+	if accountNumber < 0 {
+		return "", errors.New(ErrInvalid)
+	}
+
+	extendedPubKey, err := wallet.internal.MasterPubKey(wallet.shutdownContext(), uint32(accountNumber))
+	if err != nil {
+		return "", translateError(err)
+	}
+
+	return extendedPubKey.String(), nil
+}
+
 func (wallet *Wallet) HDPathForAccount(accountNumber int32) (string, error) {
 	cointype, err := wallet.internal.CoinType(wallet.shutdownContext())
 	if err != nil {
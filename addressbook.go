@@ -0,0 +1,238 @@
+package dcrlibwallet
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+// validateAddressBookEntry trims the name and note of `entry` and checks
+// that it has a name and an address of the network of this MultiWallet.
+// The wallets db is separate for each network, so this also ensures that
+// entries are only saved to the address book of their network.
+func (mw *MultiWallet) validateAddressBookEntry(entry *AddressBookEntry) error {
+	entry.Name = strings.TrimSpace(entry.Name)
+	entry.Address = strings.TrimSpace(entry.Address)
+	entry.Note = strings.TrimSpace(entry.Note)
+
+	if entry.Name == "" {
+		return errors.New(ErrInvalid)
+	}
+
+	if _, err := dcrutil.DecodeAddress(entry.Address, mw.chainParams); err != nil {
+		return errors.New(ErrInvalidAddress)
+	}
+
+	return nil
+}
+
+// AddAddressBookEntry saves a new address book entry and returns its id.
+func (mw *MultiWallet) AddAddressBookEntry(name, address, note string) (int, error) {
+	entry := &AddressBookEntry{
+		Name:    name,
+		Address: address,
+		Note:    note,
+	}
+
+	if err := mw.validateAddressBookEntry(entry); err != nil {
+		return 0, err
+	}
+
+	err := mw.db.Save(entry)
+	if err == storm.ErrAlreadyExists {
+		return 0, errors.New(ErrExist)
+	} else if err != nil {
+		return 0, err
+	}
+
+	return entry.ID, nil
+}
+
+// UpdateAddressBookEntry changes the name, address and note of an address
+// book entry.
+func (mw *MultiWallet) UpdateAddressBookEntry(id int, name, address, note string) error {
+	entry, err := mw.AddressBookEntry(id)
+	if err != nil {
+		return err
+	}
+
+	entry.Name = name
+	entry.Address = address
+	entry.Note = note
+	if err = mw.validateAddressBookEntry(entry); err != nil {
+		return err
+	}
+
+	err = mw.db.Save(entry)
+	if err == storm.ErrAlreadyExists {
+		return errors.New(ErrExist)
+	}
+	return err
+}
+
+func (mw *MultiWallet) DeleteAddressBookEntry(id int) error {
+	entry, err := mw.AddressBookEntry(id)
+	if err != nil {
+		return err
+	}
+
+	return mw.db.DeleteStruct(entry)
+}
+
+func (mw *MultiWallet) AddressBookEntry(id int) (*AddressBookEntry, error) {
+	entry := &AddressBookEntry{}
+	err := mw.db.One("ID", id, entry)
+	if err == storm.ErrNotFound {
+		return nil, errors.New(ErrNotExist)
+	} else if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// AddressBookEntryForAddress returns the address book entry for `address` or
+// an ErrNotExist error if the address is not in the address book.
+func (mw *MultiWallet) AddressBookEntryForAddress(address string) (*AddressBookEntry, error) {
+	entry := &AddressBookEntry{}
+	err := mw.db.One("Address", address, entry)
+	if err == storm.ErrNotFound {
+		return nil, errors.New(ErrNotExist)
+	} else if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ContactNameForAddress returns the name of the address book entry for
+// `address` or an empty string if the address is not in the address book.
+func (mw *MultiWallet) ContactNameForAddress(address string) string {
+	entry, err := mw.AddressBookEntryForAddress(address)
+	if err != nil {
+		return ""
+	}
+	return entry.Name
+}
+
+// ContactsForTransaction returns the address book entries for the addresses
+// paid to by the outputs of `tx`.
+func (mw *MultiWallet) ContactsForTransaction(tx *Transaction) ([]*AddressBookEntry, error) {
+	addresses := make([]string, 0, len(tx.Outputs))
+	for _, output := range tx.Outputs {
+		if output.Address != "" {
+			addresses = append(addresses, output.Address)
+		}
+	}
+
+	var entries []*AddressBookEntry
+	if len(addresses) == 0 {
+		return entries, nil
+	}
+
+	err := mw.db.Select(q.In("Address", addresses)).Find(&entries)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// markAddressBookEntriesUsed sets the last used time of the address book
+// entries for `addresses` to the current time.
+func (mw *MultiWallet) markAddressBookEntriesUsed(addresses []string) {
+	now := time.Now().Unix()
+	for _, address := range addresses {
+		entry, err := mw.AddressBookEntryForAddress(address)
+		if err != nil {
+			continue
+		}
+
+		entry.LastUsed = now
+		if err = mw.db.Save(entry); err != nil {
+			log.Errorf("Error updating address book entry %d: %v", entry.ID, err)
+		}
+	}
+}
+
+// AddressBookEntriesRaw returns all address book entries, most recently
+// used first.
+func (mw *MultiWallet) AddressBookEntriesRaw() ([]*AddressBookEntry, error) {
+	var entries []*AddressBookEntry
+	err := mw.db.Select(q.True()).OrderBy("LastUsed", "Name").Reverse().Find(&entries)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []*AddressBookEntry{}
+	}
+	return entries, nil
+}
+
+func (mw *MultiWallet) AddressBookEntries() (string, error) {
+	entries, err := mw.AddressBookEntriesRaw()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// ExportAddressBook returns all address book entries as json, for backup
+// with ImportAddressBook.
+func (mw *MultiWallet) ExportAddressBook() (string, error) {
+	return mw.AddressBookEntries()
+}
+
+// ImportAddressBook saves the address book entries in `entriesJSON`. Entries
+// for addresses already in the address book replace the name and note of the
+// existing entries. No entry is saved if any entry is invalid. Returns the
+// number of entries imported.
+func (mw *MultiWallet) ImportAddressBook(entriesJSON string) (int, error) {
+	var entries []*AddressBookEntry
+	if err := json.Unmarshal([]byte(entriesJSON), &entries); err != nil {
+		return 0, errors.New(ErrInvalid)
+	}
+
+	for _, entry := range entries {
+		if err := mw.validateAddressBookEntry(entry); err != nil {
+			return 0, err
+		}
+	}
+
+	dbTx, err := mw.db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer dbTx.Rollback()
+
+	for _, entry := range entries {
+		existing := &AddressBookEntry{}
+		err = dbTx.One("Address", entry.Address, existing)
+		if err == nil {
+			entry.ID = existing.ID
+			if entry.LastUsed < existing.LastUsed {
+				entry.LastUsed = existing.LastUsed
+			}
+		} else if err == storm.ErrNotFound {
+			entry.ID = 0
+		} else {
+			return 0, err
+		}
+
+		if err = dbTx.Save(entry); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = dbTx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
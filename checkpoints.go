@@ -0,0 +1,40 @@
+package dcrlibwallet
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/raedahgroup/dcrlibwallet/spv"
+)
+
+// SetHeaderCheckpoints sets the header checkpoints used by SPV sync to
+// validate headers and to import the headers through the last checkpoint in
+// parallel from multiple peers on the first sync. `data` is a checkpoints
+// file for the wallets' network, as generated by spv/cmd/gencheckpoints, and
+// `pubKeyHex` is the hex encoded ed25519 public key the file is signed with.
+// The checkpoints bundled with the spv package for the network are used if
+// this is not called, apps only need to call this before SpvSync to use
+// more recent checkpoints. The checkpoints are used from the next time sync
+// is started.
+func (mw *MultiWallet) SetHeaderCheckpoints(data []byte, pubKeyHex string) error {
+	pubKey, err := hex.DecodeString(pubKeyHex)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return errors.New(ErrInvalid)
+	}
+
+	checkpoints, err := spv.ParseCheckpoints(data, pubKey, mw.chainParams.Name)
+	if err != nil {
+		log.Errorf("Invalid header checkpoints: %v", err)
+		return errors.New(ErrInvalid)
+	}
+
+	mw.syncData.mu.Lock()
+	mw.syncData.checkpoints = checkpoints
+	mw.syncData.mu.Unlock()
+
+	if lastCheckpoint := checkpoints.LastCheckpoint(); lastCheckpoint != nil {
+		log.Infof("Loaded %d header checkpoints through height %d", len(checkpoints.Checkpoints), lastCheckpoint.Height)
+	}
+	return nil
+}
@@ -30,6 +30,13 @@ const (
 	ErrLoggerAlreadyRegistered      = "logger_already_registered"
 	ErrLogRotatorAlreadyInitialized = "log_rotator_already_initialized"
 	ErrAddressDiscoveryNotDone      = "address_discovery_not_done"
+	ErrWalletLocked                 = "wallet_locked"
+	ErrTicketBuyerRunning           = "ticket_buyer_running"
+	ErrFeeRateTooLow                = "fee_rate_too_low"
+	ErrFeeRateTooHigh               = "fee_rate_too_high"
+	ErrProxyPeersRequired           = "proxy_peers_required"
+	ErrVSPPubKeyRequired            = "vsp_pubkey_required"
+	ErrVSPPubKeyMismatch            = "vsp_pubkey_mismatch"
 )
 
 // todo, should update this method to translate more error kinds.
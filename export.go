@@ -0,0 +1,517 @@
+package dcrlibwallet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+	ExportFormatOFX  = "ofx"
+
+	// exportPageSize is the number of transactions read from the tx index at
+	// a time while exporting.
+	exportPageSize = 500
+
+	// Categories of exported transactions. Vote and revocation transactions
+	// are categorized separately so that staking income can be reported.
+	ExportCategorySent           = "sent"
+	ExportCategoryReceived       = "received"
+	ExportCategoryTransferred    = "transferred"
+	ExportCategoryCoinBase       = "coinbase"
+	ExportCategoryTicketPurchase = "ticket_purchase"
+	ExportCategoryVoteReward     = "vote_reward"
+	ExportCategoryRevocation     = "revocation"
+)
+
+// FiatRateSource provides historical exchange rates used to add fiat values
+// to exported transactions.
+type FiatRateSource interface {
+	// Currency returns the code of the fiat currency, e.g. USD.
+	Currency() string
+
+	// RateAt returns the value of 1 DCR in the fiat currency at `timestamp`.
+	RateAt(timestamp int64) (float64, error)
+}
+
+// exportRow is a transaction as written to an export file. Amounts are in
+// DCR.
+type exportRow struct {
+	WalletID      int     `json:"walletID"`
+	Hash          string  `json:"hash"`
+	Timestamp     int64   `json:"timestamp"`
+	Date          string  `json:"date"`
+	BlockHeight   int32   `json:"block_height"`
+	Confirmations int32   `json:"confirmations"`
+	Type          string  `json:"type"`
+	Direction     string  `json:"direction"`
+	Category      string  `json:"category"`
+	Amount        float64 `json:"amount"`
+	Fee           float64 `json:"fee"`
+	StakingReward float64 `json:"staking_reward"`
+	Accounts      string  `json:"accounts"`
+	Label         string  `json:"label"`
+	Note          string  `json:"note"`
+
+	FiatCurrency string   `json:"fiat_currency,omitempty"`
+	FiatRate     *float64 `json:"fiat_rate,omitempty"`
+	FiatValue    *float64 `json:"fiat_value,omitempty"`
+}
+
+// SetFiatRateSource sets the source of the fiat values added to transactions
+// exported by this wallet. Pass nil to export transactions without fiat
+// values.
+func (wallet *Wallet) SetFiatRateSource(rateSource FiatRateSource) {
+	wallet.fiatRateSource = rateSource
+}
+
+// SetFiatRateSource sets the source of the fiat values added to transactions
+// exported by MultiWallet.ExportTransactions. Pass nil to export transactions
+// without fiat values.
+func (mw *MultiWallet) SetFiatRateSource(rateSource FiatRateSource) {
+	mw.fiatRateSource = rateSource
+}
+
+// ExportTransactions writes the transactions of this wallet that match
+// `query` to `writer` in the specified format, oldest first unless
+// `query.NewestFirst` is set. All transactions are exported if `query` is
+// nil. Transactions are read from the tx index and written a page at a time
+// so the whole history is never held in memory.
+func (wallet *Wallet) ExportTransactions(writer io.Writer, format string, query *TxQuery) error {
+	bestBlock := wallet.GetBestBlock()
+	exporter, err := newTxExporter(writer, format, wallet.fiatRateSource, query)
+	if err != nil {
+		return err
+	}
+
+	err = exportQueryPages(exporter, query, func(query *TxQuery) (*TxQueryResult, error) {
+		return wallet.QueryTransactions(query)
+	}, func(walletID int) int32 {
+		return bestBlock
+	})
+	if err != nil {
+		return err
+	}
+
+	return exporter.finish()
+}
+
+// ExportTransactions writes the transactions of all opened wallets, or of
+// the wallets in `query.WalletIDs` if set, that match `query` to `writer` in
+// the specified format. See Wallet.ExportTransactions.
+func (mw *MultiWallet) ExportTransactions(writer io.Writer, format string, query *TxQuery) error {
+	bestBlocks := make(map[int]int32)
+	for _, wallet := range mw.wallets {
+		if wallet.WalletOpened() {
+			bestBlocks[wallet.ID] = wallet.GetBestBlock()
+		}
+	}
+
+	exporter, err := newTxExporter(writer, format, mw.fiatRateSource, query)
+	if err != nil {
+		return err
+	}
+
+	err = exportQueryPages(exporter, query, mw.QueryTransactions, func(walletID int) int32 {
+		return bestBlocks[walletID]
+	})
+	if err != nil {
+		return err
+	}
+
+	return exporter.finish()
+}
+
+// exportQueryPages writes every page of transactions returned by
+// `queryPage` for `query` using `exporter`.
+func exportQueryPages(exporter *txExporter, query *TxQuery, queryPage func(*TxQuery) (*TxQueryResult, error),
+	bestBlock func(walletID int) int32) error {
+
+	// copy the query so that the caller's cursor and limit are not changed.
+	pageQuery := &TxQuery{}
+	if query != nil {
+		*pageQuery = *query
+	}
+	pageQuery.Limit = exportPageSize
+
+	for {
+		result, err := queryPage(pageQuery)
+		if err != nil {
+			return err
+		}
+
+		for i := range result.Transactions {
+			tx := &result.Transactions[i]
+			if err = exporter.write(tx, bestBlock(tx.WalletID)); err != nil {
+				return err
+			}
+		}
+
+		if result.NextCursor == "" {
+			return nil
+		}
+		pageQuery.Cursor = result.NextCursor
+	}
+}
+
+type txExporter struct {
+	format     string
+	writer     io.Writer
+	csvWriter  *csv.Writer
+	rateSource FiatRateSource
+	rowCount   int
+}
+
+func newTxExporter(writer io.Writer, format string, rateSource FiatRateSource, query *TxQuery) (*txExporter, error) {
+	exporter := &txExporter{
+		format:     strings.ToLower(format),
+		writer:     writer,
+		rateSource: rateSource,
+	}
+
+	var err error
+	switch exporter.format {
+	case ExportFormatCSV:
+		exporter.csvWriter = csv.NewWriter(writer)
+		err = exporter.csvWriter.Write(exporter.csvHeader())
+	case ExportFormatJSON:
+		_, err = io.WriteString(writer, "[")
+	case ExportFormatOFX:
+		err = exporter.writeOFXHeader(query)
+	default:
+		return nil, errors.New(ErrInvalid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return exporter, nil
+}
+
+func (exporter *txExporter) write(tx *Transaction, bestBlock int32) error {
+	row, err := exporter.row(tx, bestBlock)
+	if err != nil {
+		return err
+	}
+
+	switch exporter.format {
+	case ExportFormatCSV:
+		err = exporter.csvWriter.Write(exporter.csvRecord(row))
+		// flush every page so that rows are not held in memory.
+		if err == nil && (exporter.rowCount+1)%exportPageSize == 0 {
+			exporter.csvWriter.Flush()
+			err = exporter.csvWriter.Error()
+		}
+	case ExportFormatJSON:
+		err = exporter.writeJSONRow(row)
+	case ExportFormatOFX:
+		err = exporter.writeOFXRow(row)
+	}
+
+	if err != nil {
+		return err
+	}
+	exporter.rowCount++
+	return nil
+}
+
+func (exporter *txExporter) finish() error {
+	switch exporter.format {
+	case ExportFormatCSV:
+		exporter.csvWriter.Flush()
+		return exporter.csvWriter.Error()
+	case ExportFormatJSON:
+		_, err := io.WriteString(exporter.writer, "]")
+		return err
+	case ExportFormatOFX:
+		_, err := io.WriteString(exporter.writer, ofxFooter)
+		return err
+	}
+	return nil
+}
+
+func (exporter *txExporter) row(tx *Transaction, bestBlock int32) (*exportRow, error) {
+	row := &exportRow{
+		WalletID:      tx.WalletID,
+		Hash:          tx.Hash,
+		Timestamp:     tx.Timestamp,
+		Date:          FormatUTCTime(tx.Timestamp),
+		BlockHeight:   tx.BlockHeight,
+		Confirmations: txConfirmations(tx, bestBlock),
+		Type:          tx.Type,
+		Direction:     TransactionDirectionName(tx.Direction),
+		Category:      exportCategory(tx),
+		Amount:        AmountCoin(tx.Amount),
+		Fee:           AmountCoin(tx.Fee),
+		StakingReward: AmountCoin(stakingReward(tx)),
+		Accounts:      strings.Join(txAccountNames(tx), ";"),
+		Label:         tx.Label,
+		Note:          tx.Note,
+	}
+
+	if exporter.rateSource != nil {
+		rate, err := exporter.rateSource.RateAt(tx.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("error getting fiat rate for tx %s: %v", tx.Hash, err)
+		}
+
+		fiatValue := row.Amount * rate
+		row.FiatCurrency = exporter.rateSource.Currency()
+		row.FiatRate = &rate
+		row.FiatValue = &fiatValue
+	}
+
+	return row, nil
+}
+
+func txConfirmations(tx *Transaction, bestBlock int32) int32 {
+	if tx.BlockHeight == BlockHeightInvalid || tx.BlockHeight > bestBlock {
+		return 0
+	}
+	return bestBlock - tx.BlockHeight + 1
+}
+
+func exportCategory(tx *Transaction) string {
+	switch tx.Type {
+	case TxTypeCoinBase:
+		return ExportCategoryCoinBase
+	case TxTypeTicketPurchase:
+		return ExportCategoryTicketPurchase
+	case TxTypeVote:
+		return ExportCategoryVoteReward
+	case TxTypeRevocation:
+		return ExportCategoryRevocation
+	}
+
+	switch tx.Direction {
+	case TxDirectionSent:
+		return ExportCategorySent
+	case TxDirectionReceived:
+		return ExportCategoryReceived
+	default:
+		return ExportCategoryTransferred
+	}
+}
+
+// stakingReward returns the amount returned by a vote or revocation in
+// excess of the price of the ticket it spends. This is the vote subsidy for
+// votes and is usually zero or negative (the fee) for revocations.
+func stakingReward(tx *Transaction) int64 {
+	var ticketInput int
+	switch tx.Type {
+	case TxTypeVote:
+		// the first input of a vote is the stakebase, the ticket is spent
+		// by the second input.
+		ticketInput = 1
+	case TxTypeRevocation:
+		ticketInput = 0
+	default:
+		return 0
+	}
+
+	if len(tx.Inputs) <= ticketInput {
+		return 0
+	}
+
+	var totalOutput int64
+	for _, output := range tx.Outputs {
+		totalOutput += output.Amount
+	}
+	return totalOutput - tx.Inputs[ticketInput].Amount
+}
+
+// txAccountNames returns the names of the wallet accounts that own any of
+// the inputs or outputs of `tx`.
+func txAccountNames(tx *Transaction) []string {
+	var names []string
+	seen := make(map[int32]bool)
+	addAccount := func(accountNumber int32, accountName string) {
+		if accountNumber < 0 || seen[accountNumber] {
+			return
+		}
+		seen[accountNumber] = true
+		names = append(names, accountName)
+	}
+
+	for _, input := range tx.Inputs {
+		addAccount(input.AccountNumber, input.AccountName)
+	}
+	for _, output := range tx.Outputs {
+		addAccount(output.AccountNumber, output.AccountName)
+	}
+
+	return names
+}
+
+func (exporter *txExporter) csvHeader() []string {
+	header := []string{"Wallet ID", "Date", "Timestamp", "Hash", "Block Height", "Confirmations", "Type",
+		"Direction", "Category", "Amount (DCR)", "Fee (DCR)", "Staking Reward (DCR)", "Accounts", "Label", "Note"}
+	if exporter.rateSource != nil {
+		currency := exporter.rateSource.Currency()
+		header = append(header, fmt.Sprintf("Rate (%s/DCR)", currency), fmt.Sprintf("Value (%s)", currency))
+	}
+	return header
+}
+
+func (exporter *txExporter) csvRecord(row *exportRow) []string {
+	record := []string{
+		strconv.Itoa(row.WalletID),
+		row.Date,
+		strconv.FormatInt(row.Timestamp, 10),
+		row.Hash,
+		strconv.FormatInt(int64(row.BlockHeight), 10),
+		strconv.FormatInt(int64(row.Confirmations), 10),
+		row.Type,
+		row.Direction,
+		row.Category,
+		formatCoin(row.Amount),
+		formatCoin(row.Fee),
+		formatCoin(row.StakingReward),
+		row.Accounts,
+		row.Label,
+		row.Note,
+	}
+	if exporter.rateSource != nil {
+		record = append(record, strconv.FormatFloat(*row.FiatRate, 'f', -1, 64),
+			strconv.FormatFloat(*row.FiatValue, 'f', 2, 64))
+	}
+	return record
+}
+
+func formatCoin(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 8, 64)
+}
+
+func (exporter *txExporter) writeJSONRow(row *exportRow) error {
+	rowJSON, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	if exporter.rowCount > 0 {
+		if _, err = io.WriteString(exporter.writer, ","); err != nil {
+			return err
+		}
+	}
+
+	_, err = exporter.writer.Write(rowJSON)
+	return err
+}
+
+const ofxFooter = `</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+// writeOFXHeader writes an OFX 2 bank statement header. The statement period
+// is taken from the query's time range as the transactions are streamed and
+// the actual range is not known until all transactions are written.
+func (exporter *txExporter) writeOFXHeader(query *TxQuery) error {
+	now := time.Now().Unix()
+	startTime, endTime := int64(0), now
+	if query != nil {
+		if query.StartTime > 0 {
+			startTime = query.StartTime
+		}
+		if query.EndTime > 0 && query.EndTime < now {
+			endTime = query.EndTime
+		}
+	}
+
+	currency := "DCR"
+	if exporter.rateSource != nil {
+		currency = exporter.rateSource.Currency()
+	}
+
+	_, err := fmt.Fprintf(exporter.writer, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1>
+<SONRS>
+<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<DTSERVER>%s</DTSERVER>
+<LANGUAGE>ENG</LANGUAGE>
+</SONRS>
+</SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<TRNUID>0</TRNUID>
+<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS>
+<CURDEF>%s</CURDEF>
+<BANKACCTFROM><BANKID>DCR</BANKID><ACCTID>dcrlibwallet</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>%s</DTSTART>
+<DTEND>%s</DTEND>
+`, ofxTime(now), currency, ofxTime(startTime), ofxTime(endTime))
+	return err
+}
+
+// writeOFXRow writes a transaction as an OFX STMTTRN. Amounts are in the fiat
+// currency if a rate source is set, otherwise in DCR.
+func (exporter *txExporter) writeOFXRow(row *exportRow) error {
+	amount, fee := row.Amount, row.Fee
+	if row.FiatValue != nil {
+		amount, fee = *row.FiatValue, row.Fee*(*row.FiatRate)
+	}
+
+	trnType := "XFER"
+	switch row.Category {
+	case ExportCategorySent, ExportCategoryTicketPurchase:
+		trnType = "DEBIT"
+		amount = -amount
+	case ExportCategoryReceived, ExportCategoryCoinBase:
+		trnType = "CREDIT"
+	case ExportCategoryVoteReward, ExportCategoryRevocation:
+		trnType = "INT"
+		amount = row.StakingReward
+		if row.FiatRate != nil {
+			amount *= *row.FiatRate
+		}
+	case ExportCategoryTransferred:
+		trnType = "FEE"
+		amount = -fee
+	}
+
+	name := row.Label
+	if name == "" {
+		name = row.Category
+	}
+
+	memo := fmt.Sprintf("%s %s; fee %s DCR; accounts: %s", row.Type, row.Direction, formatCoin(row.Fee), row.Accounts)
+	if row.Note != "" {
+		memo = row.Note + "; " + memo
+	}
+
+	_, err := fmt.Fprintf(exporter.writer, `<STMTTRN>
+<TRNTYPE>%s</TRNTYPE>
+<DTPOSTED>%s</DTPOSTED>
+<TRNAMT>%s</TRNAMT>
+<FITID>%d-%s</FITID>
+<NAME>%s</NAME>
+<MEMO>%s</MEMO>
+</STMTTRN>
+`, trnType, ofxTime(row.Timestamp), strconv.FormatFloat(amount, 'f', 8, 64), row.WalletID, row.Hash,
+		ofxEscape(name), ofxEscape(memo))
+	return err
+}
+
+func ofxTime(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format("20060102150405")
+}
+
+func ofxEscape(s string) string {
+	s = strings.Replace(s, "&", "&amp;", -1)
+	s = strings.Replace(s, "<", "&lt;", -1)
+	return strings.Replace(s, ">", "&gt;", -1)
+}
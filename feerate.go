@@ -0,0 +1,147 @@
+package dcrlibwallet
+
+import (
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/decred/dcrwallet/wallet/v3/txrules"
+)
+
+const (
+	FeeRatePresetEconomy  = "economy"
+	FeeRatePresetNormal   = "normal"
+	FeeRatePresetPriority = "priority"
+
+	// MaxFeeRateAtomsPerKB is the highest fee rate that is accepted without
+	// explicitly allowing high fees on a TxAuthor. It is 100 times the
+	// default relay fee.
+	MaxFeeRateAtomsPerKB = int64(100 * txrules.DefaultRelayFeePerKb)
+
+	// the economy preset pays the minimum relay fee, the other presets pay
+	// more to be preferred by miners when blocks are full.
+	defaultEconomyFeeRate  = int64(txrules.DefaultRelayFeePerKb)
+	defaultNormalFeeRate   = int64(3 * txrules.DefaultRelayFeePerKb / 2)
+	defaultPriorityFeeRate = int64(2 * txrules.DefaultRelayFeePerKb)
+)
+
+// validateFeeRate returns ErrFeeRateTooLow if `atomsPerKB` is below the
+// minimum relay fee and ErrFeeRateTooHigh if it is above
+// MaxFeeRateAtomsPerKB and high fees are not allowed.
+func validateFeeRate(atomsPerKB int64, allowHighFees bool) error {
+	if atomsPerKB < int64(txrules.DefaultRelayFeePerKb) {
+		return errors.New(ErrFeeRateTooLow)
+	}
+	if !allowHighFees && atomsPerKB > MaxFeeRateAtomsPerKB {
+		return errors.New(ErrFeeRateTooHigh)
+	}
+	return nil
+}
+
+func feeRateConfigKey(preset string) (string, int64, error) {
+	switch preset {
+	case FeeRatePresetEconomy:
+		return EconomyFeeRateConfigKey, defaultEconomyFeeRate, nil
+	case FeeRatePresetNormal:
+		return NormalFeeRateConfigKey, defaultNormalFeeRate, nil
+	case FeeRatePresetPriority:
+		return PriorityFeeRateConfigKey, defaultPriorityFeeRate, nil
+	default:
+		return "", 0, errors.New(ErrInvalid)
+	}
+}
+
+// FeeRateForPreset returns the fee rate in atoms/kB saved for `preset` or the
+// default rate for the preset if none was saved.
+func (mw *MultiWallet) FeeRateForPreset(preset string) (int64, error) {
+	configKey, defaultRate, err := feeRateConfigKey(preset)
+	if err != nil {
+		return 0, err
+	}
+	return mw.ReadLongConfigValueForKey(configKey, defaultRate), nil
+}
+
+// SetFeeRateForPreset saves `atomsPerKB` as the fee rate for `preset`. The
+// rate must not be below the relay fee or above MaxFeeRateAtomsPerKB.
+func (mw *MultiWallet) SetFeeRateForPreset(preset string, atomsPerKB int64) error {
+	configKey, _, err := feeRateConfigKey(preset)
+	if err != nil {
+		return err
+	}
+
+	if err = validateFeeRate(atomsPerKB, false); err != nil {
+		return err
+	}
+
+	mw.SetLongConfigValueForKey(configKey, atomsPerKB)
+	return nil
+}
+
+// SelectedFeeRatePreset returns the fee rate preset chosen by the user,
+// FeeRatePresetNormal is returned if none was chosen.
+func (mw *MultiWallet) SelectedFeeRatePreset() string {
+	preset := mw.ReadStringConfigValueForKey(FeeRatePresetConfigKey)
+	if _, _, err := feeRateConfigKey(preset); err != nil {
+		return FeeRatePresetNormal
+	}
+	return preset
+}
+
+func (mw *MultiWallet) SelectFeeRatePreset(preset string) error {
+	if _, _, err := feeRateConfigKey(preset); err != nil {
+		return err
+	}
+
+	mw.SetStringConfigValueForKey(FeeRatePresetConfigKey, preset)
+	return nil
+}
+
+// SelectedFeeRate returns the fee rate in atoms/kB of the selected preset.
+func (mw *MultiWallet) SelectedFeeRate() int64 {
+	feeRate, _ := mw.FeeRateForPreset(mw.SelectedFeeRatePreset())
+	return feeRate
+}
+
+// NewUnsignedTx creates a TxAuthor for the specified wallet that uses the
+// fee rate of the selected preset.
+func (mw *MultiWallet) NewUnsignedTx(walletID int, sourceAccountNumber, requiredConfirmations int32) (*TxAuthor, error) {
+	wallet := mw.WalletWithID(walletID)
+	if wallet == nil {
+		return nil, errors.New(ErrNotExist)
+	}
+
+	txAuthor := wallet.NewUnsignedTx(sourceAccountNumber, requiredConfirmations)
+	txAuthor.multiWallet = mw
+	return txAuthor, nil
+}
+
+// NewUnsignedMultisigTx creates a TxAuthor for a multisig address of the
+// specified wallet that uses the fee rate of the selected preset.
+func (mw *MultiWallet) NewUnsignedMultisigTx(walletID int, address string, requiredConfirmations int32) (*TxAuthor, error) {
+	wallet := mw.WalletWithID(walletID)
+	if wallet == nil {
+		return nil, errors.New(ErrNotExist)
+	}
+
+	txAuthor, err := wallet.NewUnsignedMultisigTx(address, requiredConfirmations)
+	if err != nil {
+		return nil, err
+	}
+
+	txAuthor.multiWallet = mw
+	return txAuthor, nil
+}
+
+// SweepPrivateKey sweeps the funds of `wif` to `destAccount` of the specified
+// wallet using the fee rate of the selected preset, see Wallet.SweepPrivateKey.
+func (mw *MultiWallet) SweepPrivateKey(walletID int, wif string, destAccount int32) ([]byte, error) {
+	return mw.SweepPrivateKeyFromHeight(walletID, wif, destAccount, 0)
+}
+
+// SweepPrivateKeyFromHeight is like SweepPrivateKey but only scans the main
+// chain blocks from `startHeight`, see Wallet.SweepPrivateKeyFromHeight.
+func (mw *MultiWallet) SweepPrivateKeyFromHeight(walletID int, wif string, destAccount, startHeight int32) ([]byte, error) {
+	wallet := mw.WalletWithID(walletID)
+	if wallet == nil {
+		return nil, errors.New(ErrNotExist)
+	}
+
+	return wallet.SweepPrivateKeyFromHeight(wif, destAccount, mw.SelectedFeeRate(), startHeight)
+}
@@ -0,0 +1,20 @@
+package dcrlibwallet
+
+import (
+	"testing"
+
+	"github.com/decred/dcrwallet/wallet/v3/txrules"
+)
+
+func TestNewUnsignedTxFeeRate(t *testing.T) {
+	wallet := &Wallet{ID: 1}
+	if feeRate := wallet.NewUnsignedTx(0, 0).FeeRate(); feeRate != int64(txrules.DefaultRelayFeePerKb) {
+		t.Fatalf("fee rate %d, expected the relay fee %d", feeRate, txrules.DefaultRelayFeePerKb)
+	}
+
+	// wallets loaded by a MultiWallet use the selected preset.
+	wallet.selectedFeeRate = func() int64 { return defaultPriorityFeeRate }
+	if feeRate := wallet.NewUnsignedTx(0, 0).FeeRate(); feeRate != defaultPriorityFeeRate {
+		t.Fatalf("fee rate %d, expected the selected preset rate %d", feeRate, defaultPriorityFeeRate)
+	}
+}
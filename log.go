@@ -19,6 +19,7 @@ import (
 	"github.com/jrick/logrotate/rotator"
 	"github.com/raedahgroup/dcrlibwallet/internal/loader"
 	"github.com/raedahgroup/dcrlibwallet/spv"
+	"github.com/raedahgroup/dcrlibwallet/txindex"
 )
 
 // logWriter implements an io.Writer that outputs to both standard output and
@@ -67,6 +68,7 @@ func init() {
 	udb.UseLogger(walletLog)
 	ticketbuyer.UseLogger(tkbyLog)
 	spv.UseLogger(syncLog)
+	txindex.UseLogger(log)
 	p2p.UseLogger(syncLog)
 	connmgr.UseLogger(cmgrLog)
 	addrmgr.UseLogger(amgrLog)
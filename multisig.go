@@ -0,0 +1,318 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/hdkeychain/v2"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+	w "github.com/decred/dcrwallet/wallet/v3"
+	"github.com/decred/dcrwallet/wallet/v3/txauthor"
+	"github.com/decred/dcrwallet/wallet/v3/udb"
+)
+
+// MultisigAddress is an m-of-n P2SH address created from the account
+// extended public keys of its cosigners.
+type MultisigAddress struct {
+	Address            string   `json:"address"`
+	RedeemScript       string   `json:"redeem_script"`
+	RequiredSignatures int32    `json:"required_signatures"`
+	CosignerXpubs      []string `json:"cosigner_xpubs"`
+	AddressIndex       uint32   `json:"address_index"`
+	CreatedAt          int64    `json:"created_at"`
+}
+
+// CreateMultisigAddress creates a P2SH address that requires
+// `requiredSignatures` signatures from the keys at external branch index
+// `addressIndex` of each of the cosigner account xpubs, and imports its
+// redeem script so that the wallet watches the address. The xpub of one of
+// this wallet's accounts should be included in `cosignerXpubs` for the
+// wallet to sign spends from the address. Every cosigner must create the
+// address with the same xpubs, required signatures and index, the order of
+// the xpubs does not change the address.
+func (wallet *Wallet) CreateMultisigAddress(requiredSignatures int32, cosignerXpubs []string, addressIndex uint32) (*MultisigAddress, error) {
+	if len(cosignerXpubs) < 2 || requiredSignatures < 1 || int(requiredSignatures) > len(cosignerXpubs) {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	pubKeys := make([]*dcrutil.AddressSecpPubKey, 0, len(cosignerXpubs))
+	for _, xpub := range cosignerXpubs {
+		pubKey, err := wallet.cosignerPubKey(xpub, addressIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, existing := range pubKeys {
+			if bytes.Equal(existing.ScriptAddress(), pubKey.ScriptAddress()) {
+				return nil, errors.New(ErrExist)
+			}
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	// sort the keys so that all cosigners get the same redeem script.
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i].ScriptAddress(), pubKeys[j].ScriptAddress()) < 0
+	})
+
+	redeemScript, err := txscript.MultiSigScript(pubKeys, int(requiredSignatures))
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := dcrutil.NewAddressScriptHash(redeemScript, wallet.chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wallet.internal.ImportScript(wallet.shutdownContext(), redeemScript)
+	if err != nil && !errors.Is(errors.Exist, err) {
+		return nil, fmt.Errorf("error importing multisig redeem script: %v", err)
+	}
+
+	multisigAddress := &MultisigAddress{
+		Address:            address.Address(),
+		RedeemScript:       hex.EncodeToString(redeemScript),
+		RequiredSignatures: requiredSignatures,
+		CosignerXpubs:      cosignerXpubs,
+		AddressIndex:       addressIndex,
+		CreatedAt:          time.Now().Unix(),
+	}
+
+	wallet.multisigAddressesMu.Lock()
+	defer wallet.multisigAddressesMu.Unlock()
+
+	multisigAddresses := wallet.readMultisigAddresses()
+	if existing, ok := multisigAddresses[multisigAddress.Address]; ok {
+		return existing, nil
+	}
+	multisigAddresses[multisigAddress.Address] = multisigAddress
+	wallet.SaveUserConfigValue(MultisigAddressesConfigKey, multisigAddresses)
+
+	return multisigAddress, nil
+}
+
+// cosignerPubKey returns the public key at external branch index
+// `addressIndex` of the account extended public key `xpub`.
+func (wallet *Wallet) cosignerPubKey(xpub string, addressIndex uint32) (*dcrutil.AddressSecpPubKey, error) {
+	accountKey, err := hdkeychain.NewKeyFromString(xpub, wallet.chainParams)
+	if err != nil || accountKey.IsPrivate() {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	branchKey, err := accountKey.Child(udb.ExternalBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := branchKey.Child(addressIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return dcrutil.NewAddressSecpPubKey(pubKey.SerializeCompressed(), wallet.chainParams)
+}
+
+// MultisigAddresses returns the multisig addresses created by this wallet.
+func (wallet *Wallet) MultisigAddresses() ([]*MultisigAddress, error) {
+	wallet.multisigAddressesMu.Lock()
+	defer wallet.multisigAddressesMu.Unlock()
+
+	multisigAddresses := wallet.readMultisigAddresses()
+	addresses := make([]*MultisigAddress, 0, len(multisigAddresses))
+	for _, multisigAddress := range multisigAddresses {
+		addresses = append(addresses, multisigAddress)
+	}
+
+	return addresses, nil
+}
+
+func (wallet *Wallet) MultisigAddressesJSON() (string, error) {
+	addresses, err := wallet.MultisigAddresses()
+	if err != nil {
+		return "", err
+	}
+
+	result, _ := json.Marshal(addresses)
+	return string(result), nil
+}
+
+// readMultisigAddresses must be called with wallet.multisigAddressesMu held.
+func (wallet *Wallet) readMultisigAddresses() map[string]*MultisigAddress {
+	multisigAddresses := make(map[string]*MultisigAddress)
+	wallet.ReadUserConfigValue(MultisigAddressesConfigKey, &multisigAddresses)
+	return multisigAddresses
+}
+
+func (wallet *Wallet) multisigAddress(address string) (*MultisigAddress, error) {
+	wallet.multisigAddressesMu.Lock()
+	defer wallet.multisigAddressesMu.Unlock()
+
+	multisigAddress, ok := wallet.readMultisigAddresses()[address]
+	if !ok {
+		return nil, errors.New(ErrNotExist)
+	}
+	return multisigAddress, nil
+}
+
+// MultisigUnspentOutputs returns the unspent outputs paying to the multisig
+// address `address` that have at least `requiredConfirmations`
+// confirmations. Outputs paying to imported scripts belong to the imported
+// account.
+func (wallet *Wallet) MultisigUnspentOutputs(address string, requiredConfirmations int32) ([]*UnspentOutput, error) {
+	if _, err := wallet.multisigAddress(address); err != nil {
+		return nil, err
+	}
+
+	outputs, err := wallet.UnspentOutputs(int32(udb.ImportedAddrAccount), requiredConfirmations)
+	if err != nil {
+		return nil, err
+	}
+
+	multisigOutputs := make([]*UnspentOutput, 0, len(outputs))
+	for _, output := range outputs {
+		if output.Address == address {
+			multisigOutputs = append(multisigOutputs, output)
+		}
+	}
+
+	return multisigOutputs, nil
+}
+
+// NewUnsignedMultisigTx creates a TxAuthor that spends the outputs of the
+// multisig address `address` and returns change to the same address. The
+// transaction must be exported with ExportUnsigned and signed by enough
+// cosigners using SignTransactionEnvelope before it is published with
+// PublishTransactionEnvelope.
+func (wallet *Wallet) NewUnsignedMultisigTx(address string, requiredConfirmations int32) (*TxAuthor, error) {
+	multisigAddress, err := wallet.multisigAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	txAuthor := wallet.NewUnsignedTx(int32(udb.ImportedAddrAccount), requiredConfirmations)
+	txAuthor.multisig = multisigAddress
+	return txAuthor, nil
+}
+
+// multisigInputsSource returns an input source for the unspent outputs of
+// the multisig address of this transaction. If `all` is true or inputs are
+// selected with UseInputs, all outputs (or all selected outputs) are
+// returned by the input source regardless of the target amount. The total
+// amount of the outputs that may be returned is also returned.
+func (tx *TxAuthor) multisigInputsSource(all bool) (txauthor.InputSource, dcrutil.Amount, error) {
+	redeemScript, err := hex.DecodeString(tx.multisig.RedeemScript)
+	if err != nil {
+		return nil, 0, err
+	}
+	sigScriptSize := multisigSigScriptSize(int(tx.multisig.RequiredSignatures), len(redeemScript))
+
+	outputs, err := tx.wallet.unspentOutputs(udb.ImportedAddrAccount, tx.requiredConfirmations)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	multisigOutputs := make(map[string]*w.TransactionOutput)
+	var orderedKeys []string
+	for _, output := range outputs {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(output.Output.Version, output.Output.PkScript, tx.wallet.chainParams)
+		if err != nil || len(addrs) != 1 || addrs[0].Address() != tx.multisig.Address {
+			continue
+		}
+
+		key := outpointKey(&output.OutPoint)
+		multisigOutputs[key] = output
+		orderedKeys = append(orderedKeys, key)
+	}
+
+	if len(tx.inputs) > 0 {
+		for _, key := range tx.inputs {
+			if _, ok := multisigOutputs[key]; !ok {
+				return nil, 0, fmt.Errorf("selected input %s is not spendable from %s", key, tx.multisig.Address)
+			}
+		}
+		orderedKeys = tx.inputs
+		all = true
+	}
+
+	var total dcrutil.Amount
+	for _, key := range orderedKeys {
+		total += dcrutil.Amount(multisigOutputs[key].Output.Value)
+	}
+
+	inputSource := func(target dcrutil.Amount) (*txauthor.InputDetail, error) {
+		inputDetail := &txauthor.InputDetail{}
+		for _, key := range orderedKeys {
+			if !all && inputDetail.Amount >= target {
+				break
+			}
+
+			output := multisigOutputs[key]
+			outpoint := output.OutPoint
+			amount := dcrutil.Amount(output.Output.Value)
+			inputDetail.Amount += amount
+			inputDetail.Inputs = append(inputDetail.Inputs, wire.NewTxIn(&outpoint, int64(amount), nil))
+			inputDetail.Scripts = append(inputDetail.Scripts, output.Output.PkScript)
+			inputDetail.RedeemScriptSizes = append(inputDetail.RedeemScriptSizes, sigScriptSize)
+		}
+		return inputDetail, nil
+	}
+
+	return inputSource, total, nil
+}
+
+// multisigSigScriptSize returns the size of a signature script that spends
+// a P2SH multisig output with `requiredSignatures` signatures.
+func multisigSigScriptSize(requiredSignatures, redeemScriptSize int) int {
+	// each signature is pushed with a 1 byte data push opcode and is at
+	// most 72 bytes long including the sighash type.
+	size := requiredSignatures * (1 + 72)
+
+	switch {
+	case redeemScriptSize < txscript.OP_PUSHDATA1:
+		size++
+	case redeemScriptSize <= 0xff:
+		size += 2
+	default:
+		size += 3
+	}
+
+	return size + redeemScriptSize
+}
+
+// multisigSignatureCount returns the number of signatures in a P2SH
+// multisig signature script. The last data push is the redeem script, all
+// other data pushes are signatures.
+func multisigSignatureCount(sigScript []byte) (int, error) {
+	if len(sigScript) == 0 {
+		return 0, nil
+	}
+
+	pushes, err := txscript.PushedData(sigScript)
+	if err != nil {
+		return 0, err
+	}
+	if len(pushes) == 0 {
+		return 0, nil
+	}
+
+	var count int
+	for _, push := range pushes[:len(pushes)-1] {
+		if len(push) > 0 {
+			count++
+		}
+	}
+	return count, nil
+}
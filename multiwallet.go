@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
@@ -29,9 +31,16 @@ type MultiWallet struct {
 	wallets     map[int]*Wallet
 	syncData    *syncData
 
-	txAndBlockNotificationListeners map[string]TxAndBlockNotificationListener
+	notificationHub *notificationHub
+
+	// txAndBlockNotificationListeners holds the function used to cancel the
+	// event subscription of each listener. Protected by notificationListenersMu.
+	txAndBlockNotificationListeners map[string]context.CancelFunc
+	notificationListenersMu         sync.Mutex
 	blocksRescanProgressListener    BlocksRescanProgressListener
 
+	fiatRateSource FiatRateSource
+
 	shuttingDown chan bool
 	cancelFuncs  []context.CancelFunc
 }
@@ -72,6 +81,18 @@ func NewMultiWallet(rootDir, dbDriver, netType string) (*MultiWallet, error) {
 		return nil, err
 	}
 
+	err = walletsDb.Init(&AddressBookEntry{})
+	if err != nil {
+		log.Errorf("Error initializing address book: %s", err.Error())
+		return nil, err
+	}
+
+	err = walletsDb.Init(&BannedPeer{})
+	if err != nil {
+		log.Errorf("Error initializing banned peers: %s", err.Error())
+		return nil, err
+	}
+
 	mw := &MultiWallet{
 		dbDriver:    dbDriver,
 		rootDir:     rootDir,
@@ -81,8 +102,10 @@ func NewMultiWallet(rootDir, dbDriver, netType string) (*MultiWallet, error) {
 		syncData: &syncData{
 			syncCanceled:          make(chan bool),
 			syncProgressListeners: make(map[string]SyncProgressListener),
+			rescans:               make(map[int]*walletRescan),
 		},
-		txAndBlockNotificationListeners: make(map[string]TxAndBlockNotificationListener),
+		notificationHub:                 newNotificationHub(),
+		txAndBlockNotificationListeners: make(map[string]context.CancelFunc),
 	}
 
 	// read saved wallets info from db and initialize wallets
@@ -94,12 +117,31 @@ func NewMultiWallet(rootDir, dbDriver, netType string) (*MultiWallet, error) {
 	}
 
 	// prepare the wallets loaded from db for use
+	var seedDeviceKey *[seedKeySize]byte
 	for _, wallet := range wallets {
-		err = wallet.prepare(rootDir, chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID))
+		err = wallet.prepare(rootDir, chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID), mw.walletHTTPClientFn(wallet.ID), mw.walletSaveFn(wallet), mw.SelectedFeeRate)
 		if err != nil {
 			return nil, err
 		}
 		mw.wallets[wallet.ID] = wallet
+
+		if wallet.Seed != "" || wallet.WrappedSeed != nil {
+			if seedDeviceKey == nil {
+				seedDeviceKey, err = loadSeedDeviceKey(rootDir)
+				if err != nil {
+					return nil, err
+				}
+			}
+			wallet.seedDeviceKey = seedDeviceKey
+
+			// the private passphrase is required to encrypt the seed
+			// properly, until the wallet is unlocked the seed is only
+			// encrypted with the device key.
+			if err = wallet.wrapPlaintextSeed(); err != nil {
+				log.Errorf("[%d] Error encrypting wallet seed with the device key: %v", wallet.ID, err)
+			}
+			log.Warnf("[%d] Wallet seed will be encrypted with the private passphrase the next time the wallet is unlocked", wallet.ID)
+		}
 	}
 
 	mw.listenForShutdown()
@@ -235,6 +277,8 @@ func (mw *MultiWallet) OpenWallets(startupPassphrase []byte) error {
 		}
 
 		go mw.listenForTransactions(wallet.ID)
+
+		wallet.resumeTicketBuyer()
 	}
 
 	return nil
@@ -247,7 +291,7 @@ func (mw *MultiWallet) CreateWatchOnlyWallet(walletName, extendedPublicKey strin
 	}
 
 	return mw.saveNewWallet(wallet, func() error {
-		err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID))
+		err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID), mw.walletHTTPClientFn(wallet.ID), mw.walletSaveFn(wallet), mw.SelectedFeeRate)
 		if err != nil {
 			return err
 		}
@@ -256,20 +300,46 @@ func (mw *MultiWallet) CreateWatchOnlyWallet(walletName, extendedPublicKey strin
 	})
 }
 
+// CreateWatchOnlyFromWallet creates a watch-only wallet named `walletName`
+// that watches the account `accountNumber` of the wallet with id `walletID`.
+func (mw *MultiWallet) CreateWatchOnlyFromWallet(walletID int, accountNumber int32, walletName string) (*Wallet, error) {
+	wallet := mw.WalletWithID(walletID)
+	if wallet == nil {
+		return nil, errors.New(ErrNotExist)
+	}
+
+	extendedPubKey, err := wallet.AccountExtendedPubKey(accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mw.ValidateExtPubKey(extendedPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return mw.CreateWatchOnlyWallet(walletName, extendedPubKey)
+}
+
 func (mw *MultiWallet) CreateNewWallet(privatePassphrase string, privatePassphraseType int32) (*Wallet, error) {
 	seed, err := GenerateSeed()
 	if err != nil {
 		return nil, err
 	}
 
+	encryptedSeed, err := encryptWalletSeed([]byte(privatePassphrase), seed)
+	if err != nil {
+		return nil, err
+	}
+
 	wallet := &Wallet{
-		Seed:                  seed,
+		EncryptedSeed:         encryptedSeed,
 		PrivatePassphraseType: privatePassphraseType,
 		HasDiscoveredAccounts: true,
 	}
 
 	return mw.saveNewWallet(wallet, func() error {
-		err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID))
+		err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID), mw.walletHTTPClientFn(wallet.ID), mw.walletSaveFn(wallet), mw.SelectedFeeRate)
 		if err != nil {
 			return err
 		}
@@ -279,14 +349,30 @@ func (mw *MultiWallet) CreateNewWallet(privatePassphrase string, privatePassphra
 }
 
 func (mw *MultiWallet) RestoreWallet(seedMnemonic, privatePassphrase string, privatePassphraseType int32) (*Wallet, error) {
+	return mw.RestoreWalletWithBirthday(seedMnemonic, privatePassphrase, privatePassphraseType, nil)
+}
+
+// RestoreWalletWithBirthday restores a wallet from `seedMnemonic`. If
+// `birthday` is not nil, address discovery and the rescan performed during
+// the wallet's first sync start from the birthday instead of the genesis
+// block.
+func (mw *MultiWallet) RestoreWalletWithBirthday(seedMnemonic, privatePassphrase string, privatePassphraseType int32, birthday *WalletBirthday) (*Wallet, error) {
 	wallet := &Wallet{
 		PrivatePassphraseType: privatePassphraseType,
 		IsRestored:            true,
 		HasDiscoveredAccounts: false,
 	}
 
+	if birthday != nil {
+		if birthday.Height < 0 || birthday.Timestamp < 0 || birthday.Timestamp > time.Now().Unix() {
+			return nil, errors.New(ErrInvalid)
+		}
+		wallet.BirthdayHeight = birthday.Height
+		wallet.BirthdayTimestamp = birthday.Timestamp
+	}
+
 	return mw.saveNewWallet(wallet, func() error {
-		err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID))
+		err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID), mw.walletHTTPClientFn(wallet.ID), mw.walletSaveFn(wallet), mw.SelectedFeeRate)
 		if err != nil {
 			return err
 		}
@@ -334,7 +420,7 @@ func (mw *MultiWallet) LinkExistingWallet(walletDataDir, originalPubPass string,
 
 		// prepare the wallet for use and open it
 		err := (func() error {
-			err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID))
+			err := wallet.prepare(mw.rootDir, mw.chainParams, mw.walletConfigSetFn(wallet.ID), mw.walletConfigReadFn(wallet.ID), mw.walletHTTPClientFn(wallet.ID), mw.walletSaveFn(wallet), mw.SelectedFeeRate)
 			if err != nil {
 				return err
 			}
@@ -482,8 +568,34 @@ func (mw *MultiWallet) VerifySeedForWallet(walletID int, seedMnemonic string) er
 		return errors.New(ErrNotExist)
 	}
 
-	if wallet.Seed == seedMnemonic {
+	if !wallet.needsSeedBackup() {
+		return errors.New(ErrInvalid)
+	}
+
+	wallet.seedMu.Lock()
+	legacySeed, err := wallet.legacySeed()
+	wallet.seedMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var seedMatches bool
+	if legacySeed != "" {
+		seedMatches = legacySeed == seedMnemonic
+	} else {
+		seedMatches, err = wallet.seedMatches(seedMnemonic)
+		if err != nil {
+			return err
+		}
+	}
+
+	if seedMatches {
+		wallet.seedMu.Lock()
+		defer wallet.seedMu.Unlock()
+
 		wallet.Seed = ""
+		wallet.WrappedSeed = nil
+		wallet.EncryptedSeed = nil
 		return translateError(mw.db.Save(wallet))
 	}
 
@@ -493,7 +605,7 @@ func (mw *MultiWallet) VerifySeedForWallet(walletID int, seedMnemonic string) er
 func (mw *MultiWallet) NumWalletsNeedingSeedBackup() int32 {
 	var backupsNeeded int32
 	for _, wallet := range mw.wallets {
-		if wallet.WalletOpened() && wallet.Seed != "" {
+		if wallet.WalletOpened() && wallet.needsSeedBackup() {
 			backupsNeeded++
 		}
 	}
@@ -528,7 +640,7 @@ func (mw *MultiWallet) OpenedWalletsCount() int32 {
 func (mw *MultiWallet) SyncedWalletsCount() int32 {
 	var syncedWallets int32
 	for _, wallet := range mw.wallets {
-		if wallet.WalletOpened() && wallet.synced {
+		if wallet.WalletOpened() && wallet.IsSynced() {
 			syncedWallets++
 		}
 	}
@@ -570,11 +682,48 @@ func (mw *MultiWallet) ChangePrivatePassphraseForWallet(walletID int, oldPrivate
 		return errors.New(ErrInvalid)
 	}
 
+	wallet.seedMu.Lock()
+	defer wallet.seedMu.Unlock()
+
+	// re-encrypt the unverified seed with the new passphrase. This must be
+	// done before changing the passphrase as both passphrases are cleared
+	// by wallet.changePrivatePassphrase.
+	var encryptedSeed []byte
+	if wallet.EncryptedSeed != nil {
+		seed, err := decryptWalletSeed(oldPrivatePassphrase, wallet.EncryptedSeed)
+		if err != nil {
+			return err
+		}
+
+		encryptedSeed, err = encryptWalletSeed(newPrivatePassphrase, seed)
+		if err != nil {
+			return err
+		}
+	} else {
+		seed, err := wallet.legacySeed()
+		if err != nil {
+			return err
+		}
+
+		if seed != "" {
+			encryptedSeed, err = encryptWalletSeed(newPrivatePassphrase, seed)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	err := wallet.changePrivatePassphrase(oldPrivatePassphrase, newPrivatePassphrase)
 	if err != nil {
 		return translateError(err)
 	}
 
+	if encryptedSeed != nil {
+		wallet.EncryptedSeed = encryptedSeed
+		wallet.Seed = ""
+		wallet.WrappedSeed = nil
+	}
+
 	wallet.PrivatePassphraseType = privatePassphraseType
 	return mw.db.Save(wallet)
 }
@@ -1,6 +1,8 @@
 package dcrlibwallet
 
 import (
+	"net/http"
+
 	"github.com/asdine/storm"
 )
 
@@ -23,10 +25,24 @@ const (
 	NetworkModeConfigKey                = "network_mode"
 	SpvPersistentPeerAddressesConfigKey = "spv_peer_addresses"
 	UserAgentConfigKey                  = "user_agent"
+	ProxyConfigKey                      = "proxy_config"
+	SyncPausedConfigKey                 = "sync_paused"
 
 	LastTxHashConfigKey = "last_tx_hash"
 
-	VSPHostConfigKey = "vsp_host"
+	VSPHostConfigKey    = "vsp_host"
+	VSPTicketsConfigKey = "vsp_tickets"
+	VSPPubKeysConfigKey = "vsp_pubkeys"
+
+	FeeRatePresetConfigKey   = "fee_rate_preset"
+	EconomyFeeRateConfigKey  = "fee_rate_economy"
+	NormalFeeRateConfigKey   = "fee_rate_normal"
+	PriorityFeeRateConfigKey = "fee_rate_priority"
+
+	TicketBuyerConfigKey        = "ticket_buyer_config"
+	TicketBuyerEnabledConfigKey = "ticket_buyer_enabled"
+
+	MultisigAddressesConfigKey = "multisig_addresses"
 
 	PassphraseTypePin  int32 = 0
 	PassphraseTypePass int32 = 1
@@ -34,6 +50,17 @@ const (
 
 type configSaveFn = func(key string, value interface{}) error
 type configReadFn = func(key string, valueOut interface{}) error
+type httpClientFn = func() *http.Client
+type walletSaveFn = func() error
+type feeRateFn = func() int64
+
+// walletSaveFn returns the function used by `wallet` to save changes to its
+// record in the wallets db.
+func (mw *MultiWallet) walletSaveFn(wallet *Wallet) walletSaveFn {
+	return func() error {
+		return mw.db.Save(wallet)
+	}
+}
 
 func (mw *MultiWallet) walletConfigSetFn(walletID int) configSaveFn {
 	return func(key string, value interface{}) error {
@@ -89,7 +89,7 @@ func (mw *MultiWallet) markWalletAsDiscoveredAccounts(walletID int) error {
 
 func (mw *MultiWallet) setNetworkBackend(syncer *spv.Syncer) {
 	for walletID, wallet := range mw.wallets {
-		if wallet.WalletOpened() {
+		if wallet.WalletOpened() && !wallet.isSyncPaused() {
 			walletBackend := &spv.WalletBackend{
 				Syncer:   syncer,
 				WalletID: walletID,
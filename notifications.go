@@ -0,0 +1,296 @@
+package dcrlibwallet
+
+import (
+	"context"
+	"sync"
+)
+
+// EventType identifies what an Event reports. Events cover transactions,
+// blocks, balances, tickets and the sync state of the wallets. The detailed
+// progress reported to SyncProgressListener, BlocksRescanProgressListener and
+// TicketBuyerNotificationListener is not published as events, those
+// listeners are still called directly.
+type EventType int
+
+const (
+	// EventNewTransaction is sent when a new unmined transaction is seen.
+	EventNewTransaction EventType = iota + 1
+
+	// EventTransactionConfirmed is sent when a transaction is mined.
+	EventTransactionConfirmed
+
+	// EventBlockAttached is sent when a block is attached to the main chain.
+	EventBlockAttached
+
+	// EventReorg is sent when blocks are removed from the main chain.
+	EventReorg
+
+	// EventBalanceChanged is sent when the total balance of an account changes.
+	EventBalanceChanged
+
+	// EventTicketStatusChanged is sent when a ticket is purchased, voted
+	// or revoked.
+	EventTicketStatusChanged
+
+	// EventSyncStateChanged is sent when sync starts, completes or stops.
+	EventSyncStateChanged
+)
+
+// SlowConsumerPolicy determines what happens to events published to a
+// subscriber whose buffer is full.
+type SlowConsumerPolicy int
+
+const (
+	// DropEvents drops events that do not fit in the subscriber's buffer.
+	// The number of events dropped is reported in Event.Dropped of the next
+	// event delivered to the subscriber.
+	DropEvents SlowConsumerPolicy = iota
+
+	// Unsubscribe closes the subscriber's channel the first time an event
+	// does not fit in the buffer.
+	Unsubscribe
+
+	// Unbounded queues the events that do not fit in the subscriber's buffer
+	// and delivers them in order once the subscriber catches up. No event is
+	// dropped but the queue grows for as long as the subscriber is slow.
+	Unbounded
+)
+
+const defaultEventBufferSize = 100
+
+// Event is a notification sent to subscribers. Only the fields relevant to
+// the event type are set.
+type Event struct {
+	Type     EventType
+	WalletID int
+
+	// Dropped is the number of events dropped for this subscriber since the
+	// previous event was delivered.
+	Dropped uint64
+
+	// EventNewTransaction, EventTransactionConfirmed
+	Transaction *Transaction
+
+	// EventTransactionConfirmed, EventBlockAttached, EventReorg
+	// For EventReorg, BlockHeight is the height of the lowest detached block.
+	BlockHeight int32
+	ReorgDepth  int32
+
+	// EventBalanceChanged
+	Account      int32
+	TotalBalance int64
+
+	// EventTicketStatusChanged, the hash of the ticket and the type of the
+	// transaction that changed its status.
+	TicketHash string
+	TxType     string
+
+	// EventSyncStateChanged
+	Syncing bool
+	Synced  bool
+}
+
+// EventFilter selects the events sent to a subscriber.
+type EventFilter struct {
+	// WalletIDs limits events to the specified wallets. Sync state events
+	// are not specific to a wallet and are always sent. All wallets if empty.
+	WalletIDs []int
+
+	// Types limits events to the specified types. All types if empty.
+	Types []EventType
+
+	// BufferSize is the size of the subscriber's channel. A default size is
+	// used if it is not positive.
+	BufferSize int
+
+	SlowConsumerPolicy SlowConsumerPolicy
+}
+
+type subscriber struct {
+	events  chan *Event
+	filter  EventFilter
+	dropped uint64
+	closed  bool
+
+	// used with the Unbounded policy. queue holds the events waiting to be
+	// sent to events, queued is signaled when an event is queued and done is
+	// closed when the subscriber is removed.
+	queue  []*Event
+	queued chan struct{}
+	done   chan struct{}
+}
+
+// reading/writing of properties of this struct are protected by mutex.
+type notificationHub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+func newNotificationHub() *notificationHub {
+	return &notificationHub{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives the events matching `filter`
+// until `ctx` is canceled, after which the channel is closed. A nil filter
+// receives all events. Events are never blocked on a slow subscriber, the
+// filter's SlowConsumerPolicy determines what happens when the subscriber's
+// buffer is full.
+// TxAndBlockNotificationListener is built on Subscribe, see EventType for the
+// listeners that are not.
+func (mw *MultiWallet) Subscribe(ctx context.Context, filter *EventFilter) <-chan *Event {
+	sub := &subscriber{}
+	if filter != nil {
+		sub.filter = *filter
+	}
+
+	bufferSize := sub.filter.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBufferSize
+	}
+	sub.events = make(chan *Event, bufferSize)
+
+	hub := mw.notificationHub
+	if sub.filter.SlowConsumerPolicy == Unbounded {
+		sub.queued = make(chan struct{}, 1)
+		sub.done = make(chan struct{})
+		go hub.deliverQueued(sub)
+	}
+
+	hub.mu.Lock()
+	hub.subscribers[sub] = struct{}{}
+	hub.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		hub.mu.Lock()
+		hub.remove(sub)
+		hub.mu.Unlock()
+	}()
+
+	return sub.events
+}
+
+// remove closes the subscriber's channel and removes it from the hub.
+// Must be called with hub.mu held.
+func (hub *notificationHub) remove(sub *subscriber) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(hub.subscribers, sub)
+
+	if sub.filter.SlowConsumerPolicy == Unbounded {
+		// the channel is closed by deliverQueued once it stops sending.
+		close(sub.done)
+		return
+	}
+	close(sub.events)
+}
+
+// deliverQueued sends the events queued for a subscriber with the Unbounded
+// policy, in the order they were published, until the subscriber is removed.
+func (hub *notificationHub) deliverQueued(sub *subscriber) {
+	defer close(sub.events)
+
+	for {
+		hub.mu.Lock()
+		queue := sub.queue
+		sub.queue = nil
+		hub.mu.Unlock()
+
+		for _, event := range queue {
+			select {
+			case sub.events <- event:
+			case <-sub.done:
+				return
+			}
+		}
+
+		select {
+		case <-sub.queued:
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+func (sub *subscriber) matches(event *Event) bool {
+	if len(sub.filter.Types) > 0 {
+		var typeMatches bool
+		for _, eventType := range sub.filter.Types {
+			if eventType == event.Type {
+				typeMatches = true
+				break
+			}
+		}
+		if !typeMatches {
+			return false
+		}
+	}
+
+	if len(sub.filter.WalletIDs) > 0 && event.Type != EventSyncStateChanged {
+		for _, walletID := range sub.filter.WalletIDs {
+			if walletID == event.WalletID {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// publish sends `event` to every matching subscriber without blocking.
+func (hub *notificationHub) publish(event *Event) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for sub := range hub.subscribers {
+		if !sub.matches(event) {
+			continue
+		}
+
+		// each subscriber gets its own copy so that Dropped can be set.
+		subEvent := *event
+		subEvent.Dropped = sub.dropped
+
+		if sub.filter.SlowConsumerPolicy == Unbounded {
+			sub.queue = append(sub.queue, &subEvent)
+			select {
+			case sub.queued <- struct{}{}:
+			default:
+			}
+			continue
+		}
+
+		select {
+		case sub.events <- &subEvent:
+			sub.dropped = 0
+		default:
+			if sub.filter.SlowConsumerPolicy == Unsubscribe {
+				log.Warnf("Closing event subscription, subscriber is not keeping up")
+				hub.remove(sub)
+			} else {
+				sub.dropped++
+			}
+		}
+	}
+}
+
+func (mw *MultiWallet) publishEvent(event *Event) {
+	mw.notificationHub.publish(event)
+}
+
+func (mw *MultiWallet) publishSyncStateChanged() {
+	mw.syncData.mu.RLock()
+	syncing, synced := mw.syncData.syncing, mw.syncData.synced
+	mw.syncData.mu.RUnlock()
+
+	mw.publishEvent(&Event{
+		Type:    EventSyncStateChanged,
+		Syncing: syncing,
+		Synced:  synced,
+	})
+}
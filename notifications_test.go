@@ -0,0 +1,66 @@
+package dcrlibwallet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribeUnbounded(t *testing.T) {
+	mw := &MultiWallet{notificationHub: newNotificationHub()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := mw.Subscribe(ctx, &EventFilter{
+		BufferSize:         2,
+		SlowConsumerPolicy: Unbounded,
+	})
+
+	// publish more events than fit in the buffer before reading any.
+	const eventCount = 50
+	for height := int32(1); height <= eventCount; height++ {
+		mw.publishEvent(&Event{Type: EventBlockAttached, BlockHeight: height})
+	}
+
+	for height := int32(1); height <= eventCount; height++ {
+		select {
+		case event := <-events:
+			if event.BlockHeight != height || event.Dropped != 0 {
+				t.Fatalf("received block %d with %d dropped, expected block %d", event.BlockHeight, event.Dropped, height)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for block %d", height)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("received an event after the subscription was canceled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after the subscription was canceled")
+	}
+}
+
+func TestSubscribeDropEvents(t *testing.T) {
+	mw := &MultiWallet{notificationHub: newNotificationHub()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := mw.Subscribe(ctx, &EventFilter{BufferSize: 1})
+
+	mw.publishEvent(&Event{Type: EventBlockAttached, BlockHeight: 1})
+	mw.publishEvent(&Event{Type: EventBlockAttached, BlockHeight: 2})
+	mw.publishEvent(&Event{Type: EventBlockAttached, BlockHeight: 3})
+
+	if event := <-events; event.BlockHeight != 1 {
+		t.Fatalf("received block %d, expected block 1", event.BlockHeight)
+	}
+
+	mw.publishEvent(&Event{Type: EventBlockAttached, BlockHeight: 4})
+	event := <-events
+	if event.BlockHeight != 4 || event.Dropped != 2 {
+		t.Fatalf("received block %d with %d dropped, expected block 4 with 2 dropped", event.BlockHeight, event.Dropped)
+	}
+}
@@ -0,0 +1,197 @@
+package dcrlibwallet
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+const (
+	PaymentURIScheme = "decred"
+
+	paymentURIAmountParam  = "amount"
+	paymentURILabelParam   = "label"
+	paymentURIMessageParam = "message"
+
+	// paymentURIRequiredParamPrefix marks params that must be understood
+	// for the payment request to be processed.
+	paymentURIRequiredParamPrefix = "req-"
+)
+
+// PaymentURI is a payment request encoded as a decred: URI, e.g.
+// decred:<address>?amount=1.5&label=Shop&message=Order%2042
+type PaymentURI struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"`
+	Label   string `json:"label"`
+	Message string `json:"message"`
+
+	// UnknownRequiredParams are the names of req- params in the URI that
+	// are not supported. Payment should not be made if any are present.
+	UnknownRequiredParams []string `json:"unknown_required_params"`
+}
+
+// ParsePaymentURI parses a decred: payment URI. An error is returned if the
+// URI is malformed or if its address is not an address of the network of
+// this MultiWallet.
+func (mw *MultiWallet) ParsePaymentURI(uri string) (*PaymentURI, error) {
+	parsedURI, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || !strings.EqualFold(parsedURI.Scheme, PaymentURIScheme) {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	// decred:<address> is parsed as an opaque URI while decred://<address>
+	// is parsed with the address as the host.
+	address := parsedURI.Opaque
+	if address == "" {
+		address = parsedURI.Host
+	}
+
+	if _, err = dcrutil.DecodeAddress(address, mw.chainParams); err != nil {
+		return nil, errors.New(ErrInvalidAddress)
+	}
+
+	params, err := url.ParseQuery(parsedURI.RawQuery)
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	paymentURI := &PaymentURI{
+		Address: address,
+		Label:   params.Get(paymentURILabelParam),
+		Message: params.Get(paymentURIMessageParam),
+	}
+
+	if amount := params.Get(paymentURIAmountParam); amount != "" {
+		paymentURI.Amount, err = parseCoinAmount(amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for param := range params {
+		if strings.HasPrefix(param, paymentURIRequiredParamPrefix) {
+			paymentURI.UnknownRequiredParams = append(paymentURI.UnknownRequiredParams, param)
+		}
+	}
+	sort.Strings(paymentURI.UnknownRequiredParams)
+
+	return paymentURI, nil
+}
+
+// PaymentURI returns a decred: payment URI requesting `amount` atoms to be
+// paid to a new address of `account`. The amount, label and message are
+// left out of the URI if they are not set.
+func (wallet *Wallet) PaymentURI(account int32, amount int64, label, message string) (string, error) {
+	if amount < 0 || amount > MaxAmountAtom {
+		return "", errors.New(ErrInvalid)
+	}
+
+	address, err := wallet.NextAddress(account)
+	if err != nil {
+		return "", err
+	}
+
+	return encodePaymentURI(&PaymentURI{
+		Address: address,
+		Amount:  amount,
+		Label:   label,
+		Message: message,
+	}), nil
+}
+
+func encodePaymentURI(paymentURI *PaymentURI) string {
+	var params []string
+	if paymentURI.Amount > 0 {
+		params = append(params, paymentURIAmountParam+"="+formatCoinAmount(paymentURI.Amount))
+	}
+	if paymentURI.Label != "" {
+		params = append(params, paymentURILabelParam+"="+escapePaymentURIParam(paymentURI.Label))
+	}
+	if paymentURI.Message != "" {
+		params = append(params, paymentURIMessageParam+"="+escapePaymentURIParam(paymentURI.Message))
+	}
+
+	uri := PaymentURIScheme + ":" + paymentURI.Address
+	if len(params) > 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+// escapePaymentURIParam escapes spaces as %20 rather than + as some URI
+// parsers do not decode + in query params.
+func escapePaymentURIParam(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+// AddPaymentURIDestination adds the address and amount of a parsed payment
+// URI as a destination of this transaction. An error is returned if the URI
+// has unknown required params or does not request an amount.
+func (tx *TxAuthor) AddPaymentURIDestination(paymentURI *PaymentURI) error {
+	if len(paymentURI.UnknownRequiredParams) > 0 || paymentURI.Amount <= 0 {
+		return errors.New(ErrInvalid)
+	}
+
+	if !tx.wallet.IsAddressValid(paymentURI.Address) {
+		return errors.New(ErrInvalidAddress)
+	}
+
+	tx.AddSendDestination(paymentURI.Address, paymentURI.Amount, false)
+	return nil
+}
+
+// parseCoinAmount converts a decimal DCR amount to atoms without the
+// rounding errors of parsing the amount as a float.
+func parseCoinAmount(amount string) (int64, error) {
+	whole, fraction := amount, ""
+	if i := strings.IndexByte(amount, '.'); i >= 0 {
+		whole, fraction = amount[:i], amount[i+1:]
+	}
+
+	if (whole == "" && fraction == "") || len(fraction) > 8 ||
+		strings.ContainsAny(whole, "+-") || strings.ContainsAny(fraction, "+-") {
+		return 0, errors.New(ErrInvalid)
+	}
+
+	var atoms int64
+	if whole != "" {
+		coins, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || coins > MaxAmountDcr {
+			return 0, errors.New(ErrInvalid)
+		}
+		atoms = coins * dcrutil.AtomsPerCoin
+	}
+
+	if fraction != "" {
+		fraction += strings.Repeat("0", 8-len(fraction))
+		fractionAtoms, err := strconv.ParseInt(fraction, 10, 64)
+		if err != nil {
+			return 0, errors.New(ErrInvalid)
+		}
+		atoms += fractionAtoms
+	}
+
+	if atoms > MaxAmountAtom {
+		return 0, errors.New(ErrInvalid)
+	}
+	return atoms, nil
+}
+
+// formatCoinAmount formats an amount in atoms as a decimal DCR amount with
+// no trailing zeros.
+func formatCoinAmount(atoms int64) string {
+	whole := strconv.FormatInt(atoms/dcrutil.AtomsPerCoin, 10)
+	fraction := atoms % dcrutil.AtomsPerCoin
+	if fraction == 0 {
+		return whole
+	}
+
+	fractionStr := strconv.FormatInt(fraction, 10)
+	fractionStr = strings.Repeat("0", 8-len(fractionStr)) + fractionStr
+	return whole + "." + strings.TrimRight(fractionStr, "0")
+}
@@ -0,0 +1,206 @@
+package dcrlibwallet
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/raedahgroup/dcrlibwallet/spv"
+)
+
+// spvSyncer returns the syncer of the running SPV sync or an
+// ErrNotConnected error if SPV sync is not running.
+func (mw *MultiWallet) spvSyncer() (*spv.Syncer, error) {
+	mw.syncData.mu.RLock()
+	defer mw.syncData.mu.RUnlock()
+
+	if mw.syncData.spvSyncer == nil {
+		return nil, errors.New(ErrNotConnected)
+	}
+	return mw.syncData.spvSyncer, nil
+}
+
+// Peers returns the peers connected to during SPV sync, in the order they
+// connected. No peers are returned if SPV sync is not running.
+func (mw *MultiWallet) Peers() []*PeerInfo {
+	syncer, err := mw.spvSyncer()
+	if err != nil {
+		return []*PeerInfo{}
+	}
+
+	spvPeers := syncer.Peers()
+	peers := make([]*PeerInfo, len(spvPeers))
+	for i, peer := range spvPeers {
+		peers[i] = &PeerInfo{
+			ID:               int64(peer.ID),
+			Addr:             peer.Addr,
+			UserAgent:        peer.UserAgent,
+			Services:         int64(peer.Services),
+			ProtocolVersion:  int32(peer.ProtocolVersion),
+			InitialHeight:    peer.InitialHeight,
+			ConnectTime:      peer.ConnectTime,
+			BytesSent:        int64(peer.BytesSent),
+			BytesReceived:    int64(peer.BytesReceived),
+			HeadersRoundTrip: peer.HeadersRoundTrip.Nanoseconds() / int64(time.Millisecond),
+		}
+	}
+
+	return peers
+}
+
+func (mw *MultiWallet) PeersJSON() (string, error) {
+	result, err := json.Marshal(mw.Peers())
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// ConnectPeer connects to the peer at `addr` in addition to the peers
+// already connected to. The peer is not reconnected to if the connection is
+// lost or if sync is restarted, use SpvPersistentPeerAddressesConfigKey to
+// always sync with a peer.
+func (mw *MultiWallet) ConnectPeer(addr string) error {
+	peerAddress, err := NormalizeAddress(addr, mw.chainParams.DefaultPort)
+	if err != nil {
+		return errors.New(ErrInvalidPeers)
+	}
+
+	syncer, err := mw.spvSyncer()
+	if err != nil {
+		return err
+	}
+
+	return translateError(syncer.ConnectPeer(peerAddress))
+}
+
+func (mw *MultiWallet) DisconnectPeer(addr string) error {
+	syncer, err := mw.spvSyncer()
+	if err != nil {
+		return err
+	}
+
+	return translateError(syncer.DisconnectPeer(addr))
+}
+
+// BanPeer prevents connecting to the host of `addr` for `durationSeconds`
+// and disconnects the connected peers of the host. Bans are saved and apply
+// to future syncs until they expire or are removed with UnbanPeer. The host
+// must be an IP address, peers are only known by their IP addresses so a
+// ban of a host name would never apply.
+func (mw *MultiWallet) BanPeer(addr string, durationSeconds int64) error {
+	if durationSeconds <= 0 {
+		return errors.New(ErrInvalid)
+	}
+
+	host := peerHost(addr)
+	if host == "" {
+		return errors.New(ErrInvalidPeers)
+	}
+
+	until := time.Now().Add(time.Duration(durationSeconds) * time.Second)
+	err := mw.db.Save(&BannedPeer{
+		Host:        host,
+		BannedUntil: until.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if syncer, err := mw.spvSyncer(); err == nil {
+		syncer.BanPeer(host, until)
+	}
+
+	return nil
+}
+
+func (mw *MultiWallet) UnbanPeer(addr string) error {
+	host := peerHost(addr)
+	if host == "" {
+		// host name bans saved by older versions can still be removed.
+		host = addr
+	}
+	err := mw.db.DeleteStruct(&BannedPeer{Host: host})
+	if err == storm.ErrNotFound {
+		return errors.New(ErrNotExist)
+	} else if err != nil {
+		return err
+	}
+
+	if syncer, err := mw.spvSyncer(); err == nil {
+		syncer.UnbanPeer(host)
+	}
+
+	return nil
+}
+
+// BannedPeers returns the peer hosts with bans that have not expired. Expired
+// bans are deleted.
+func (mw *MultiWallet) BannedPeers() ([]*BannedPeer, error) {
+	var expired []*BannedPeer
+	err := mw.db.Select(q.Lte("BannedUntil", time.Now().Unix())).Find(&expired)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	for _, bannedPeer := range expired {
+		if err = mw.db.DeleteStruct(bannedPeer); err != nil {
+			log.Errorf("Error deleting expired ban of %s: %v", bannedPeer.Host, err)
+		}
+	}
+
+	var bannedPeers []*BannedPeer
+	err = mw.db.Select(q.Gt("BannedUntil", time.Now().Unix())).OrderBy("BannedUntil").Find(&bannedPeers)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	if bannedPeers == nil {
+		bannedPeers = []*BannedPeer{}
+	}
+	return bannedPeers, nil
+}
+
+func (mw *MultiWallet) BannedPeersJSON() (string, error) {
+	bannedPeers, err := mw.BannedPeers()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := json.Marshal(bannedPeers)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// bannedPeers returns the saved bans keyed by host for use by the syncer.
+func (mw *MultiWallet) bannedPeers() (map[string]time.Time, error) {
+	bannedPeers, err := mw.BannedPeers()
+	if err != nil {
+		return nil, err
+	}
+
+	bans := make(map[string]time.Time, len(bannedPeers))
+	for _, bannedPeer := range bannedPeers {
+		bans[bannedPeer.Host] = time.Unix(bannedPeer.BannedUntil, 0)
+	}
+	return bans, nil
+}
+
+// peerHost returns the IP address of a peer address in the format used for
+// the addresses of connected peers. An empty string is returned if the host
+// of the address is not an IP address.
+func peerHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,25 @@
+package dcrlibwallet
+
+import "testing"
+
+func TestPeerHost(t *testing.T) {
+	tests := []struct {
+		addr string
+		host string
+	}{
+		{"127.0.0.1:9108", "127.0.0.1"},
+		{"127.0.0.1", "127.0.0.1"},
+		{"[::1]:9108", "::1"},
+		{"0:0:0:0:0:0:0:1", "::1"},
+		// host names never match the addresses of connected peers.
+		{"mainnet-seed.decred.org:9108", ""},
+		{"localhost", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if host := peerHost(test.addr); host != test.host {
+			t.Errorf("peerHost(%q) = %q, expected %q", test.addr, host, test.host)
+		}
+	}
+}
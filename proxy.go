@@ -0,0 +1,198 @@
+package dcrlibwallet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/decred/dcrd/connmgr/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/decred/go-socks/socks"
+	"github.com/raedahgroup/dcrlibwallet/spv"
+)
+
+// httpRequestTimeout is the timeout of the http clients returned by
+// MultiWallet.HTTPClient and used for the requests made by wallets.
+const httpRequestTimeout = 30 * time.Second
+
+// ProxyConfig is the SOCKS5 proxy that SPV peer connections and http
+// requests are made through. If IsTor is true, host names are resolved
+// through the proxy using Tor's SOCKS extension. Other proxies cannot resolve
+// host names without connecting to them, so SPV sync through such a proxy
+// requires persistent peers with IP addresses and does not use DNS seeding,
+// to avoid leaking lookups outside the proxy. StreamIsolation requires a Tor proxy and uses
+// a separate circuit for each peer connection and for the http requests of
+// each wallet.
+type ProxyConfig struct {
+	Host            string `json:"host"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	IsTor           bool   `json:"is_tor"`
+	StreamIsolation bool   `json:"stream_isolation"`
+}
+
+// SetProxy saves the SOCKS5 proxy that SPV peer connections and http
+// requests are made through. The proxy is used for peer connections the next
+// time sync is started, RestartSpvSync must be called to apply the proxy to a
+// running sync.
+func (mw *MultiWallet) SetProxy(host, username, password string, isTor, streamIsolation bool) error {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return errors.New(ErrInvalid)
+	}
+	if streamIsolation && !isTor {
+		return errors.New(ErrInvalid)
+	}
+
+	return mw.db.Set(userConfigBucketName, ProxyConfigKey, &ProxyConfig{
+		Host:            host,
+		Username:        username,
+		Password:        password,
+		IsTor:           isTor,
+		StreamIsolation: streamIsolation,
+	})
+}
+
+// RemoveProxy stops using the saved proxy. Like SetProxy, this only applies
+// to peer connections the next time sync is started.
+func (mw *MultiWallet) RemoveProxy() {
+	mw.DeleteUserConfigValueForKey(ProxyConfigKey)
+}
+
+// Proxy returns the saved proxy config or nil if no proxy is set.
+func (mw *MultiWallet) Proxy() *ProxyConfig {
+	var proxy ProxyConfig
+	err := mw.ReadUserConfigValue(ProxyConfigKey, &proxy)
+	if err != nil || proxy.Host == "" {
+		return nil
+	}
+	return &proxy
+}
+
+// socksProxy returns the SOCKS5 proxy for the config. If `isolationKey` is
+// set and stream isolation is enabled, connections made with the same key
+// share a circuit and are isolated from other connections.
+func (proxy *ProxyConfig) socksProxy(isolationKey string) *socks.Proxy {
+	socksProxy := &socks.Proxy{
+		Addr:     proxy.Host,
+		Username: proxy.Username,
+		Password: proxy.Password,
+	}
+
+	if proxy.StreamIsolation {
+		if isolationKey != "" {
+			// tor isolates streams that use different SOCKS credentials.
+			socksProxy.Username = isolationKey
+			socksProxy.Password = isolationKey
+		} else {
+			socksProxy.TorIsolation = true
+		}
+	}
+
+	return socksProxy
+}
+
+// lookupIP resolves host names through the proxy if it is a Tor proxy. Other
+// proxies cannot resolve host names, so only IP addresses are accepted.
+func (proxy *ProxyConfig) lookupIP(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	if !proxy.IsTor {
+		return nil, fmt.Errorf("cannot resolve %s without leaking the lookup outside the proxy", host)
+	}
+	return connmgr.TorLookupIP(host, proxy.Host)
+}
+
+// seeder returns a seeder that finds peers by resolving the DNS seeds of the
+// network through the proxy. Must only be used with a Tor proxy.
+func (proxy *ProxyConfig) seeder(mw *MultiWallet) spv.Seeder {
+	return func(ctx context.Context) ([]*wire.NetAddress, error) {
+		port, err := strconv.ParseUint(mw.chainParams.DefaultPort, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		var addrs []*wire.NetAddress
+		for _, seeder := range mw.chainParams.Seeders() {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			ips, err := proxy.lookupIP(seeder)
+			if err != nil {
+				log.Warnf("Failed to resolve DNS seed %s: %v", seeder, err)
+				continue
+			}
+
+			for _, ip := range ips {
+				addrs = append(addrs, wire.NewNetAddressIPPort(ip, uint16(port), wire.SFNodeNetwork))
+			}
+		}
+
+		return addrs, nil
+	}
+}
+
+// checkPeers returns ErrProxyPeersRequired if SPV sync through the proxy
+// would need to resolve host names that the proxy cannot resolve, i.e. if
+// the proxy is not a Tor proxy and `peerAddresses` is empty or has an
+// address that is not an IP address.
+func (proxy *ProxyConfig) checkPeers(peerAddresses []string) error {
+	if proxy.IsTor {
+		return nil
+	}
+	if len(peerAddresses) == 0 {
+		return errors.New(ErrProxyPeersRequired)
+	}
+	for _, addr := range peerAddresses {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) == nil {
+			return errors.New(ErrProxyPeersRequired)
+		}
+	}
+	return nil
+}
+
+// HTTPClient returns an http client that makes requests through the saved
+// proxy, if any, for use by apps making requests that should not bypass the
+// proxy, e.g. requests for exchange rates.
+func (mw *MultiWallet) HTTPClient() *http.Client {
+	return mw.httpClient("")
+}
+
+// httpClient returns an http client that makes requests through the saved
+// proxy, if any. `isolationKey` is used to isolate the requests from other
+// requests if stream isolation is enabled.
+func (mw *MultiWallet) httpClient(isolationKey string) *http.Client {
+	proxy := mw.Proxy()
+	if proxy == nil {
+		return &http.Client{Timeout: httpRequestTimeout}
+	}
+
+	if isolationKey == "" {
+		isolationKey = "multiwallet"
+	}
+	socksProxy := proxy.socksProxy(isolationKey)
+
+	return &http.Client{
+		Timeout: httpRequestTimeout,
+		Transport: &http.Transport{
+			// host names are passed to the proxy to be resolved.
+			DialContext: socksProxy.DialContext,
+		},
+	}
+}
+
+// walletHTTPClientFn returns the function used by the wallet with id
+// `walletID` to get an http client. Requests made by different wallets use
+// different circuits if stream isolation is enabled.
+func (mw *MultiWallet) walletHTTPClientFn(walletID int) httpClientFn {
+	return func() *http.Client {
+		return mw.httpClient(fmt.Sprintf("wallet-%d", walletID))
+	}
+}
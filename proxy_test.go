@@ -0,0 +1,30 @@
+package dcrlibwallet
+
+import "testing"
+
+func TestProxyCheckPeers(t *testing.T) {
+	tests := []struct {
+		isTor bool
+		peers []string
+		valid bool
+	}{
+		{true, nil, true},
+		{true, []string{"mainnet-seed.decred.org:9108"}, true},
+		{false, []string{"127.0.0.1:9108", "[::1]:9108"}, true},
+		// non-Tor proxies cannot resolve the DNS seeds or host names of
+		// peers.
+		{false, nil, false},
+		{false, []string{"127.0.0.1:9108", "mainnet-seed.decred.org:9108"}, false},
+	}
+
+	for i, test := range tests {
+		proxy := &ProxyConfig{Host: "127.0.0.1:9050", IsTor: test.isTor}
+		err := proxy.checkPeers(test.peers)
+		if test.valid && err != nil {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+		if !test.valid && (err == nil || err.Error() != ErrProxyPeersRequired) {
+			t.Errorf("test %d: expected %s, got %v", i, ErrProxyPeersRequired, err)
+		}
+	}
+}
@@ -9,121 +9,229 @@ import (
 	w "github.com/decred/dcrwallet/wallet/v3"
 )
 
+// walletRescan is a running or queued rescan of a wallet. The pointer is
+// used to identify the rescan so that a finished rescan does not remove a
+// newer rescan of the same wallet from mw.syncData.rescans.
+type walletRescan struct {
+	cancel context.CancelFunc
+}
+
 func (mw *MultiWallet) RescanBlocks(walletID int) error {
+	return mw.RescanBlocksFromHeight(walletID, 0)
+}
 
-	wallet := mw.WalletWithID(walletID)
-	if wallet == nil {
-		return errors.E(ErrNotExist)
-	}
+// RescanBlocksFromHeight rescans the blocks of the specified wallet from
+// `startHeight` to the current best block. Only the transactions index
+// for the rescanned blocks is rebuilt after the rescan completes.
+func (mw *MultiWallet) RescanBlocksFromHeight(walletID int, startHeight int32) error {
+	return mw.RescanWalletsFromHeight([]int{walletID}, startHeight, false)
+}
 
-	netBackend, err := wallet.internal.NetworkBackend()
-	if err != nil {
-		return errors.E(ErrNotConnected)
+// RescanWalletsFromHeight queues a rescan from `startHeight` for each of the
+// specified wallets. The wallets are rescanned one after another in the order
+// provided or all at once if `parallel` is true. The rescan of each wallet
+// can be canceled using CancelWalletRescan and progress is reported to the
+// BlocksRescanProgressListener for each wallet.
+func (mw *MultiWallet) RescanWalletsFromHeight(walletIDs []int, startHeight int32, parallel bool) error {
+	if !mw.IsSynced() || startHeight < 0 || len(walletIDs) == 0 {
+		return errors.E(ErrInvalid)
 	}
 
-	if mw.IsRescanning() || !mw.IsSynced() {
-		return errors.E(ErrInvalid)
+	wallets := make([]*Wallet, 0, len(walletIDs))
+	netBackends := make(map[int]w.NetworkBackend, len(walletIDs))
+	for _, walletID := range walletIDs {
+		wallet := mw.WalletWithID(walletID)
+		if wallet == nil {
+			return errors.E(ErrNotExist)
+		}
+
+		if _, queued := netBackends[walletID]; queued || mw.IsWalletRescanning(walletID) {
+			return errors.E(ErrInvalid)
+		}
+
+		if startHeight > wallet.GetBestBlock() {
+			return errors.E(ErrInvalid)
+		}
+
+		netBackend, err := wallet.internal.NetworkBackend()
+		if err != nil {
+			return errors.E(ErrNotConnected)
+		}
+
+		wallets = append(wallets, wallet)
+		netBackends[walletID] = netBackend
 	}
 
-	go func() {
-		defer func() {
-			mw.syncData.mu.Lock()
-			mw.syncData.rescanning = false
-			mw.syncData.cancelRescan = nil
+	// register the wallets as rescanning before starting so that a queued
+	// rescan can be canceled before it starts.
+	mw.syncData.mu.Lock()
+	for _, wallet := range wallets {
+		if _, rescanning := mw.syncData.rescans[wallet.ID]; rescanning {
+			// a rescan of the wallet was started since it was checked above.
 			mw.syncData.mu.Unlock()
+			return errors.E(ErrInvalid)
+		}
+	}
+	contexts := make(map[int]context.Context, len(wallets))
+	rescans := make(map[int]*walletRescan, len(wallets))
+	for _, wallet := range wallets {
+		ctx, cancel := wallet.shutdownContextWithCancel()
+		contexts[wallet.ID] = ctx
+		rescans[wallet.ID] = &walletRescan{cancel: cancel}
+		mw.syncData.rescans[wallet.ID] = rescans[wallet.ID]
+	}
+	mw.syncData.mu.Unlock()
+
+	rescan := func(wallet *Wallet) {
+		mw.rescanWallet(contexts[wallet.ID], rescans[wallet.ID], wallet, netBackends[wallet.ID], startHeight)
+	}
+
+	if parallel {
+		for _, wallet := range wallets {
+			go rescan(wallet)
+		}
+	} else {
+		go func() {
+			for _, wallet := range wallets {
+				rescan(wallet)
+			}
 		}()
+	}
 
-		ctx, cancel := wallet.shutdownContextWithCancel()
+	return nil
+}
+
+func (mw *MultiWallet) rescanWallet(ctx context.Context, rescan *walletRescan, wallet *Wallet, netBackend w.NetworkBackend, startHeight int32) {
+	walletID := wallet.ID
 
+	defer func() {
+		rescan.cancel()
+
+		// the rescan may have been canceled and replaced by a new rescan.
 		mw.syncData.mu.Lock()
-		mw.syncData.rescanning = true
-		mw.syncData.cancelRescan = cancel
+		if mw.syncData.rescans[walletID] == rescan {
+			delete(mw.syncData.rescans, walletID)
+		}
 		mw.syncData.mu.Unlock()
+	}()
 
-		if mw.blocksRescanProgressListener != nil {
-			mw.blocksRescanProgressListener.OnBlocksRescanStarted(walletID)
-		}
+	if ctx.Err() != nil {
+		// rescan was canceled while queued
+		return
+	}
 
-		progress := make(chan w.RescanProgress, 1)
-		go wallet.internal.RescanProgressFromHeight(ctx, netBackend, 0, progress)
+	if mw.blocksRescanProgressListener != nil {
+		mw.blocksRescanProgressListener.OnBlocksRescanStarted(walletID)
+	}
 
-		rescanStartTime := time.Now().Unix()
+	progress := make(chan w.RescanProgress, 1)
+	go wallet.internal.RescanProgressFromHeight(ctx, netBackend, startHeight, progress)
 
-		for p := range progress {
-			if p.Err != nil {
-				log.Error(p.Err)
-				if mw.blocksRescanProgressListener != nil {
-					mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, p.Err)
-				}
-				return
-			}
+	rescanStartTime := time.Now().Unix()
 
-			rescanProgressReport := &HeadersRescanProgressReport{
-				CurrentRescanHeight: p.ScannedThrough,
-				TotalHeadersToScan:  wallet.GetBestBlock(),
-				WalletID:            walletID,
+	for p := range progress {
+		if p.Err != nil {
+			log.Error(p.Err)
+			if mw.blocksRescanProgressListener != nil {
+				mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, p.Err)
 			}
+			return
+		}
 
-			elapsedRescanTime := time.Now().Unix() - rescanStartTime
-			rescanRate := float64(p.ScannedThrough) / float64(rescanProgressReport.TotalHeadersToScan)
+		rescanProgressReport := &HeadersRescanProgressReport{
+			CurrentRescanHeight: p.ScannedThrough,
+			TotalHeadersToScan:  wallet.GetBestBlock(),
+			WalletID:            walletID,
+		}
+
+		elapsedRescanTime := time.Now().Unix() - rescanStartTime
+		rescanRate := float64(1)
+		if headersToScan := rescanProgressReport.TotalHeadersToScan - startHeight; headersToScan > 0 {
+			rescanRate = float64(p.ScannedThrough-startHeight) / float64(headersToScan)
+		}
 
-			rescanProgressReport.RescanProgress = int32(math.Round(rescanRate * 100))
+		rescanProgressReport.RescanProgress = int32(math.Round(rescanRate * 100))
+		if rescanRate > 0 {
 			estimatedTotalRescanTime := int64(math.Round(float64(elapsedRescanTime) / rescanRate))
 			rescanProgressReport.RescanTimeRemaining = estimatedTotalRescanTime - elapsedRescanTime
+		}
 
-			rescanProgressReport.GeneralSyncProgress = &GeneralSyncProgress{
-				TotalSyncProgress:         rescanProgressReport.RescanProgress,
-				TotalTimeRemainingSeconds: rescanProgressReport.RescanTimeRemaining,
-			}
+		rescanProgressReport.GeneralSyncProgress = &GeneralSyncProgress{
+			TotalSyncProgress:         rescanProgressReport.RescanProgress,
+			TotalTimeRemainingSeconds: rescanProgressReport.RescanTimeRemaining,
+		}
 
-			if mw.blocksRescanProgressListener != nil {
-				mw.blocksRescanProgressListener.OnBlocksRescanProgress(rescanProgressReport)
-			}
+		if mw.blocksRescanProgressListener != nil {
+			mw.blocksRescanProgressListener.OnBlocksRescanProgress(rescanProgressReport)
+		}
 
-			select {
-			case <-ctx.Done():
-				log.Info("Rescan canceled through context")
+		select {
+		case <-ctx.Done():
+			log.Infof("[%d] Rescan canceled through context", walletID)
 
-				if mw.blocksRescanProgressListener != nil {
-					if ctx.Err() != nil && ctx.Err() != context.Canceled {
-						mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, ctx.Err())
-					} else {
-						mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, nil)
-					}
+			if mw.blocksRescanProgressListener != nil {
+				if ctx.Err() != nil && ctx.Err() != context.Canceled {
+					mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, ctx.Err())
+				} else {
+					mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, nil)
 				}
-
-				return
-			default:
-				continue
 			}
-		}
 
-		err := wallet.reindexTransactions()
-		if mw.blocksRescanProgressListener != nil {
-			mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, err)
+			return
+		default:
+			continue
 		}
-	}()
+	}
 
-	return nil
+	err := wallet.reindexTransactionsFromHeight(startHeight)
+	if mw.blocksRescanProgressListener != nil {
+		mw.blocksRescanProgressListener.OnBlocksRescanEnded(walletID, err)
+	}
 }
 
+// CancelRescan cancels all running and queued rescans.
 func (mw *MultiWallet) CancelRescan() {
 	mw.syncData.mu.Lock()
 	defer mw.syncData.mu.Unlock()
-	if mw.syncData.cancelRescan != nil {
-		mw.syncData.cancelRescan()
-		mw.syncData.cancelRescan = nil
 
-		log.Info("Rescan canceled.")
+	for walletID, rescan := range mw.syncData.rescans {
+		rescan.cancel()
+		delete(mw.syncData.rescans, walletID)
+
+		log.Infof("[%d] Rescan canceled.", walletID)
+	}
+}
+
+// CancelWalletRescan cancels the running or queued rescan of a wallet.
+func (mw *MultiWallet) CancelWalletRescan(walletID int) {
+	mw.syncData.mu.Lock()
+	defer mw.syncData.mu.Unlock()
+
+	if rescan, ok := mw.syncData.rescans[walletID]; ok {
+		rescan.cancel()
+		delete(mw.syncData.rescans, walletID)
+
+		log.Infof("[%d] Rescan canceled.", walletID)
 	}
 }
 
 func (mw *MultiWallet) IsRescanning() bool {
 	mw.syncData.mu.RLock()
 	defer mw.syncData.mu.RUnlock()
-	return mw.syncData.rescanning
+	return len(mw.syncData.rescans) > 0
+}
+
+// IsWalletRescanning returns true if a rescan is running or queued for the
+// specified wallet.
+func (mw *MultiWallet) IsWalletRescanning(walletID int) bool {
+	mw.syncData.mu.RLock()
+	defer mw.syncData.mu.RUnlock()
+	_, ok := mw.syncData.rescans[walletID]
+	return ok
 }
 
+// SetBlocksRescanProgressListener sets the listener that is called directly
+// with the progress of rescans, rescans are not published to subscribers.
 func (mw *MultiWallet) SetBlocksRescanProgressListener(blocksRescanProgressListener BlocksRescanProgressListener) {
 	mw.blocksRescanProgressListener = blocksRescanProgressListener
 }
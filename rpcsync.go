@@ -0,0 +1,339 @@
+package dcrlibwallet
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/decred/dcrwallet/chain/v3"
+	"github.com/decred/dcrwallet/errors/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// rpcReconnectDelay is the delay before the first attempt to reconnect to
+	// dcrd after the RPC connection is lost. The delay is doubled after every
+	// failed attempt up to rpcMaxReconnectDelay.
+	rpcReconnectDelay    = 5 * time.Second
+	rpcMaxReconnectDelay = 2 * time.Minute
+)
+
+// rpcHeadersFetch aggregates the headers fetch progress of the wallets synced
+// using RPC. Each wallet fetches headers from dcrd using its own connection,
+// progress is reported for the wallet that is furthest behind and headers
+// fetching is finished once every wallet has fetched all headers.
+type rpcHeadersFetch struct {
+	fetchStarted  func(peerInitialHeight int32)
+	fetchProgress func(lastFetchedHeaderHeight int32, lastFetchedHeaderTime int64)
+	fetchFinished func()
+
+	mu sync.Mutex
+	// fetching holds the last header fetched by each wallet that has not
+	// finished fetching headers, keyed by wallet id.
+	fetching map[int]*rpcFetchedHeader
+}
+
+type rpcFetchedHeader struct {
+	height    int32
+	timestamp int64
+}
+
+// newRPCHeadersFetch returns an rpcHeadersFetch for the wallets with the
+// provided ids that reports the aggregated progress to the SPV sync headers
+// fetch handlers of `mw`.
+func newRPCHeadersFetch(mw *MultiWallet, walletIDs []int) *rpcHeadersFetch {
+	fetching := make(map[int]*rpcFetchedHeader, len(walletIDs))
+	for _, walletID := range walletIDs {
+		fetching[walletID] = &rpcFetchedHeader{}
+	}
+
+	return &rpcHeadersFetch{
+		fetchStarted:  mw.fetchHeadersStarted,
+		fetchProgress: mw.fetchHeadersProgress,
+		fetchFinished: mw.fetchHeadersFinished,
+		fetching:      fetching,
+	}
+}
+
+func (f *rpcHeadersFetch) started(walletID int, bestBlockHeight int32) {
+	f.mu.Lock()
+	if _, ok := f.fetching[walletID]; !ok {
+		// the wallet reconnected after it finished fetching headers.
+		f.fetching[walletID] = &rpcFetchedHeader{}
+	}
+	f.mu.Unlock()
+
+	f.fetchStarted(bestBlockHeight)
+}
+
+func (f *rpcHeadersFetch) progress(walletID int, lastFetchedHeaderHeight int32, lastFetchedHeaderTime int64) {
+	f.mu.Lock()
+	if header, ok := f.fetching[walletID]; ok {
+		header.height = lastFetchedHeaderHeight
+		header.timestamp = lastFetchedHeaderTime
+	}
+
+	// report the progress of the wallet that is furthest behind, once all
+	// wallets have fetched some headers.
+	var lowest *rpcFetchedHeader
+	for _, header := range f.fetching {
+		if header.timestamp == 0 {
+			f.mu.Unlock()
+			return
+		}
+		if lowest == nil || header.height < lowest.height {
+			lowest = header
+		}
+	}
+	if lowest == nil {
+		f.mu.Unlock()
+		return
+	}
+	height, timestamp := lowest.height, lowest.timestamp
+	f.mu.Unlock()
+
+	f.fetchProgress(height, timestamp)
+}
+
+func (f *rpcHeadersFetch) finished(walletID int) {
+	f.mu.Lock()
+	_, fetching := f.fetching[walletID]
+	delete(f.fetching, walletID)
+	allFinished := fetching && len(f.fetching) == 0
+	f.mu.Unlock()
+
+	if allFinished {
+		f.fetchFinished()
+	}
+}
+
+// defaultRPCPort returns the default dcrd RPC port for the network.
+func (mw *MultiWallet) defaultRPCPort() string {
+	switch mw.chainParams.Name {
+	case "mainnet":
+		return "9109"
+	case "testnet3":
+		return "19109"
+	case "simnet":
+		return "19556"
+	default:
+		return ""
+	}
+}
+
+// RpcSync syncs all opened wallets using the dcrd node at `host` as the
+// network backend instead of SPV. `cert` is the PEM encoded TLS certificate
+// of the dcrd RPC server, the system certificate pool is used if it is
+// empty. Sync progress is reported to the registered SyncProgressListeners
+// and the connection is retried if it is lost, until CancelSync is called.
+func (mw *MultiWallet) RpcSync(host, user, pass string, cert []byte) error {
+	// prevent an attempt to sync when the previous syncing has not been canceled
+	if mw.IsSyncing() || mw.IsSynced() {
+		return errors.New(ErrSyncAlreadyInProgress)
+	}
+
+	address, err := NormalizeAddress(host, mw.defaultRPCPort())
+	if err != nil {
+		return errors.New(ErrInvalidAddress)
+	}
+
+	rpcOptions := &chain.RPCOptions{
+		Address:     address,
+		DefaultPort: mw.defaultRPCPort(),
+		User:        user,
+		Pass:        pass,
+		CA:          cert,
+	}
+
+	// init activeSyncData to be used to hold data used
+	// to calculate sync estimates only during sync
+	mw.initActiveSyncData()
+
+	var openedWallets []*Wallet
+	var openedWalletIDs []int
+	for _, wallet := range mw.wallets {
+		if wallet.WalletOpened() {
+			wallet.syncStateMu.Lock()
+			wallet.waiting = true
+			wallet.syncing = true
+			wallet.syncStateMu.Unlock()
+			openedWallets = append(openedWallets, wallet)
+			openedWalletIDs = append(openedWalletIDs, wallet.ID)
+		}
+	}
+
+	ctx, cancel := mw.contextWithShutdownCancel()
+
+	var restartSyncRequested bool
+
+	mw.syncData.mu.Lock()
+	restartSyncRequested = mw.syncData.restartSyncRequested
+	mw.syncData.restartSyncRequested = false
+	mw.syncData.syncing = true
+	mw.syncData.cancelSync = cancel
+	mw.syncData.mu.Unlock()
+
+	for _, listener := range mw.syncProgressListeners() {
+		listener.OnSyncStarted(restartSyncRequested)
+	}
+	mw.publishSyncStateChanged()
+
+	// each wallet uses its own RPC connection to dcrd. The number of wallets
+	// connected to dcrd is reported as the number of connected peers.
+	var connectedWallets int32
+	headersFetch := newRPCHeadersFetch(mw, openedWalletIDs)
+
+	// stop syncing the other wallets if syncing any wallet fails with an
+	// error that reconnecting cannot fix.
+	rpcSync, syncCtx := errgroup.WithContext(ctx)
+	for _, wallet := range openedWallets {
+		wallet := wallet
+		rpcSync.Go(func() error {
+			return mw.rpcSyncWallet(syncCtx, wallet, rpcOptions, &connectedWallets, headersFetch)
+		})
+	}
+
+	go func() {
+		syncError := rpcSync.Wait()
+
+		// sync has ended or errored, reset sync variables
+		mw.resetSyncData()
+		mw.handlePeerCountUpdate(0)
+
+		if syncError != nil {
+			if syncError == context.DeadlineExceeded {
+				mw.notifySyncError(errors.Errorf("RPC synchronization deadline exceeded: %v", syncError))
+			} else if syncError == context.Canceled {
+				mw.syncData.syncCanceled <- true
+				mw.notifySyncCanceled()
+			} else {
+				mw.notifySyncError(syncError)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// rpcSyncWallet runs a dcrd RPC syncer for `wallet` until `ctx` is canceled,
+// reconnecting whenever the syncer returns because the connection was lost
+// or could not be established.
+func (mw *MultiWallet) rpcSyncWallet(ctx context.Context, wallet *Wallet, rpcOptions *chain.RPCOptions, connectedWallets *int32,
+	headersFetch *rpcHeadersFetch) error {
+	var connected bool
+	setConnected := func(c bool) {
+		if connected == c {
+			return
+		}
+		connected = c
+
+		var count int32
+		if c {
+			count = atomic.AddInt32(connectedWallets, 1)
+		} else {
+			count = atomic.AddInt32(connectedWallets, -1)
+		}
+		mw.handlePeerCountUpdate(count)
+	}
+
+	reconnectDelay := rpcReconnectDelay
+	for {
+		syncer := chain.NewRPCSyncer(wallet.internal, rpcOptions)
+		syncer.SetCallbacks(mw.rpcSyncCallbacks(wallet, setConnected, headersFetch))
+
+		startTime := time.Now()
+		err := syncer.Run(ctx)
+		if ctx.Err() != nil {
+			setConnected(false)
+			return ctx.Err()
+		}
+
+		// the wallet is no longer synced when the connection is lost.
+		if wallet.IsSynced() {
+			mw.rpcConnectionLost(wallet)
+		}
+		setConnected(false)
+
+		if errors.Is(errors.Passphrase, err) {
+			// wrong RPC credentials, retrying will not help.
+			return err
+		}
+
+		// reset the delay if the previous connection was up for a while.
+		if time.Since(startTime) > rpcMaxReconnectDelay {
+			reconnectDelay = rpcReconnectDelay
+		}
+
+		log.Errorf("[%d] RPC sync with %s ended: %v, reconnecting in %v", wallet.ID, rpcOptions.Address, err, reconnectDelay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reconnectDelay):
+		}
+
+		reconnectDelay *= 2
+		if reconnectDelay > rpcMaxReconnectDelay {
+			reconnectDelay = rpcMaxReconnectDelay
+		}
+	}
+}
+
+// rpcConnectionLost marks `wallet` as syncing again after its RPC connection
+// was lost, so that the sync completes again once the wallet reconnects and
+// is synced.
+func (mw *MultiWallet) rpcConnectionLost(wallet *Wallet) {
+	mw.syncData.mu.Lock()
+	mw.syncData.synced = false
+	mw.syncData.syncing = true
+	mw.syncData.mu.Unlock()
+
+	wallet.syncStateMu.Lock()
+	wallet.synced = false
+	wallet.syncing = true
+	wallet.syncStateMu.Unlock()
+}
+
+// rpcSyncCallbacks maps the callbacks of a dcrd RPC syncer to the same
+// handlers used for SPV sync so that progress is reported the same way.
+// `setConnected` is called with true once the syncer is connected to dcrd.
+// The headers fetch progress of all wallets is aggregated by `headersFetch`.
+func (mw *MultiWallet) rpcSyncCallbacks(wallet *Wallet, setConnected func(bool), headersFetch *rpcHeadersFetch) *chain.Callbacks {
+	walletID := wallet.ID
+	return &chain.Callbacks{
+		Synced: func(synced bool) {
+			mw.synced(walletID, synced)
+		},
+		FetchMissingCFiltersStarted: func() {
+			setConnected(true)
+		},
+		FetchMissingCFiltersProgress: func(startCFiltersHeight, endCFiltersHeight int32) {},
+		FetchMissingCFiltersFinished: func() {},
+		FetchHeadersStarted: func() {
+			setConnected(true)
+			headersFetch.started(walletID, wallet.GetBestBlock())
+		},
+		FetchHeadersProgress: func(lastFetchedHeaderHeight int32, lastFetchedHeaderTime int64) {
+			headersFetch.progress(walletID, lastFetchedHeaderHeight, lastFetchedHeaderTime)
+		},
+		FetchHeadersFinished: func() {
+			headersFetch.finished(walletID)
+		},
+		DiscoverAddressesStarted: func() {
+			mw.discoverAddressesStarted(walletID)
+		},
+		DiscoverAddressesFinished: func() {
+			mw.discoverAddressesFinished(walletID)
+		},
+		RescanStarted: func() {
+			mw.rescanStarted(walletID)
+		},
+		RescanProgress: func(rescannedThrough int32) {
+			mw.rescanProgress(walletID, rescannedThrough)
+		},
+		RescanFinished: func() {
+			mw.rescanFinished(walletID)
+		},
+	}
+}
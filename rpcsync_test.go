@@ -0,0 +1,331 @@
+package dcrlibwallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/gorilla/websocket"
+)
+
+type headersFetchRecorder struct {
+	started  int
+	progress []int32
+	finished int
+}
+
+func newTestRPCHeadersFetch(walletIDs ...int) (*rpcHeadersFetch, *headersFetchRecorder) {
+	recorder := new(headersFetchRecorder)
+	fetching := make(map[int]*rpcFetchedHeader, len(walletIDs))
+	for _, walletID := range walletIDs {
+		fetching[walletID] = &rpcFetchedHeader{}
+	}
+
+	headersFetch := &rpcHeadersFetch{
+		fetchStarted: func(int32) {
+			recorder.started++
+		},
+		fetchProgress: func(lastFetchedHeaderHeight int32, lastFetchedHeaderTime int64) {
+			recorder.progress = append(recorder.progress, lastFetchedHeaderHeight)
+		},
+		fetchFinished: func() {
+			recorder.finished++
+		},
+		fetching: fetching,
+	}
+	return headersFetch, recorder
+}
+
+func TestRPCHeadersFetchProgress(t *testing.T) {
+	headersFetch, recorder := newTestRPCHeadersFetch(1, 2)
+
+	headersFetch.started(1, 0)
+	headersFetch.started(2, 0)
+	if recorder.started != 2 {
+		t.Fatalf("headers fetch started %d times, expected 2", recorder.started)
+	}
+
+	// progress is only reported once every wallet has fetched headers.
+	headersFetch.progress(1, 2000, 1000)
+	if len(recorder.progress) != 0 {
+		t.Fatalf("progress %v reported before all wallets fetched headers", recorder.progress)
+	}
+
+	// the progress of the wallet that is furthest behind is reported.
+	headersFetch.progress(2, 500, 500)
+	headersFetch.progress(1, 4000, 2000)
+	headersFetch.progress(2, 1000, 1000)
+	expected := []int32{500, 500, 1000}
+	if len(recorder.progress) != len(expected) {
+		t.Fatalf("reported progress %v, expected %v", recorder.progress, expected)
+	}
+	for i := range expected {
+		if recorder.progress[i] != expected[i] {
+			t.Fatalf("reported progress %v, expected %v", recorder.progress, expected)
+		}
+	}
+
+	// headers fetching finishes when every wallet finished.
+	headersFetch.finished(1)
+	if recorder.finished != 0 {
+		t.Fatal("headers fetch finished before all wallets finished")
+	}
+	headersFetch.progress(2, 4000, 2000)
+	if last := recorder.progress[len(recorder.progress)-1]; last != 4000 {
+		t.Fatalf("reported progress %d after wallet 1 finished, expected 4000", last)
+	}
+	headersFetch.finished(2)
+	if recorder.finished != 1 {
+		t.Fatalf("headers fetch finished %d times, expected 1", recorder.finished)
+	}
+
+	// finishing again without restarting is ignored.
+	headersFetch.finished(2)
+	if recorder.finished != 1 {
+		t.Fatalf("headers fetch finished %d times, expected 1", recorder.finished)
+	}
+}
+
+// rpcSyncTimeout is how long the RPC sync tests wait for the wallets to sync,
+// which includes the delay before reconnecting.
+const rpcSyncTimeout = 30 * time.Second
+
+// fakeDcrd is a dcrd JSON-RPC websocket server whose chain only has the
+// genesis block.
+type fakeDcrd struct {
+	*httptest.Server
+	t *testing.T
+
+	mu          sync.Mutex
+	conns       []*websocket.Conn
+	connections int
+}
+
+type fakeDcrdRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     json.RawMessage   `json:"id"`
+}
+
+type fakeDcrdResponse struct {
+	Result interface{}     `json:"result"`
+	Error  interface{}     `json:"error"`
+	ID     json.RawMessage `json:"id"`
+}
+
+func newFakeDcrd(t *testing.T) *fakeDcrd {
+	dcrd := &fakeDcrd{t: t}
+	dcrd.Server = httptest.NewTLSServer(http.HandlerFunc(dcrd.serveWebsocket))
+	return dcrd
+}
+
+// cert returns the PEM encoded TLS certificate of the server.
+func (dcrd *fakeDcrd) cert() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: dcrd.Certificate().Raw})
+}
+
+func (dcrd *fakeDcrd) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	dcrd.mu.Lock()
+	dcrd.conns = append(dcrd.conns, conn)
+	dcrd.connections++
+	dcrd.mu.Unlock()
+
+	for {
+		var request fakeDcrdRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			return
+		}
+
+		response := &fakeDcrdResponse{Result: dcrd.result(&request), ID: request.ID}
+		if err := conn.WriteJSON(response); err != nil {
+			return
+		}
+	}
+}
+
+// result returns the result of the request for a chain that only has the
+// genesis block. Requests for notifications and other methods that the
+// syncer does not read a result for return null.
+func (dcrd *fakeDcrd) result(request *fakeDcrdRequest) interface{} {
+	genesisHash := chaincfg.SimNetParams().GenesisHash
+	switch request.Method {
+	case "getcurrentnet":
+		return uint32(wire.SimNet)
+	case "version":
+		return map[string]interface{}{
+			"dcrdjsonrpcapi": map[string]interface{}{"versionstring": "6.1.2", "major": 6, "minor": 1, "patch": 2},
+		}
+	case "getbestblock":
+		return map[string]interface{}{"hash": genesisHash.String(), "height": 0}
+	case "getblockhash":
+		return genesisHash.String()
+	case "getheaders":
+		return map[string]interface{}{"headers": []string{}}
+	case "existsaddresses":
+		// none of the addresses are used.
+		var addresses []string
+		if len(request.Params) > 0 {
+			json.Unmarshal(request.Params[0], &addresses)
+		}
+		return hex.EncodeToString(make([]byte, (len(addresses)+7)/8))
+	case "rescan":
+		return map[string]interface{}{"discovereddata": []interface{}{}}
+	default:
+		return nil
+	}
+}
+
+// disconnect closes every websocket connection to the server.
+func (dcrd *fakeDcrd) disconnect() {
+	dcrd.mu.Lock()
+	defer dcrd.mu.Unlock()
+
+	for _, conn := range dcrd.conns {
+		conn.Close()
+	}
+	dcrd.conns = nil
+}
+
+func (dcrd *fakeDcrd) connectionsCount() int {
+	dcrd.mu.Lock()
+	defer dcrd.mu.Unlock()
+	return dcrd.connections
+}
+
+// rpcSyncListener records the connected peers and completed syncs reported
+// to a SyncProgressListener.
+type rpcSyncListener struct {
+	peers     chan int32
+	completed chan struct{}
+	errors    chan error
+}
+
+func newRPCSyncListener() *rpcSyncListener {
+	return &rpcSyncListener{
+		peers:     make(chan int32, 100),
+		completed: make(chan struct{}, 100),
+		errors:    make(chan error, 100),
+	}
+}
+
+func (l *rpcSyncListener) OnSyncStarted(wasRestarted bool) {}
+func (l *rpcSyncListener) OnPeerConnectedOrDisconnected(numberOfConnectedPeers int32) {
+	l.peers <- numberOfConnectedPeers
+}
+func (l *rpcSyncListener) OnHeadersFetchProgress(*HeadersFetchProgressReport)         {}
+func (l *rpcSyncListener) OnAddressDiscoveryProgress(*AddressDiscoveryProgressReport) {}
+func (l *rpcSyncListener) OnHeadersRescanProgress(*HeadersRescanProgressReport)       {}
+func (l *rpcSyncListener) OnSyncCompleted()                                           { l.completed <- struct{}{} }
+func (l *rpcSyncListener) OnSyncCanceled(willRestart bool)                            {}
+func (l *rpcSyncListener) OnSyncEndedWithError(err error)                             { l.errors <- err }
+func (l *rpcSyncListener) Debug(*DebugInfo)                                           {}
+
+func (l *rpcSyncListener) waitForPeers(t *testing.T, peers int32) {
+	t.Helper()
+
+	timeout := time.After(rpcSyncTimeout)
+	for {
+		select {
+		case connected := <-l.peers:
+			if connected == peers {
+				return
+			}
+		case err := <-l.errors:
+			t.Fatalf("sync failed: %v", err)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %d connected peers", peers)
+		}
+	}
+}
+
+func (l *rpcSyncListener) waitForSyncCompleted(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-l.completed:
+	case err := <-l.errors:
+		t.Fatalf("sync failed: %v", err)
+	case <-time.After(rpcSyncTimeout):
+		t.Fatal("timed out waiting for the sync to complete")
+	}
+}
+
+func TestRpcSync(t *testing.T) {
+	dcrd := newFakeDcrd(t)
+	defer dcrd.Close()
+
+	dir, err := ioutil.TempDir("", "dcrlibwallet-rpcsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mw, err := NewMultiWallet(dir, "", "simnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		if _, err = mw.CreateNewWallet("passphrase", PassphraseTypePass); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listener := newRPCSyncListener()
+	if err = mw.AddSyncProgressListener(listener, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = mw.RpcSync(dcrd.Listener.Addr().String(), "user", "pass", dcrd.cert()); err != nil {
+		t.Fatal(err)
+	}
+	defer mw.CancelSync()
+
+	// each wallet connects to dcrd and uses the connection as its network
+	// backend.
+	listener.waitForPeers(t, 2)
+	listener.waitForSyncCompleted(t)
+	for _, wallet := range mw.wallets {
+		if _, err := wallet.internal.NetworkBackend(); err != nil {
+			t.Fatalf("wallet %d has no network backend: %v", wallet.ID, err)
+		}
+	}
+
+	// the wallets reconnect and sync again after the connections are lost.
+	dcrd.disconnect()
+	listener.waitForPeers(t, 0)
+	if mw.IsSynced() {
+		t.Fatal("wallets are synced after the connections were lost")
+	}
+	listener.waitForPeers(t, 2)
+	listener.waitForSyncCompleted(t)
+	if connections := dcrd.connectionsCount(); connections != 4 {
+		t.Fatalf("dcrd received %d connections, expected 4", connections)
+	}
+	for _, wallet := range mw.wallets {
+		if !wallet.IsSynced() {
+			t.Fatalf("wallet %d did not sync again", wallet.ID)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/decred/dcrd/chaincfg/chainhash"
 	"github.com/decred/dcrd/dcrutil/v2"
@@ -81,10 +82,12 @@ func (wb *WalletBackend) Headers(ctx context.Context, blockLocators []*chainhash
 		if err != nil {
 			return nil, err
 		}
+		start := time.Now()
 		hs, err := rp.Headers(ctx, blockLocators, hashStop)
 		if err != nil {
 			continue
 		}
+		wb.headersReceived(rp, time.Since(start))
 		return hs, nil
 	}
 }
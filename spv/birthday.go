@@ -0,0 +1,95 @@
+package spv
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrwallet/wallet/v3"
+)
+
+// birthdayTimestampMargin is subtracted from birthday timestamps before they
+// are converted to a block height to account for inaccurate clocks and block
+// timestamps.
+const birthdayTimestampMargin = 48 * time.Hour
+
+// Birthday describes the earliest point in the chain that may contain
+// transactions relevant to a wallet. If Height is set, Timestamp is ignored.
+type Birthday struct {
+	Height    int32
+	Timestamp int64
+}
+
+// SetBirthdays sets the birthday of each wallet, keyed by wallet id. Address
+// discovery and rescans performed while catching up start from the birthday
+// block of a wallet instead of the wallet's rescan point when the rescan
+// point is before the birthday. Must be called before Run.
+func (s *Syncer) SetBirthdays(birthdays map[int]*Birthday) {
+	s.walletsMu.Lock()
+	s.birthdays = birthdays
+	s.walletsMu.Unlock()
+}
+
+func (s *Syncer) birthdayHeightResolved(walletID int, height int32) {
+	if s.notifications != nil && s.notifications.BirthdayHeightResolved != nil {
+		s.notifications.BirthdayHeightResolved(walletID, height)
+	}
+}
+
+// birthdayBlock returns the hash and height of the birthday block of the
+// wallet. A nil hash is returned if the wallet has no birthday or if the
+// birthday is after the wallet's main chain tip. Birthday timestamps are
+// converted to a height using the block headers synced by the wallet and the
+// resolved height is saved so that the conversion is only done once.
+func (s *Syncer) birthdayBlock(ctx context.Context, walletID int, w *wallet.Wallet) (*chainhash.Hash, int32, error) {
+	s.walletsMu.RLock()
+	birthday := s.birthdays[walletID]
+	s.walletsMu.RUnlock()
+	if birthday == nil || (birthday.Height <= 0 && birthday.Timestamp <= 0) {
+		return nil, 0, nil
+	}
+
+	_, tipHeight := w.MainChainTip(ctx)
+
+	if birthday.Height <= 0 {
+		timestamp := time.Unix(birthday.Timestamp, 0).Add(-birthdayTimestampMargin)
+
+		// find the first block mined at or after the birthday timestamp.
+		var searchErr error
+		height := sort.Search(int(tipHeight)+1, func(h int) bool {
+			if searchErr != nil {
+				return true
+			}
+			info, err := w.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(int32(h)))
+			if err != nil {
+				searchErr = err
+				return true
+			}
+			return info.Timestamp >= timestamp.Unix()
+		})
+		if searchErr != nil {
+			return nil, 0, searchErr
+		}
+
+		if height > int(tipHeight) {
+			// the birthday is after the synced headers, the tip may not be
+			// up to date yet so do not save this height.
+			return nil, 0, nil
+		}
+
+		birthday.Height = int32(height)
+		s.birthdayHeightResolved(walletID, birthday.Height)
+	}
+
+	if birthday.Height > tipHeight {
+		return nil, 0, nil
+	}
+
+	info, err := w.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(birthday.Height))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &info.Hash, birthday.Height, nil
+}
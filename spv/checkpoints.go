@@ -0,0 +1,463 @@
+package spv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"math/big"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/decred/dcrwallet/p2p/v2"
+	"github.com/decred/dcrwallet/wallet/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// checkpointsMagic begins every serialized checkpoints file.
+	checkpointsMagic = "dcrckpt1"
+
+	// checkpointWorkSize is the size of the serialized cumulative work of a
+	// checkpoint.
+	checkpointWorkSize = 32
+
+	// DefaultCheckpointInterval is the number of blocks between checkpoints.
+	// It matches the maximum number of headers in a headers message so that
+	// the headers between two checkpoints are fetched with a single request.
+	DefaultCheckpointInterval = wire.MaxBlockHeadersPerMsg
+)
+
+// Checkpoint is the hash and cumulative proof of work of a main chain block.
+type Checkpoint struct {
+	Height int32
+	Hash   chainhash.Hash
+
+	// Work is the total work of the chain from the genesis block through
+	// this block.
+	Work *big.Int
+}
+
+// Checkpoints are main chain blocks at every Interval blocks used to
+// validate headers and to fetch the headers between checkpoints from
+// multiple peers at once. The checkpoint at index i is at height
+// (i+1)*Interval.
+type Checkpoints struct {
+	Network     string
+	Interval    int32
+	Checkpoints []Checkpoint
+}
+
+// NewCheckpoints creates checkpoints at every `interval` blocks of
+// `headers`, which must be consecutive main chain headers starting from the
+// genesis block.
+func NewCheckpoints(network string, interval int32, headers []*wire.BlockHeader) (*Checkpoints, error) {
+	if interval <= 0 || len(headers) == 0 || headers[0].Height != 0 {
+		return nil, errors.E(errors.Invalid, "headers must start at the genesis block")
+	}
+
+	checkpoints := &Checkpoints{
+		Network:  network,
+		Interval: interval,
+	}
+
+	work := new(big.Int)
+	var prevHash chainhash.Hash
+	for i, header := range headers {
+		if i > 0 && (header.PrevBlock != prevHash || int(header.Height) != i) {
+			return nil, errors.E(errors.Invalid, errors.Errorf("header %d does not connect to the previous header", i))
+		}
+
+		prevHash = header.BlockHash()
+		work.Add(work, standalone.CalcWork(header.Bits))
+
+		if i > 0 && int32(i)%interval == 0 {
+			checkpoints.Checkpoints = append(checkpoints.Checkpoints, Checkpoint{
+				Height: int32(i),
+				Hash:   prevHash,
+				Work:   new(big.Int).Set(work),
+			})
+		}
+	}
+
+	return checkpoints, nil
+}
+
+// LastCheckpoint returns the highest checkpoint or nil if there are no
+// checkpoints.
+func (c *Checkpoints) LastCheckpoint() *Checkpoint {
+	if len(c.Checkpoints) == 0 {
+		return nil
+	}
+	return &c.Checkpoints[len(c.Checkpoints)-1]
+}
+
+// checkpointAt returns the checkpoint at `height` or nil if there is no
+// checkpoint at the height.
+func (c *Checkpoints) checkpointAt(height int32) *Checkpoint {
+	if height <= 0 || height%c.Interval != 0 {
+		return nil
+	}
+	i := int(height/c.Interval) - 1
+	if i >= len(c.Checkpoints) {
+		return nil
+	}
+	return &c.Checkpoints[i]
+}
+
+// Serialize encodes the checkpoints and signs them with `privKey`:
+//
+//	magic | network length (1 byte) | network | interval (4 bytes)
+//	| count (4 bytes) | count * (hash (32 bytes) | work (32 bytes))
+//	| ed25519 signature of all the preceding bytes (64 bytes)
+//
+// Integers are little endian except for the cumulative work which is big
+// endian.
+func (c *Checkpoints) Serialize(privKey ed25519.PrivateKey) ([]byte, error) {
+	if len(c.Network) > 0xff {
+		return nil, errors.E(errors.Invalid, "network name is too long")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(checkpointsMagic)
+	buf.WriteByte(byte(len(c.Network)))
+	buf.WriteString(c.Network)
+
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(c.Interval))
+	buf.Write(b[:])
+	binary.LittleEndian.PutUint32(b[:], uint32(len(c.Checkpoints)))
+	buf.Write(b[:])
+
+	for _, checkpoint := range c.Checkpoints {
+		workBytes := checkpoint.Work.Bytes()
+		if len(workBytes) > checkpointWorkSize {
+			return nil, errors.E(errors.Invalid, "cumulative work is too large")
+		}
+
+		buf.Write(checkpoint.Hash[:])
+		buf.Write(make([]byte, checkpointWorkSize-len(workBytes)))
+		buf.Write(workBytes)
+	}
+
+	buf.Write(ed25519.Sign(privKey, buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+// ParseCheckpoints decodes checkpoints serialized with Serialize. An error
+// is returned if the checkpoints are not signed by `pubKey` or are not for
+// `network`.
+func ParseCheckpoints(data []byte, pubKey ed25519.PublicKey, network string) (*Checkpoints, error) {
+	if len(pubKey) != ed25519.PublicKeySize {
+		return nil, errors.E(errors.Invalid, "invalid checkpoints public key")
+	}
+
+	if len(data) < len(checkpointsMagic)+1+ed25519.SignatureSize {
+		return nil, errors.E(errors.Encoding, "checkpoints data is too short")
+	}
+	signed, signature := data[:len(data)-ed25519.SignatureSize], data[len(data)-ed25519.SignatureSize:]
+	if !ed25519.Verify(pubKey, signed, signature) {
+		return nil, errors.E(errors.Invalid, "invalid checkpoints signature")
+	}
+
+	r := bytes.NewReader(signed)
+	magic := make([]byte, len(checkpointsMagic))
+	r.Read(magic)
+	if string(magic) != checkpointsMagic {
+		return nil, errors.E(errors.Encoding, "invalid checkpoints file")
+	}
+
+	networkLen, _ := r.ReadByte()
+	networkName := make([]byte, networkLen)
+	var interval, count uint32
+	if n, _ := r.Read(networkName); n != int(networkLen) {
+		return nil, errors.E(errors.Encoding, "checkpoints data is too short")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &interval); err != nil {
+		return nil, errors.E(errors.Encoding, "checkpoints data is too short")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, errors.E(errors.Encoding, "checkpoints data is too short")
+	}
+
+	if string(networkName) != network {
+		return nil, errors.E(errors.Invalid, errors.Errorf("checkpoints are for %s, not %s", networkName, network))
+	}
+	if interval == 0 || interval > 1<<30 || uint64(r.Len()) != uint64(count)*(chainhash.HashSize+checkpointWorkSize) {
+		return nil, errors.E(errors.Encoding, "invalid checkpoints file")
+	}
+
+	checkpoints := &Checkpoints{
+		Network:     network,
+		Interval:    int32(interval),
+		Checkpoints: make([]Checkpoint, count),
+	}
+	work := make([]byte, checkpointWorkSize)
+	for i := range checkpoints.Checkpoints {
+		checkpoint := &checkpoints.Checkpoints[i]
+		checkpoint.Height = int32(i+1) * checkpoints.Interval
+		r.Read(checkpoint.Hash[:])
+		r.Read(work)
+		checkpoint.Work = new(big.Int).SetBytes(work)
+	}
+
+	return checkpoints, nil
+}
+
+// maxCheckpointSegments is the maximum number of segments of headers between
+// checkpoints that are fetched at once during the checkpoint import.
+const maxCheckpointSegments = 8
+
+// checkpointSegment holds the headers and cfilters of the blocks after a
+// checkpoint through the next checkpoint.
+type checkpointSegment struct {
+	checkpoint *Checkpoint
+	nodes      []*wallet.BlockNode
+}
+
+// SetCheckpoints sets the checkpoints used to validate fetched headers and
+// to import the headers between checkpoints from multiple peers in parallel
+// on the first sync. Every header and cfilter through the last checkpoint is
+// still downloaded, the initial sync is only faster by fetching from several
+// peers at once and skipping the difficulty checks of those headers. Must be
+// called before Run.
+func (s *Syncer) SetCheckpoints(checkpoints *Checkpoints) {
+	s.checkpoints = checkpoints
+}
+
+// checkCheckpoints returns a protocol error if any header at a checkpoint
+// height is not the checkpoint block.
+func (s *Syncer) checkCheckpoints(headers []*wire.BlockHeader) error {
+	if s.checkpoints == nil {
+		return nil
+	}
+
+	for _, header := range headers {
+		checkpoint := s.checkpoints.checkpointAt(int32(header.Height))
+		if checkpoint != nil && header.BlockHash() != checkpoint.Hash {
+			return errors.E(errors.Protocol, errors.Errorf("header at height %d "+
+				"does not match checkpoint %v", header.Height, checkpoint.Hash))
+		}
+	}
+	return nil
+}
+
+// importCheckpointHeaders fetches the headers through the last checkpoint
+// that are not yet in the wallets' main chains. The headers between
+// checkpoints are fetched in parallel from the connected peers, checked
+// against the checkpoints and connected to the wallets' main chains in order.
+// Header difficulties are not validated as the headers are committed to by
+// the checkpoints. All the headers and cfilters are downloaded, as they are
+// without checkpoints.
+func (s *Syncer) importCheckpointHeaders(ctx context.Context, rp *p2p.RemotePeer) error {
+	if s.checkpoints == nil || len(s.checkpoints.Checkpoints) == 0 {
+		return nil
+	}
+
+	s.checkpointMu.Lock()
+	defer s.checkpointMu.Unlock()
+
+	lastCheckpoint := s.checkpoints.LastCheckpoint()
+	_, tipHeight, lowestChainWallet := s.lowestChainTip(ctx)
+	if tipHeight >= lastCheckpoint.Height || rp.InitialHeight() < lastCheckpoint.Height {
+		return nil
+	}
+
+	// start from the last checkpoint in the main chain of the wallet with the
+	// lowest tip.
+	startHeight := tipHeight - tipHeight%s.checkpoints.Interval
+	startHash := s.chainParams.GenesisHash
+	startWork := standalone.CalcWork(s.chainParams.GenesisBlock.Header.Bits)
+	if startHeight > 0 {
+		info, err := lowestChainWallet.BlockInfo(ctx, wallet.NewBlockIdentifierFromHeight(startHeight))
+		if err != nil {
+			return err
+		}
+		checkpoint := s.checkpoints.checkpointAt(startHeight)
+		if info.Hash != checkpoint.Hash {
+			return errors.E(errors.Invalid, errors.Errorf("main chain block at "+
+				"height %d does not match checkpoint %v", startHeight, checkpoint.Hash))
+		}
+		startHash, startWork = checkpoint.Hash, checkpoint.Work
+	}
+
+	log.Infof("Importing headers from height %d through checkpoint at height %d",
+		startHeight, lastCheckpoint.Height)
+
+	next := int(startHeight / s.checkpoints.Interval)
+	for next < len(s.checkpoints.Checkpoints) {
+		remotes := s.checkpointRemotes(rp)
+
+		n := len(s.checkpoints.Checkpoints) - next
+		if n > maxCheckpointSegments {
+			n = maxCheckpointSegments
+		}
+		segments := make([]*checkpointSegment, n)
+
+		g, gctx := errgroup.WithContext(ctx)
+		for i := range segments {
+			i := i
+			prevHash, prevWork := startHash, startWork
+			if next+i > 0 {
+				prevCheckpoint := &s.checkpoints.Checkpoints[next+i-1]
+				prevHash, prevWork = prevCheckpoint.Hash, prevCheckpoint.Work
+			}
+			checkpoint := &s.checkpoints.Checkpoints[next+i]
+			remote := remotes[i%len(remotes)]
+
+			g.Go(func() error {
+				segment, err := s.fetchCheckpointSegment(gctx, remote, prevHash, prevWork, checkpoint)
+				if err != nil {
+					if errors.Is(err, errors.Protocol) && remote != rp {
+						remote.Disconnect(err)
+					}
+					return err
+				}
+				segments[i] = segment
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+
+		for _, segment := range segments {
+			if err := s.connectCheckpointSegment(ctx, segment); err != nil {
+				return err
+			}
+			s.fetchHeadersProgress(segment.nodes[len(segment.nodes)-1].Header)
+		}
+
+		next += n
+	}
+
+	// the wallets' main chains have changed, fetch the remaining headers
+	// using new locators.
+	s.locatorMu.Lock()
+	s.currentLocators = nil
+	s.locatorGeneration++
+	s.locatorMu.Unlock()
+
+	log.Infof("Imported headers through checkpoint at height %d", lastCheckpoint.Height)
+	return nil
+}
+
+// checkpointRemotes returns the connected peers that advertised a height at
+// or above the last checkpoint. rp is always included.
+func (s *Syncer) checkpointRemotes(rp *p2p.RemotePeer) []*p2p.RemotePeer {
+	lastHeight := s.checkpoints.LastCheckpoint().Height
+
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	remotes := []*p2p.RemotePeer{rp}
+	for _, remote := range s.remotes {
+		if remote != rp && remote.InitialHeight() >= lastHeight {
+			remotes = append(remotes, remote)
+		}
+	}
+	return remotes
+}
+
+// fetchCheckpointSegment fetches the headers and cfilters of the blocks after
+// the block `prevHash` through `checkpoint` from rp. The headers must connect
+// to `prevHash`, end at the checkpoint and have the cumulative work of the
+// checkpoint when added to `prevWork`.
+func (s *Syncer) fetchCheckpointSegment(ctx context.Context, rp *p2p.RemotePeer, prevHash chainhash.Hash,
+	prevWork *big.Int, checkpoint *Checkpoint) (*checkpointSegment, error) {
+
+	startHeight := checkpoint.Height - s.checkpoints.Interval + 1
+	headers := make([]*wire.BlockHeader, 0, s.checkpoints.Interval)
+	hashes := make([]*chainhash.Hash, 0, s.checkpoints.Interval)
+	work := new(big.Int).Set(prevWork)
+
+	for len(headers) < int(s.checkpoints.Interval) {
+		locator := prevHash
+		start := time.Now()
+		fetched, err := rp.Headers(ctx, []*chainhash.Hash{&locator}, &checkpoint.Hash)
+		if err != nil {
+			return nil, err
+		}
+		s.headersReceived(rp, time.Since(start))
+
+		if len(fetched) == 0 || len(headers)+len(fetched) > int(s.checkpoints.Interval) {
+			return nil, errors.E(errors.Protocol, errors.Errorf("peer did not "+
+				"provide headers through checkpoint at height %d", checkpoint.Height))
+		}
+
+		for _, header := range fetched {
+			height := startHeight + int32(len(headers))
+			if header.PrevBlock != prevHash || int32(header.Height) != height {
+				return nil, errors.E(errors.Protocol, errors.Errorf("header at "+
+					"height %d does not connect to the previous header", height))
+			}
+
+			prevHash = header.BlockHash()
+			hash := prevHash
+			headers = append(headers, header)
+			hashes = append(hashes, &hash)
+			work.Add(work, standalone.CalcWork(header.Bits))
+		}
+	}
+
+	if prevHash != checkpoint.Hash || work.Cmp(checkpoint.Work) != 0 {
+		return nil, errors.E(errors.Protocol, errors.Errorf("headers do not "+
+			"match checkpoint at height %d", checkpoint.Height))
+	}
+
+	filters, err := rp.CFilters(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*wallet.BlockNode, len(headers))
+	for i, header := range headers {
+		nodes[i] = wallet.NewBlockNode(header, hashes[i], filters[i])
+	}
+
+	log.Debugf("Fetched %d headers ending at checkpoint height %d from %v",
+		len(nodes), checkpoint.Height, rp)
+
+	return &checkpointSegment{checkpoint: checkpoint, nodes: nodes}, nil
+}
+
+// connectCheckpointSegment connects the blocks of a segment that are not in
+// the main chain of each wallet.
+func (s *Syncer) connectCheckpointSegment(ctx context.Context, segment *checkpointSegment) error {
+	s.sidechainMu.Lock()
+	defer s.sidechainMu.Unlock()
+
+	for walletID, w := range s.walletsSnapshot(false) {
+		for _, n := range segment.nodes {
+			haveBlock, _, _ := w.BlockInMainChain(ctx, n.Hash)
+			if !haveBlock {
+				s.sidechains.AddBlockNode(n)
+			}
+		}
+
+		bestChain, err := w.EvaluateBestChain(ctx, &s.sidechains)
+		if err != nil {
+			return err
+		}
+		if len(bestChain) == 0 {
+			continue
+		}
+
+		prevChain, err := w.ChainSwitch(ctx, &s.sidechains, bestChain, nil)
+		if err != nil {
+			return err
+		}
+		for _, n := range prevChain {
+			s.sidechains.AddBlockNode(n)
+		}
+
+		tip := bestChain[len(bestChain)-1]
+		log.Infof("[%d] Connected %d blocks through checkpoint, new tip %v, height %d, date %v",
+			walletID, len(bestChain), tip.Hash, tip.Header.Height, tip.Header.Timestamp)
+	}
+
+	return nil
+}
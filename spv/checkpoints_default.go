@@ -0,0 +1,37 @@
+package spv
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+//go:generate go run ./cmd/gencheckpoints -net mainnet -headers mainnet.headers -key checkpoints.key -gofile checkpoints_mainnet.go
+//go:generate go run ./cmd/gencheckpoints -net testnet3 -headers testnet3.headers -key checkpoints.key -gofile checkpoints_testnet3.go
+
+// CheckpointsPubKey is the hex encoded ed25519 public key the default
+// checkpoints of each network are signed with. No key and no checkpoints
+// files are bundled yet, so there are no default checkpoints until they are
+// generated with cmd/gencheckpoints and the key is set here.
+const CheckpointsPubKey = ""
+
+// defaultCheckpoints holds the signed checkpoints files bundled with this
+// package, keyed by network name. The file of each network is added by the
+// checkpoints_<network>.go file generated by cmd/gencheckpoints.
+var defaultCheckpoints = make(map[string][]byte)
+
+// DefaultCheckpoints returns the checkpoints bundled for `network`, or nil if
+// no checkpoints are bundled for the network.
+func DefaultCheckpoints(network string) (*Checkpoints, error) {
+	data, ok := defaultCheckpoints[network]
+	if !ok || CheckpointsPubKey == "" {
+		return nil, nil
+	}
+
+	pubKey, err := hex.DecodeString(CheckpointsPubKey)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return nil, errors.E(errors.Invalid, "invalid checkpoints public key")
+	}
+	return ParseCheckpoints(data, pubKey, network)
+}
@@ -0,0 +1,136 @@
+package spv
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/wire"
+)
+
+// testHeaders returns `n` connected headers starting at the simnet genesis
+// block.
+func testHeaders(n int) []*wire.BlockHeader {
+	genesis := chaincfg.SimNetParams().GenesisBlock.Header
+	headers := []*wire.BlockHeader{&genesis}
+	for len(headers) < n {
+		prev := headers[len(headers)-1]
+		header := *prev
+		header.PrevBlock = prev.BlockHash()
+		header.Height = prev.Height + 1
+		header.Nonce = uint32(len(headers))
+		headers = append(headers, &header)
+	}
+	return headers
+}
+
+func testCheckpoints(t *testing.T) (*Checkpoints, ed25519.PublicKey, []byte) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints, err := NewCheckpoints("simnet", 10, testHeaders(35))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := checkpoints.Serialize(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checkpoints, pubKey, data
+}
+
+func TestNewCheckpoints(t *testing.T) {
+	headers := testHeaders(35)
+	checkpoints, err := NewCheckpoints("simnet", 10, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(checkpoints.Checkpoints) != 3 {
+		t.Fatalf("created %d checkpoints, expected 3", len(checkpoints.Checkpoints))
+	}
+	// all test headers have the same difficulty.
+	blockWork := standalone.CalcWork(headers[0].Bits)
+	for i, checkpoint := range checkpoints.Checkpoints {
+		height := int32(i+1) * 10
+		if checkpoint.Height != height || checkpoint.Hash != headers[height].BlockHash() {
+			t.Errorf("checkpoint %d is not the block at height %d", i, height)
+		}
+		work := new(big.Int).Mul(blockWork, big.NewInt(int64(height+1)))
+		if checkpoint.Work.Cmp(work) != 0 {
+			t.Errorf("checkpoint %d has work %v, expected %v", i, checkpoint.Work, work)
+		}
+	}
+
+	headers[20].PrevBlock = headers[18].BlockHash()
+	if _, err = NewCheckpoints("simnet", 10, headers); err == nil {
+		t.Fatal("created checkpoints of headers that do not connect")
+	}
+}
+
+func TestParseCheckpoints(t *testing.T) {
+	checkpoints, pubKey, data := testCheckpoints(t)
+
+	parsed, err := ParseCheckpoints(data, pubKey, "simnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Network != checkpoints.Network || parsed.Interval != checkpoints.Interval ||
+		len(parsed.Checkpoints) != len(checkpoints.Checkpoints) {
+		t.Fatalf("parsed %+v, expected %+v", parsed, checkpoints)
+	}
+	for i, checkpoint := range parsed.Checkpoints {
+		expected := checkpoints.Checkpoints[i]
+		if checkpoint.Height != expected.Height || checkpoint.Hash != expected.Hash ||
+			checkpoint.Work.Cmp(expected.Work) != 0 {
+			t.Errorf("parsed checkpoint %d %+v, expected %+v", i, checkpoint, expected)
+		}
+	}
+}
+
+func TestParseTamperedCheckpoints(t *testing.T) {
+	_, pubKey, data := testCheckpoints(t)
+	otherPubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every byte of the file is covered by the signature.
+	for i := range data {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 1
+		if _, err := ParseCheckpoints(tampered, pubKey, "simnet"); err == nil {
+			t.Fatalf("parsed checkpoints with byte %d modified", i)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		pubKey  ed25519.PublicKey
+		network string
+	}{
+		{"other key", data, otherPubKey, "simnet"},
+		{"invalid key", data, pubKey[:16], "simnet"},
+		{"other network", data, pubKey, "mainnet"},
+		{"truncated", data[:len(data)-1], pubKey, "simnet"},
+		{"empty", nil, pubKey, "simnet"},
+	}
+	for _, test := range tests {
+		if _, err := ParseCheckpoints(test.data, test.pubKey, test.network); err == nil {
+			t.Errorf("%s: parsed invalid checkpoints", test.name)
+		}
+	}
+}
+
+func TestDefaultCheckpoints(t *testing.T) {
+	checkpoints, err := DefaultCheckpoints("simnet")
+	if err != nil || checkpoints != nil {
+		t.Fatalf("expected no default simnet checkpoints, got %v, %v", checkpoints, err)
+	}
+}
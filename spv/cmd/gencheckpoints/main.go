@@ -0,0 +1,251 @@
+// gencheckpoints generates the signed header checkpoints file used by the SPV
+// syncer from the main chain headers of a network.
+//
+// The headers file must contain the consecutive main chain headers from the
+// genesis block, either as serialized headers or, with -hex, as one hex
+// encoded header per line, e.g. as output by `dcrctl getblockheader <hash>
+// false`. Headers after the last checkpoint are ignored.
+//
+// A signing key is created with -genkey:
+//
+//	gencheckpoints -genkey -key checkpoints.key
+//
+// and checkpoints are generated with:
+//
+//	gencheckpoints -net mainnet -headers headers.dat -key checkpoints.key -out mainnet.checkpoints
+//
+// With -gofile, the checkpoints are written as a Go source file of the spv
+// package that bundles them as the default checkpoints of the network, see
+// the go:generate directives of the spv package. The public key of the
+// signing key must be set as spv.CheckpointsPubKey.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/raedahgroup/dcrlibwallet/spv"
+)
+
+var (
+	network     = flag.String("net", "mainnet", "network of the headers: mainnet, testnet3, simnet or regnet")
+	headersFile = flag.String("headers", "", "file of the main chain headers from the genesis block")
+	hexHeaders  = flag.Bool("hex", false, "headers file has one hex encoded header per line")
+	interval    = flag.Int("interval", spv.DefaultCheckpointInterval, "number of blocks between checkpoints")
+	keyFile     = flag.String("key", "", "file of the hex encoded ed25519 private key to sign the checkpoints with")
+	genKey      = flag.Bool("genkey", false, "generate a new private key, write it to -key and print the public key")
+	outFile     = flag.String("out", "", "file to write the checkpoints to")
+	goFile      = flag.String("gofile", "", "go source file of the spv package to write the checkpoints to as the default checkpoints of the network")
+)
+
+func main() {
+	flag.Parse()
+
+	var err error
+	if *genKey {
+		err = generateKey()
+	} else {
+		err = generateCheckpoints()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func generateKey() error {
+	if *keyFile == "" {
+		return fmt.Errorf("-key is required")
+	}
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	// the key file must not overwrite an existing key.
+	f, err := os.OpenFile(*keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = fmt.Fprintln(f, hex.EncodeToString(privKey)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Public key: %x\n", pubKey)
+	return nil
+}
+
+func generateCheckpoints() error {
+	if *headersFile == "" || *keyFile == "" || (*outFile == "" && *goFile == "") {
+		return fmt.Errorf("-headers, -key and -out or -gofile are required")
+	}
+
+	params, err := chainParams(*network)
+	if err != nil {
+		return err
+	}
+
+	privKey, err := readKey(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	headers, err := readHeaders(*headersFile, *hexHeaders)
+	if err != nil {
+		return err
+	}
+	if len(headers) == 0 || headers[0].BlockHash() != params.GenesisHash {
+		return fmt.Errorf("headers do not start at the %s genesis block", params.Name)
+	}
+
+	checkpoints, err := spv.NewCheckpoints(params.Name, int32(*interval), headers)
+	if err != nil {
+		return err
+	}
+	lastCheckpoint := checkpoints.LastCheckpoint()
+	if lastCheckpoint == nil {
+		return fmt.Errorf("%d headers are not enough for a checkpoint every %d blocks", len(headers), *interval)
+	}
+
+	data, err := checkpoints.Serialize(privKey)
+	if err != nil {
+		return err
+	}
+
+	// ensure the checkpoints can be parsed with the public key.
+	if _, err = spv.ParseCheckpoints(data, privKey.Public().(ed25519.PublicKey), params.Name); err != nil {
+		return err
+	}
+
+	for _, out := range []struct {
+		path string
+		data []byte
+	}{
+		{*outFile, data},
+		{*goFile, goSource(params.Name, data)},
+	} {
+		if out.path == "" {
+			continue
+		}
+		if err = ioutil.WriteFile(out.path, out.data, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d %s checkpoints through height %d (%v) to %s\n", len(checkpoints.Checkpoints),
+			params.Name, lastCheckpoint.Height, lastCheckpoint.Hash, out.path)
+	}
+
+	fmt.Printf("Public key: %x\n", privKey.Public())
+	return nil
+}
+
+// goSource returns a Go source file of the spv package that adds `data` to
+// the default checkpoints of `network`.
+func goSource(network string, data []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gencheckpoints. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package spv\n\n")
+	fmt.Fprintf(&buf, "import \"encoding/hex\"\n\n")
+	fmt.Fprintf(&buf, "func init() {\n")
+	fmt.Fprintf(&buf, "\tdata, err := hex.DecodeString(\"\" +\n")
+
+	encoded := hex.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 128
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		fmt.Fprintf(&buf, "\t\t\"%s\" +\n", encoded[:n])
+		encoded = encoded[n:]
+	}
+	fmt.Fprintf(&buf, "\t\t\"\")\n")
+
+	fmt.Fprintf(&buf, "\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	fmt.Fprintf(&buf, "\tdefaultCheckpoints[%q] = data\n", network)
+	fmt.Fprintf(&buf, "}\n")
+	return buf.Bytes()
+}
+
+func chainParams(network string) (*chaincfg.Params, error) {
+	switch strings.ToLower(network) {
+	case "mainnet":
+		return chaincfg.MainNetParams(), nil
+	case "testnet", "testnet3":
+		return chaincfg.TestNet3Params(), nil
+	case "simnet":
+		return chaincfg.SimNetParams(), nil
+	case "regnet":
+		return chaincfg.RegNetParams(), nil
+	default:
+		return nil, fmt.Errorf("unknown network %s", network)
+	}
+}
+
+func readKey(path string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%s is not a hex encoded ed25519 private key", path)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+func readHeaders(path string, isHex bool) ([]*wire.BlockHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var headers []*wire.BlockHeader
+
+	if isHex {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			b, err := hex.DecodeString(line)
+			if err != nil {
+				return nil, fmt.Errorf("header %d: %v", len(headers), err)
+			}
+			header := new(wire.BlockHeader)
+			if err = header.Deserialize(bytes.NewReader(b)); err != nil {
+				return nil, fmt.Errorf("header %d: %v", len(headers), err)
+			}
+			headers = append(headers, header)
+		}
+		return headers, scanner.Err()
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		header := new(wire.BlockHeader)
+		err := header.Deserialize(r)
+		if err == io.EOF {
+			return headers, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("header %d: %v", len(headers), err)
+		}
+		headers = append(headers, header)
+	}
+}
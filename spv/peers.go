@@ -0,0 +1,348 @@
+package spv
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/decred/dcrd/addrmgr"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/decred/dcrwallet/p2p/v2"
+)
+
+// PeerInfo describes a connected remote peer.
+type PeerInfo struct {
+	ID              uint64
+	Addr            string
+	UserAgent       string
+	Services        uint64
+	ProtocolVersion uint32
+	InitialHeight   int32
+
+	// ConnectTime is the unix timestamp of when the peer connected.
+	ConnectTime int64
+
+	BytesSent     uint64
+	BytesReceived uint64
+
+	// HeadersRoundTrip is the time taken by the peer to respond to the last
+	// headers request made to it, zero if no headers have been requested
+	// from the peer. It includes the time taken to send the headers and is
+	// not the latency measured by wire ping messages, which is not exposed
+	// by the p2p package.
+	HeadersRoundTrip time.Duration
+}
+
+type remoteStats struct {
+	connectTime      time.Time
+	headersRoundTrip time.Duration
+}
+
+// countingConn counts the bytes read from and written to a connection.
+type countingConn struct {
+	// atomics
+	bytesSent     uint64
+	bytesReceived uint64
+
+	net.Conn
+	closeOnce sync.Once
+	onClose   func()
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.bytesReceived, uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.bytesSent, uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Close() error {
+	c.closeOnce.Do(c.onClose)
+	return c.Conn.Close()
+}
+
+// DialFunc connects to the address `addr` on the named network.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Seeder returns the addresses of peers to connect to.
+type Seeder func(ctx context.Context) ([]*wire.NetAddress, error)
+
+// dial is the dial function of the local peer. Connections are made using
+// the dial function set with SetDialFunc, if any, and are tracked to report
+// the bytes sent to and received from each peer.
+func (s *Syncer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dial := s.dialer
+	if dial == nil {
+		dial = new(net.Dialer).DialContext
+	}
+
+	conn, err := dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	key := conn.RemoteAddr().String()
+	c := &countingConn{Conn: conn}
+	c.onClose = func() {
+		s.connsMu.Lock()
+		if s.conns[key] == c {
+			delete(s.conns, key)
+		}
+		s.connsMu.Unlock()
+	}
+
+	s.connsMu.Lock()
+	s.conns[key] = c
+	s.connsMu.Unlock()
+
+	return c, nil
+}
+
+// seed adds the addresses returned by the seeder to the address manager.
+func (s *Syncer) seed(ctx context.Context) {
+	addrs, err := s.seeder(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warnf("Failed to seed peer addresses: %v", err)
+		}
+		return
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	log.Infof("Seeder returned %d peer addresses", len(addrs))
+	s.lp.AddrManager().AddAddresses(addrs, addrs[0])
+}
+
+// addRemote adds a connected peer and returns the number of connected peers.
+func (s *Syncer) addRemote(k string, rp *p2p.RemotePeer) int {
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	delete(s.connectingRemotes, k)
+	s.remotes[k] = rp
+	s.remoteStats[rp] = &remoteStats{connectTime: time.Now()}
+	return len(s.remotes)
+}
+
+// removeRemote removes a disconnected peer and returns the number of
+// connected peers.
+func (s *Syncer) removeRemote(k string, rp *p2p.RemotePeer) int {
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	if s.remotes[k] == rp {
+		delete(s.remotes, k)
+	}
+	delete(s.remoteStats, rp)
+	return len(s.remotes)
+}
+
+// headersReceived records the round trip time of a headers request made to
+// rp.
+func (s *Syncer) headersReceived(rp *p2p.RemotePeer, roundTrip time.Duration) {
+	s.remotesMu.Lock()
+	if stats, ok := s.remoteStats[rp]; ok {
+		stats.headersRoundTrip = roundTrip
+	}
+	s.remotesMu.Unlock()
+}
+
+// Peers returns information about the connected peers, in the order they
+// connected.
+func (s *Syncer) Peers() []*PeerInfo {
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	peers := make([]*PeerInfo, 0, len(s.remotes))
+	for k, rp := range s.remotes {
+		peer := &PeerInfo{
+			ID:              rp.ID(),
+			Addr:            k,
+			UserAgent:       rp.UA(),
+			Services:        uint64(rp.Services()),
+			ProtocolVersion: rp.Pver(),
+			InitialHeight:   rp.InitialHeight(),
+		}
+
+		if stats, ok := s.remoteStats[rp]; ok {
+			peer.ConnectTime = stats.connectTime.Unix()
+			peer.HeadersRoundTrip = stats.headersRoundTrip
+		}
+
+		s.connsMu.Lock()
+		if conn, ok := s.conns[rp.RemoteAddr().String()]; ok {
+			peer.BytesSent = atomic.LoadUint64(&conn.bytesSent)
+			peer.BytesReceived = atomic.LoadUint64(&conn.bytesReceived)
+		}
+		s.connsMu.Unlock()
+
+		peers = append(peers, peer)
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ConnectTime < peers[j].ConnectTime
+	})
+
+	return peers
+}
+
+// ConnectPeer connects to the peer at `addr` in the background. The peer is
+// not reconnected to if the connection is lost. The syncer must be running.
+func (s *Syncer) ConnectPeer(addr string) error {
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	if s.runCtx == nil {
+		return errors.E(errors.Invalid, "syncer is not running")
+	}
+	if s.isBanned(peerHost(addr)) {
+		return errors.E(errors.Invalid, "peer is banned")
+	}
+	_, isConnecting := s.connectingRemotes[addr]
+	_, isRemote := s.remotes[addr]
+	if isConnecting || isRemote {
+		return errors.E(errors.Exist, "peer is already connected")
+	}
+
+	s.connectingRemotes[addr] = struct{}{}
+	s.manualPeers.Add(1)
+	go s.connectToPeer(s.runCtx, addr)
+
+	return nil
+}
+
+func (s *Syncer) connectToPeer(ctx context.Context, raddr string) {
+	defer s.manualPeers.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rp, err := s.lp.ConnectOutbound(ctx, raddr, reqSvcs)
+	if err != nil {
+		s.remotesMu.Lock()
+		delete(s.connectingRemotes, raddr)
+		s.remotesMu.Unlock()
+		if ctx.Err() == nil {
+			log.Warnf("Peering attempt failed: %v", err)
+		}
+		return
+	}
+	log.Infof("New peer %v %v %v", raddr, rp.UA(), rp.Services())
+
+	s.remotesMu.Lock()
+	delete(s.connectingRemotes, raddr)
+	s.remotesMu.Unlock()
+
+	k := addrmgr.NetAddressKey(rp.NA())
+	n := s.addRemote(k, rp)
+	s.peerConnected(n, k)
+
+	wait := make(chan struct{})
+	go func() {
+		err := s.startupSync(ctx, rp)
+		if err != nil {
+			rp.Disconnect(err)
+		}
+		wait <- struct{}{}
+	}()
+
+	err = rp.Err()
+	if ctx.Err() != context.Canceled {
+		log.Warnf("Lost peer %v: %v", raddr, err)
+	}
+
+	<-wait
+	n = s.removeRemote(k, rp)
+	s.peerDisconnected(n, k)
+}
+
+// DisconnectPeer disconnects the connected peer at `addr`. Peers found
+// through peer discovery are replaced with other peers and persistent peers
+// are reconnected to.
+func (s *Syncer) DisconnectPeer(addr string) error {
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	for k, rp := range s.remotes {
+		if k == addr || rp.RemoteAddr().String() == addr {
+			rp.Disconnect(errors.E("peer disconnected by user"))
+			return nil
+		}
+	}
+
+	return errors.E(errors.NotExist, "peer is not connected")
+}
+
+// SetBannedPeers sets the IP addresses of banned peers and the time their
+// bans expire. Banned peers are not connected to. Must be called before Run.
+func (s *Syncer) SetBannedPeers(bans map[string]time.Time) {
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	s.bannedPeers = make(map[string]time.Time, len(bans))
+	for host, until := range bans {
+		s.bannedPeers[host] = until
+	}
+}
+
+// BanPeer bans the host of `addr` until `until` and disconnects the
+// connected peers of the host. The host must be an IP address as bans are
+// matched against the IP addresses of peers.
+func (s *Syncer) BanPeer(addr string, until time.Time) {
+	host := peerHost(addr)
+
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	s.bannedPeers[host] = until
+	for _, rp := range s.remotes {
+		if peerHost(rp.RemoteAddr().String()) == host {
+			rp.Disconnect(errors.E("peer banned by user"))
+		}
+	}
+}
+
+// UnbanPeer removes the ban of the host of `addr`.
+func (s *Syncer) UnbanPeer(addr string) {
+	s.remotesMu.Lock()
+	delete(s.bannedPeers, peerHost(addr))
+	s.remotesMu.Unlock()
+}
+
+// isBanned must be called with s.remotesMu held.
+func (s *Syncer) isBanned(host string) bool {
+	until, ok := s.bannedPeers[host]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(s.bannedPeers, host)
+		return false
+	}
+	return true
+}
+
+// peerHost returns the host of a peer address, or the address if it has no
+// port. IP addresses are returned in their canonical form so that different
+// forms of the same address match.
+func peerHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
@@ -0,0 +1,461 @@
+// Package simnet provides a test harness that serves a generated simnet chain
+// to SPV wallets over the Decred wire protocol from an in-process peer
+// listening on localhost. Blocks can be mined, wallet addresses paid and the
+// chain reorganized while wallets are syncing, allowing SPV sync, rescans,
+// notifications and tx indexing to be tested end to end without a dcrd node.
+//
+// A MultiWallet is synced against the harness by creating it on simnet and
+// setting the harness as its only persistent peer:
+//
+//	h, err := simnet.New()
+//	...
+//	defer h.Close()
+//	h.MineBlocks(20)
+//
+//	mw, err := dcrlibwallet.NewMultiWallet(dir, "", "simnet")
+//	...
+//	mw.SetStringConfigValueForKey(dcrlibwallet.SpvPersistentPeerAddressesConfigKey, h.Addr())
+//	err = mw.SpvSync()
+//
+// Mined blocks are timestamped twice the target block time apart, starting
+// chainTimespan before the harness is created, so that block timestamps are
+// never in the future and the difficulty, which is calculated as by dcrd,
+// stays at the simnet proof of work limit. Blocks mined once the timestamps
+// reach the current time are timestamped at the current time and raise the
+// difficulty. Tickets are never purchased, so the stake difficulty remains
+// the minimum stake difficulty. The harness does not validate transactions,
+// the inputs of transactions paying wallet addresses are made up.
+//
+// The tests of the dcrlibwallet package sync wallets against the harness to
+// test SPV sync, tx indexing, reorgs and rescans.
+package simnet
+
+import (
+	"encoding/binary"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/standalone"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/gcs"
+	"github.com/decred/dcrd/gcs/blockcf"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+const (
+	// blockVersion is the version of mined blocks.
+	blockVersion = 7
+
+	// txFee is the fee of the transactions created to pay wallet addresses.
+	txFee = 10000
+
+	// chainTimespan is how long before the harness is created the first
+	// mined block is timestamped. At twice the simnet target block time,
+	// 21600 blocks are mined before the timestamps reach the current time.
+	chainTimespan = 12 * time.Hour
+)
+
+// Harness is an in-process simnet peer serving a generated chain.
+type Harness struct {
+	params   *chaincfg.Params
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	// startTime is the timestamp of the first mined block.
+	startTime time.Time
+
+	// mu protects all fields below.
+	mu sync.Mutex
+
+	// mainChain holds the hashes of the main chain blocks by height. blocks
+	// and filters hold all blocks ever mined, including blocks removed from
+	// the main chain by reorgs.
+	mainChain []chainhash.Hash
+	blocks    map[chainhash.Hash]*wire.MsgBlock
+	filters   map[chainhash.Hash]*gcs.Filter
+
+	mempool      map[chainhash.Hash]*wire.MsgTx
+	mempoolOrder []chainhash.Hash
+
+	// counters used to create unique coinbase and funding transactions.
+	extraNonce uint64
+	fundings   uint64
+
+	peers  map[*peer]struct{}
+	closed bool
+}
+
+// New creates a harness with a chain of only the simnet genesis block and
+// starts accepting peer connections on a random localhost port.
+func New() (*Harness, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	params := chaincfg.SimNetParams()
+	genesis := params.GenesisBlock
+	genesisFilter, err := blockcf.Regular(genesis)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	h := &Harness{
+		params:    params,
+		listener:  listener,
+		startTime: time.Unix(time.Now().Add(-chainTimespan).Unix(), 0),
+		mainChain: []chainhash.Hash{params.GenesisHash},
+		blocks:    map[chainhash.Hash]*wire.MsgBlock{params.GenesisHash: genesis},
+		filters:   map[chainhash.Hash]*gcs.Filter{params.GenesisHash: genesisFilter},
+		mempool:   make(map[chainhash.Hash]*wire.MsgTx),
+		peers:     make(map[*peer]struct{}),
+	}
+
+	h.wg.Add(1)
+	go h.acceptPeers()
+
+	return h, nil
+}
+
+// Addr returns the address that the harness accepts peer connections on.
+func (h *Harness) Addr() string {
+	return h.listener.Addr().String()
+}
+
+// Params returns the simnet chain parameters.
+func (h *Harness) Params() *chaincfg.Params {
+	return h.params
+}
+
+// Close stops accepting peer connections and disconnects all peers.
+func (h *Harness) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	for p := range h.peers {
+		p.conn.Close()
+	}
+	h.mu.Unlock()
+
+	err := h.listener.Close()
+	h.wg.Wait()
+	return err
+}
+
+// ConnectedPeers returns the number of connected peers.
+func (h *Harness) ConnectedPeers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.peers)
+}
+
+// Tip returns the hash and height of the main chain tip.
+func (h *Harness) Tip() (chainhash.Hash, int32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	height := len(h.mainChain) - 1
+	return h.mainChain[height], int32(height)
+}
+
+// BlockAtHeight returns the main chain block at `height` or nil if the main
+// chain is not that long.
+func (h *Harness) BlockAtHeight(height int32) *wire.MsgBlock {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if height < 0 || int(height) >= len(h.mainChain) {
+		return nil
+	}
+	return h.blocks[h.mainChain[height]]
+}
+
+// MempoolTx returns the unmined transaction with hash `txHash`, e.g. a
+// transaction published by a wallet, or nil if the transaction is not in the
+// mempool.
+func (h *Harness) MempoolTx(txHash *chainhash.Hash) *wire.MsgTx {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.mempool[*txHash]
+}
+
+// MineBlocks mines `n` blocks on the main chain tip and announces them to the
+// connected peers. The mempool transactions are mined in the first block.
+func (h *Harness) MineBlocks(n int) ([]*chainhash.Hash, error) {
+	if n <= 0 {
+		return nil, errors.E(errors.Invalid, "number of blocks must be positive")
+	}
+
+	h.mu.Lock()
+	hashes, headers, err := h.mineBlocks(n)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	h.announceBlocks(headers)
+	return hashes, nil
+}
+
+// Reorg replaces the top `depth` main chain blocks with `depth`+1 new blocks
+// and announces the new blocks to the connected peers. The transactions of
+// the removed blocks are returned to the mempool and mined in the first new
+// block.
+func (h *Harness) Reorg(depth int) ([]*chainhash.Hash, error) {
+	h.mu.Lock()
+	if depth <= 0 || depth >= len(h.mainChain) {
+		h.mu.Unlock()
+		return nil, errors.E(errors.Invalid, "invalid reorg depth")
+	}
+
+	forkHeight := len(h.mainChain) - 1 - depth
+	var removedTxs []*wire.MsgTx
+	for _, hash := range h.mainChain[forkHeight+1:] {
+		removedTxs = append(removedTxs, h.blocks[hash].Transactions[1:]...)
+	}
+	h.mainChain = h.mainChain[:forkHeight+1]
+
+	// removed transactions are mined before any other mempool transactions.
+	mempoolOrder := h.mempoolOrder
+	h.mempoolOrder = nil
+	for _, tx := range removedTxs {
+		h.addMempoolTx(tx)
+	}
+	h.mempoolOrder = append(h.mempoolOrder, mempoolOrder...)
+
+	hashes, headers, err := h.mineBlocks(depth + 1)
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	h.announceBlocks(headers)
+	return hashes, nil
+}
+
+// PayToAddress creates a transaction paying `amount` atoms to the simnet
+// address `address`, adds it to the mempool and announces it to the
+// connected peers. The transaction is mined by the next call to MineBlocks.
+func (h *Harness) PayToAddress(address string, amount int64) (*wire.MsgTx, error) {
+	if amount <= 0 {
+		return nil, errors.E(errors.Invalid, "amount must be positive")
+	}
+
+	addr, err := dcrutil.DecodeAddress(address, h.params)
+	if err != nil {
+		return nil, errors.E(errors.Invalid, err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, errors.E(errors.Invalid, err)
+	}
+
+	h.mu.Lock()
+	h.fundings++
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], h.fundings)
+	fundingHash := chainhash.HashH(b[:])
+
+	tx := wire.NewMsgTx()
+	prevOut := wire.NewOutPoint(&fundingHash, 0, wire.TxTreeRegular)
+	tx.AddTxIn(wire.NewTxIn(prevOut, amount+txFee, []byte{txscript.OP_TRUE}))
+	tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+
+	h.addMempoolTx(tx)
+	h.mu.Unlock()
+
+	txHash := tx.TxHash()
+	h.announceInv(wire.NewInvVect(wire.InvTypeTx, &txHash))
+	return tx, nil
+}
+
+// addMempoolTx must be called with h.mu held.
+func (h *Harness) addMempoolTx(tx *wire.MsgTx) {
+	txHash := tx.TxHash()
+	if _, ok := h.mempool[txHash]; ok {
+		return
+	}
+	h.mempool[txHash] = tx
+	h.mempoolOrder = append(h.mempoolOrder, txHash)
+}
+
+// mineBlocks mines `n` blocks on the main chain tip, including the mempool
+// transactions in the first block. Must be called with h.mu held.
+func (h *Harness) mineBlocks(n int) ([]*chainhash.Hash, []*wire.BlockHeader, error) {
+	hashes := make([]*chainhash.Hash, 0, n)
+	headers := make([]*wire.BlockHeader, 0, n)
+
+	for i := 0; i < n; i++ {
+		var txs []*wire.MsgTx
+		for _, txHash := range h.mempoolOrder {
+			txs = append(txs, h.mempool[txHash])
+		}
+		h.mempool = make(map[chainhash.Hash]*wire.MsgTx)
+		h.mempoolOrder = nil
+
+		block, err := h.mineBlock(txs)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hash := block.BlockHash()
+		filter, err := blockcf.Regular(block)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		h.blocks[hash] = block
+		h.filters[hash] = filter
+		h.mainChain = append(h.mainChain, hash)
+		hashes = append(hashes, &hash)
+		headers = append(headers, &block.Header)
+	}
+
+	return hashes, headers, nil
+}
+
+// mineBlock creates a block with `txs` on the main chain tip and solves it.
+// Must be called with h.mu held.
+func (h *Harness) mineBlock(txs []*wire.MsgTx) (*wire.MsgBlock, error) {
+	prev := &h.blocks[h.mainChain[len(h.mainChain)-1]].Header
+	height := prev.Height + 1
+
+	// spacing blocks further apart than the target block time keeps the
+	// difficulty at the proof of work limit.
+	timestamp := h.startTime
+	if height > 1 {
+		timestamp = prev.Timestamp.Add(2 * h.params.TargetTimePerBlock)
+	}
+	if now := time.Unix(time.Now().Unix(), 0); timestamp.After(now) {
+		timestamp = now
+	}
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:      blockVersion,
+			PrevBlock:    prev.BlockHash(),
+			VoteBits:     1,
+			Bits:         h.nextRequiredDifficulty(),
+			SBits:        h.params.MinimumStakeDiff,
+			Height:       height,
+			Timestamp:    timestamp,
+			StakeVersion: prev.StakeVersion,
+		},
+		Transactions: append([]*wire.MsgTx{h.coinbaseTx(height)}, txs...),
+	}
+
+	block.Header.MerkleRoot = standalone.CalcTxTreeMerkleRoot(block.Transactions)
+	block.Header.StakeRoot = standalone.CalcTxTreeMerkleRoot(block.STransactions)
+	block.Header.Size = uint32(block.SerializeSize())
+
+	for nonce := uint32(0); ; nonce++ {
+		block.Header.Nonce = nonce
+		hash := block.Header.BlockHash()
+		if standalone.CheckProofOfWork(&hash, block.Header.Bits, h.params.PowLimit) == nil {
+			return block, nil
+		}
+		if nonce == ^uint32(0) {
+			return nil, errors.E(errors.Bug, "no nonce solves the block")
+		}
+	}
+}
+
+// nextRequiredDifficulty returns the difficulty of the block after the main
+// chain tip, calculated as by dcrd from the timestamps of the main chain
+// blocks. Must be called with h.mu held.
+func (h *Harness) nextRequiredDifficulty() uint32 {
+	params := h.params
+	tipHeight := int64(len(h.mainChain) - 1)
+	header := func(height int64) *wire.BlockHeader {
+		return &h.blocks[h.mainChain[height]].Header
+	}
+
+	tip := header(tipHeight)
+	if (tipHeight+1)%params.WorkDiffWindowSize != 0 {
+		return tip.Bits
+	}
+
+	oldDiff := standalone.CompactToBig(tip.Bits)
+	adjustmentFactor := big.NewInt(params.RetargetAdjustmentFactor)
+	nextDiffMin := new(big.Int).Div(oldDiff, adjustmentFactor)
+	nextDiffMax := new(big.Int).Mul(oldDiff, adjustmentFactor)
+	targetTimespan := int64(params.TargetTimespan / time.Second)
+
+	// the time taken to mine each window of blocks is weighted
+	// exponentially, favoring the most recent windows, using 64.32 fixed
+	// point arithmetic.
+	weightedSum := new(big.Int)
+	var weights int64
+	recentTime := tip.Timestamp.Unix()
+	height := tipHeight
+	for window := int64(0); window < params.WorkDiffWindows; window++ {
+		height -= params.WorkDiffWindowSize
+		if height < 0 {
+			height = 0
+		}
+
+		olderTime := header(height).Timestamp.Unix()
+		timeDifference := recentTime - olderTime
+		if height == 0 {
+			// windows reaching the genesis block are assumed to have
+			// been mined at the target rate.
+			timeDifference = targetTimespan
+		}
+
+		windowAdjusted := new(big.Int).Lsh(big.NewInt(timeDifference), 32)
+		windowAdjusted.Div(windowAdjusted, big.NewInt(targetTimespan))
+		shift := uint((params.WorkDiffWindows - window) * params.WorkDiffAlpha)
+		weightedSum.Add(weightedSum, windowAdjusted.Lsh(windowAdjusted, shift))
+		weights += 1 << shift
+
+		recentTime = olderTime
+	}
+
+	nextDiff := weightedSum.Div(weightedSum, big.NewInt(weights))
+	nextDiff.Mul(nextDiff, oldDiff)
+	nextDiff.Rsh(nextDiff, 32)
+
+	switch {
+	case nextDiff.Sign() == 0:
+		nextDiff.Set(params.PowLimit)
+	case nextDiff.Cmp(nextDiffMax) > 0:
+		nextDiff.Set(nextDiffMax)
+	case nextDiff.Cmp(nextDiffMin) < 0:
+		nextDiff.Set(nextDiffMin)
+	}
+	if nextDiff.Cmp(params.PowLimit) > 0 {
+		nextDiff.Set(params.PowLimit)
+	}
+
+	return standalone.BigToCompact(nextDiff)
+}
+
+// coinbaseTx returns a unique coinbase transaction for a block at `height`.
+// The block reward is paid to an anyone-can-spend script. Must be called
+// with h.mu held.
+func (h *Harness) coinbaseTx(height uint32) *wire.MsgTx {
+	h.extraNonce++
+
+	var heightData [12]byte
+	binary.LittleEndian.PutUint32(heightData[0:4], height)
+	binary.LittleEndian.PutUint64(heightData[4:12], h.extraNonce)
+	nullDataScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(heightData[:]).Script()
+
+	tx := wire.NewMsgTx()
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex, wire.TxTreeRegular),
+		Sequence:         wire.MaxTxInSequenceNum,
+		ValueIn:          h.params.BaseSubsidy,
+		BlockHeight:      wire.NullBlockHeight,
+		BlockIndex:       wire.NullBlockIndex,
+		SignatureScript:  []byte{txscript.OP_0, txscript.OP_0},
+	})
+	tx.AddTxOut(wire.NewTxOut(0, nullDataScript))
+	tx.AddTxOut(wire.NewTxOut(h.params.BaseSubsidy, []byte{txscript.OP_TRUE}))
+	return tx
+}
@@ -0,0 +1,44 @@
+package simnet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMinedBlocks(t *testing.T) {
+	h, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if _, err = h.MineBlocks(100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.Reorg(10); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	_, tipHeight := h.Tip()
+	for height := int32(1); height <= tipHeight; height++ {
+		block := h.BlockAtHeight(height)
+		prev := h.BlockAtHeight(height - 1)
+		if block.Header.PrevBlock != prev.BlockHash() || block.Header.Height != uint32(height) {
+			t.Fatalf("block at height %d does not connect to the previous block", height)
+		}
+		if block.Header.Timestamp.After(now) {
+			t.Fatalf("block at height %d is timestamped in the future", height)
+		}
+		if height > 1 && !block.Header.Timestamp.After(prev.Header.Timestamp) {
+			t.Fatalf("block at height %d is not timestamped after the previous block", height)
+		}
+
+		// blocks are mined slower than the target block time so the
+		// difficulty remains at the proof of work limit.
+		if block.Header.Bits != h.params.PowLimitBits {
+			t.Fatalf("block at height %d has difficulty bits %x, expected %x",
+				height, block.Header.Bits, h.params.PowLimitBits)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package simnet
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+// services are the services advertised by the harness, as required by SPV
+// wallets.
+const services = wire.SFNodeNetwork | wire.SFNodeCF
+
+// peer is a connection to a wallet's local peer.
+type peer struct {
+	conn net.Conn
+	pver uint32
+
+	// sendHeaders is set when the peer requests block announcements using
+	// headers messages instead of inventory messages. Protected by the
+	// harness mutex.
+	sendHeaders bool
+
+	writeMu sync.Mutex
+}
+
+func (h *Harness) acceptPeers() {
+	defer h.wg.Done()
+
+	for {
+		conn, err := h.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.servePeer(conn)
+		}()
+	}
+}
+
+// servePeer performs the version handshake with the peer connected on conn
+// and responds to its messages until the connection is closed.
+func (h *Harness) servePeer(conn net.Conn) {
+	defer conn.Close()
+
+	p, err := h.handshake(conn)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.peers[p] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.peers, p)
+		h.mu.Unlock()
+	}()
+
+	for {
+		_, msg, _, err := wire.ReadMessageN(conn, p.pver, h.params.Net)
+		if err != nil {
+			if _, ok := err.(*wire.MessageError); ok {
+				// unknown or malformed messages are ignored.
+				continue
+			}
+			return
+		}
+
+		if err := h.handleMessage(p, msg); err != nil {
+			return
+		}
+	}
+}
+
+// handshake exchanges version and verack messages with the peer. The peer
+// is expected to send its version first.
+func (h *Harness) handshake(conn net.Conn) (*peer, error) {
+	_, msg, _, err := wire.ReadMessageN(conn, wire.ProtocolVersion, h.params.Net)
+	if err != nil {
+		return nil, err
+	}
+	remoteVersion, ok := msg.(*wire.MsgVersion)
+	if !ok {
+		return nil, errors.E(errors.Protocol, "first message is not a version message")
+	}
+
+	pver := uint32(remoteVersion.ProtocolVersion)
+	if pver > wire.ProtocolVersion {
+		pver = wire.ProtocolVersion
+	}
+	p := &peer{conn: conn, pver: pver}
+
+	_, tipHeight := h.Tip()
+	local, remote := conn.LocalAddr().(*net.TCPAddr), conn.RemoteAddr().(*net.TCPAddr)
+	localAddr := wire.NewNetAddressIPPort(local.IP, uint16(local.Port), services)
+	remoteAddr := wire.NewNetAddressIPPort(remote.IP, uint16(remote.Port), 0)
+	version := wire.NewMsgVersion(localAddr, remoteAddr, rand.Uint64(), tipHeight)
+	version.ProtocolVersion = int32(pver)
+	version.Services = services
+	if err := p.send(version, h.params.Net); err != nil {
+		return nil, err
+	}
+	if err := p.send(wire.NewMsgVerAck(), h.params.Net); err != nil {
+		return nil, err
+	}
+
+	_, msg, _, err = wire.ReadMessageN(conn, pver, h.params.Net)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := msg.(*wire.MsgVerAck); !ok {
+		return nil, errors.E(errors.Protocol, "expected verack message")
+	}
+
+	return p, nil
+}
+
+func (p *peer) send(msg wire.Message, net wire.CurrencyNet) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err := wire.WriteMessageN(p.conn, msg, p.pver, net)
+	return err
+}
+
+func (h *Harness) handleMessage(p *peer, msg wire.Message) error {
+	switch msg := msg.(type) {
+	case *wire.MsgPing:
+		return p.send(wire.NewMsgPong(msg.Nonce), h.params.Net)
+
+	case *wire.MsgGetAddr:
+		return p.send(wire.NewMsgAddr(), h.params.Net)
+
+	case *wire.MsgSendHeaders:
+		h.mu.Lock()
+		p.sendHeaders = true
+		h.mu.Unlock()
+		return nil
+
+	case *wire.MsgGetHeaders:
+		return p.send(h.headersMsg(msg.BlockLocatorHashes, &msg.HashStop), h.params.Net)
+
+	case *wire.MsgGetCFilter:
+		return h.sendCFilter(p, msg)
+
+	case *wire.MsgGetData:
+		return h.sendData(p, msg.InvList)
+
+	case *wire.MsgInv:
+		// request the announced transactions that are not in the mempool.
+		getData := wire.NewMsgGetData()
+		h.mu.Lock()
+		for _, iv := range msg.InvList {
+			if _, ok := h.mempool[iv.Hash]; iv.Type == wire.InvTypeTx && !ok {
+				getData.AddInvVect(iv)
+			}
+		}
+		h.mu.Unlock()
+		if len(getData.InvList) == 0 {
+			return nil
+		}
+		return p.send(getData, h.params.Net)
+
+	case *wire.MsgTx:
+		h.mu.Lock()
+		h.addMempoolTx(msg)
+		h.mu.Unlock()
+		return nil
+
+	case *wire.MsgMemPool:
+		inv := wire.NewMsgInv()
+		h.mu.Lock()
+		for i := range h.mempoolOrder {
+			if len(inv.InvList) == wire.MaxInvPerMsg {
+				break
+			}
+			inv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &h.mempoolOrder[i]))
+		}
+		h.mu.Unlock()
+		if len(inv.InvList) == 0 {
+			return nil
+		}
+		return p.send(inv, h.params.Net)
+	}
+
+	// other messages are not needed by SPV wallets and are ignored.
+	return nil
+}
+
+// headersMsg returns the main chain headers after the first main chain block
+// in `locators`, or after the genesis block if no locator is in the main
+// chain, through `hashStop` or the tip.
+func (h *Harness) headersMsg(locators []*chainhash.Hash, hashStop *chainhash.Hash) *wire.MsgHeaders {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	startHeight := 1
+	for _, locator := range locators {
+		block, ok := h.blocks[*locator]
+		if !ok {
+			continue
+		}
+		height := int(block.Header.Height)
+		if height < len(h.mainChain) && h.mainChain[height] == *locator {
+			startHeight = height + 1
+			break
+		}
+	}
+
+	headers := wire.NewMsgHeaders()
+	for height := startHeight; height < len(h.mainChain); height++ {
+		if len(headers.Headers) == wire.MaxBlockHeadersPerMsg {
+			break
+		}
+
+		hash := h.mainChain[height]
+		headers.AddBlockHeader(&h.blocks[hash].Header)
+		if hash == *hashStop {
+			break
+		}
+	}
+	return headers
+}
+
+func (h *Harness) sendCFilter(p *peer, msg *wire.MsgGetCFilter) error {
+	h.mu.Lock()
+	filter, ok := h.filters[msg.BlockHash]
+	h.mu.Unlock()
+
+	if !ok || msg.FilterType != wire.GCSFilterRegular {
+		notFound := wire.NewMsgNotFound()
+		notFound.AddInvVect(wire.NewInvVect(wire.InvTypeFilteredBlock, &msg.BlockHash))
+		return p.send(notFound, h.params.Net)
+	}
+
+	return p.send(wire.NewMsgCFilter(&msg.BlockHash, msg.FilterType, filter.NBytes()), h.params.Net)
+}
+
+// sendData sends the requested blocks and transactions, followed by a
+// notfound message for the requested data that is not available.
+func (h *Harness) sendData(p *peer, invList []*wire.InvVect) error {
+	notFound := wire.NewMsgNotFound()
+
+	for _, iv := range invList {
+		var msg wire.Message
+		h.mu.Lock()
+		switch iv.Type {
+		case wire.InvTypeBlock:
+			if block, ok := h.blocks[iv.Hash]; ok {
+				msg = block
+			}
+		case wire.InvTypeTx:
+			if tx, ok := h.mempool[iv.Hash]; ok {
+				msg = tx
+			}
+		}
+		h.mu.Unlock()
+
+		if msg == nil {
+			notFound.AddInvVect(iv)
+			continue
+		}
+		if err := p.send(msg, h.params.Net); err != nil {
+			return err
+		}
+	}
+
+	if len(notFound.InvList) == 0 {
+		return nil
+	}
+	return p.send(notFound, h.params.Net)
+}
+
+// announceBlocks announces new main chain blocks to the connected peers,
+// using a headers message for peers that requested headers announcements.
+func (h *Harness) announceBlocks(headers []*wire.BlockHeader) {
+	headersMsg := wire.NewMsgHeaders()
+	inv := wire.NewMsgInv()
+	for _, header := range headers {
+		if len(headersMsg.Headers) == wire.MaxBlockHeadersPerMsg {
+			// peers fetch any further headers after the announced headers.
+			break
+		}
+		hash := header.BlockHash()
+		headersMsg.AddBlockHeader(header)
+		inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &hash))
+	}
+
+	for p, sendHeaders := range h.connectedPeers() {
+		if sendHeaders {
+			p.send(headersMsg, h.params.Net)
+		} else {
+			p.send(inv, h.params.Net)
+		}
+	}
+}
+
+// announceInv announces inventory to the connected peers.
+func (h *Harness) announceInv(iv *wire.InvVect) {
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(iv)
+	for p := range h.connectedPeers() {
+		p.send(inv, h.params.Net)
+	}
+}
+
+// connectedPeers returns the connected peers and whether each peer requested
+// headers announcements.
+func (h *Harness) connectedPeers() map[*peer]bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	peers := make(map[*peer]bool, len(h.peers))
+	for p := range h.peers {
+		peers[p] = p.sendHeaders
+	}
+	return peers
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/decred/dcrd/addrmgr"
 	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrd/gcs/blockcf"
 	"github.com/decred/dcrd/wire"
 	"github.com/decred/dcrwallet/errors/v2"
@@ -37,18 +38,55 @@ type Syncer struct {
 	atomicCatchUpTryLock uint32          // CAS (entered=1) to perform discovery/rescan
 	atomicWalletsSynced  map[int]*uint32 // CAS (synced=1) when wallet syncing complete
 
-	wallets map[int]*wallet.Wallet
-	lp      *p2p.LocalPeer
+	// wallets, atomicWalletsSynced, loadedFilters and birthdays are
+	// protected by walletsMu as wallets may be added and removed while the
+	// syncer is running.
+	wallets   map[int]*wallet.Wallet
+	walletsMu sync.RWMutex
+	lp        *p2p.LocalPeer
 
-	// Protected by atomicCatchUpTryLock
+	chainParams *chaincfg.Params
+
+	// Set when performing discovery/rescan under atomicCatchUpTryLock.
 	loadedFilters map[int]bool
 
+	// catchingUp holds the ids of wallets added while the syncer is running
+	// that are fetching missing cfilters and headers. Block announcements
+	// are not handled for these wallets. walletRemoved channels are closed
+	// to stop the sync of removed wallets.
+	catchingUp    map[int]bool
+	walletRemoved map[int]chan struct{}
+	catchUps      sync.WaitGroup
+
 	persistentPeers []string
 
+	// dialer is used to connect to remote peers and seeder, if set, is used
+	// to find peers instead of DNS seeding.
+	dialer DialFunc
+	seeder Seeder
+
+	// birthdays of the wallets being synced, set using SetBirthdays.
+	birthdays map[int]*Birthday
+
 	connectingRemotes map[string]struct{}
 	remotes           map[string]*p2p.RemotePeer
+	remoteStats       map[*p2p.RemotePeer]*remoteStats
 	remotesMu         sync.Mutex
 
+	// bannedPeers maps the hosts of banned peers to the time their bans
+	// expire. Protected by remotesMu.
+	bannedPeers map[string]time.Time
+
+	// runCtx is the context of the running sync, used to connect to peers
+	// requested with ConnectPeer. Protected by remotesMu.
+	runCtx      context.Context
+	manualPeers sync.WaitGroup
+
+	// conns are the connections to remote peers keyed by remote address,
+	// used to report the bytes sent to and received from each peer.
+	conns   map[string]*countingConn
+	connsMu sync.Mutex
+
 	// Data filters
 	//
 	// TODO: Replace precise rescan filter with wallet db accesses to avoid
@@ -71,6 +109,12 @@ type Syncer struct {
 	locatorGeneration uint
 	locatorMu         sync.Mutex
 
+	// checkpoints are used to validate headers and to import headers on
+	// the first sync. checkpointMu ensures headers are imported using one
+	// peer at a time.
+	checkpoints  *Checkpoints
+	checkpointMu sync.Mutex
+
 	// Holds all potential callbacks used to notify clients
 	notifications *Notifications
 }
@@ -93,6 +137,10 @@ type Notifications struct {
 	RescanProgress               func(walletID int, rescannedThrough int32)
 	RescanFinished               func(walletID int)
 
+	// BirthdayHeightResolved is called when the birthday timestamp of a
+	// wallet is converted to a block height.
+	BirthdayHeightResolved func(walletID int, height int32)
+
 	// MempoolTxs is called whenever new relevant unmined transactions are
 	// observed and saved.
 	MempoolTxs func(walletID int, txs []*wire.MsgTx)
@@ -112,24 +160,36 @@ func NewSyncer(wallets map[int]*wallet.Wallet, lp *p2p.LocalPeer) *Syncer {
 	rescanFilter := make(map[int]*wallet.RescanFilter)
 	filterData := make(map[int]*blockcf.Entries)
 	atomicWalletsSynced := make(map[int]*uint32, len(wallets))
+	walletRemoved := make(map[int]chan struct{}, len(wallets))
 
-	for walletID := range wallets {
+	var chainParams *chaincfg.Params
+	for walletID, w := range wallets {
 		rescanFilter[walletID] = wallet.NewRescanFilter(nil, nil)
 		filterData[walletID] = &blockcf.Entries{}
 		atomicWalletsSynced[walletID] = new(uint32)
+		walletRemoved[walletID] = make(chan struct{})
+		chainParams = w.ChainParams()
 	}
 
-	return &Syncer{
+	s := &Syncer{
 		atomicWalletsSynced: atomicWalletsSynced,
 		wallets:             wallets,
+		chainParams:         chainParams,
 		loadedFilters:       make(map[int]bool, len(wallets)),
+		catchingUp:          make(map[int]bool),
+		walletRemoved:       walletRemoved,
 		connectingRemotes:   make(map[string]struct{}),
 		remotes:             make(map[string]*p2p.RemotePeer),
+		remoteStats:         make(map[*p2p.RemotePeer]*remoteStats),
+		bannedPeers:         make(map[string]time.Time),
+		conns:               make(map[string]*countingConn),
 		rescanFilter:        rescanFilter,
 		filterData:          filterData,
 		seenTxs:             lru.NewCache(2000),
 		lp:                  lp,
 	}
+	lp.SetDialFunc(s.dial)
+	return s
 }
 
 // SetPersistentPeers sets each peer as a persistent peer and disables DNS
@@ -138,6 +198,19 @@ func (s *Syncer) SetPersistentPeers(peers []string) {
 	s.persistentPeers = peers
 }
 
+// SetDialFunc sets the function used to connect to remote peers, e.g. to
+// connect through a proxy. Must be called before Run.
+func (s *Syncer) SetDialFunc(dial DialFunc) {
+	s.dialer = dial
+}
+
+// SetSeeder replaces the DNS seeding of the local peer with `seeder`, e.g. to
+// avoid DNS lookups that bypass a proxy. The addresses returned by the seeder
+// are added to the address manager. Must be called before Run.
+func (s *Syncer) SetSeeder(seeder Seeder) {
+	s.seeder = seeder
+}
+
 // SetNotifications sets the possible various callbacks that are used
 // to notify interested parties to the syncing progress.
 func (s *Syncer) SetNotifications(ntfns *Notifications) {
@@ -147,7 +220,11 @@ func (s *Syncer) SetNotifications(ntfns *Notifications) {
 // synced checks the atomic that controls wallet syncness and if previously
 // unsynced, updates to synced and notifies the callback, if set.
 func (s *Syncer) synced(walletID int) {
-	if atomic.CompareAndSwapUint32(s.atomicWalletsSynced[walletID], 0, 1) &&
+	s.walletsMu.RLock()
+	walletSynced, ok := s.atomicWalletsSynced[walletID]
+	s.walletsMu.RUnlock()
+
+	if ok && atomic.CompareAndSwapUint32(walletSynced, 0, 1) &&
 		s.notifications != nil &&
 		s.notifications.Synced != nil {
 		s.notifications.Synced(walletID, true)
@@ -157,7 +234,11 @@ func (s *Syncer) synced(walletID int) {
 // unsynced checks the atomic that controls wallet syncness and if previously
 // synced, updates to unsynced and notifies the callback, if set.
 func (s *Syncer) unsynced(walletID int) {
-	if atomic.CompareAndSwapUint32(s.atomicWalletsSynced[walletID], 1, 0) &&
+	s.walletsMu.RLock()
+	walletSynced, ok := s.atomicWalletsSynced[walletID]
+	s.walletsMu.RUnlock()
+
+	if ok && atomic.CompareAndSwapUint32(walletSynced, 1, 0) &&
 		s.notifications != nil &&
 		s.notifications.Synced != nil {
 		s.notifications.Synced(walletID, false)
@@ -263,7 +344,7 @@ func (s *Syncer) lowestChainTip(ctx context.Context) (chainhash.Hash, int32, *wa
 	var lowestTip int32 = -1
 	var lowestTipHash chainhash.Hash
 	var lowestTipWallet *wallet.Wallet
-	for _, w := range s.wallets {
+	for _, w := range s.walletsSnapshot(false) {
 		if hash, height := w.MainChainTip(ctx); height < lowestTip || lowestTip == -1 {
 			lowestTip = height
 			lowestTipHash = hash
@@ -278,7 +359,7 @@ func (s *Syncer) highestChainTip(ctx context.Context) (chainhash.Hash, int32, *w
 	var highestTip int32 = -1
 	var highestTipHash chainhash.Hash
 	var highestTipWallet *wallet.Wallet
-	for _, w := range s.wallets {
+	for _, w := range s.walletsSnapshot(false) {
 		if hash, height := w.MainChainTip(ctx); height > highestTip || highestTip == -1 {
 			highestTip = height
 			highestTipHash = hash
@@ -292,7 +373,7 @@ func (s *Syncer) highestChainTip(ctx context.Context) (chainhash.Hash, int32, *w
 // Run synchronizes the wallet, returning when synchronization fails or the
 // context is cancelled.
 func (s *Syncer) Run(ctx context.Context) error {
-	log.Infof("Syncing %d wallets", len(s.wallets))
+	log.Infof("Syncing %d wallets", len(s.walletsSnapshot(false)))
 
 	tipHash, tipHeight, lowestChainWallet := s.lowestChainTip(ctx)
 	log.Infof("Headers synced through block %v height %d", &tipHash, tipHeight)
@@ -327,13 +408,20 @@ func (s *Syncer) Run(ctx context.Context) error {
 		}
 	}()
 
-	// Seed peers over DNS when not disabled by persistent peers.
-	if len(s.persistentPeers) == 0 {
+	// Seed peers over DNS when not disabled by persistent peers or replaced
+	// by a seeder.
+	if len(s.persistentPeers) == 0 && s.seeder == nil {
 		s.lp.DNSSeed(wire.SFNodeNetwork | wire.SFNodeCF)
 	}
 
 	// Start background handlers to read received messages from remote peers
 	g, ctx := errgroup.WithContext(ctx)
+	if len(s.persistentPeers) == 0 && s.seeder != nil {
+		g.Go(func() error {
+			s.seed(ctx)
+			return nil
+		})
+	}
 	g.Go(func() error { return s.receiveGetData(ctx) })
 	g.Go(func() error { return s.receiveInv(ctx) })
 	g.Go(func() error { return s.receiveHeadersAnnouncements(ctx) })
@@ -348,8 +436,22 @@ func (s *Syncer) Run(ctx context.Context) error {
 		g.Go(func() error { return s.connectToCandidates(ctx) })
 	}
 
+	s.remotesMu.Lock()
+	s.runCtx = ctx
+	s.remotesMu.Unlock()
+
 	// Wait until cancellation or a handler errors.
-	return g.Wait()
+	err = g.Wait()
+
+	// Wait for the peers requested with ConnectPeer to disconnect and for
+	// the sync of wallets added with AddWallet to stop.
+	s.remotesMu.Lock()
+	s.runCtx = nil
+	s.remotesMu.Unlock()
+	s.manualPeers.Wait()
+	s.catchUps.Wait()
+
+	return err
 }
 
 func (s *Syncer) peerCandidate(svcs wire.ServiceFlag) (*wire.NetAddress, error) {
@@ -368,8 +470,9 @@ func (s *Syncer) peerCandidate(svcs wire.ServiceFlag) (*wire.NetAddress, error)
 		s.remotesMu.Lock()
 		_, isConnecting := s.connectingRemotes[k]
 		_, isRemote := s.remotes[k]
+		isBanned := s.isBanned(na.IP.String())
 		s.remotesMu.Unlock()
-		if isConnecting || isRemote {
+		if isConnecting || isRemote || isBanned {
 			continue
 		}
 
@@ -394,6 +497,13 @@ func (s *Syncer) connectToPersistent(ctx context.Context, raddr string) error {
 			ctx, cancel := context.WithCancel(ctx)
 			defer cancel()
 
+			s.remotesMu.Lock()
+			isBanned := s.isBanned(peerHost(raddr))
+			s.remotesMu.Unlock()
+			if isBanned {
+				return
+			}
+
 			rp, err := s.lp.ConnectOutbound(ctx, raddr, reqSvcs)
 			if err != nil {
 				if ctx.Err() == nil {
@@ -404,10 +514,7 @@ func (s *Syncer) connectToPersistent(ctx context.Context, raddr string) error {
 			log.Infof("New peer %v %v %v", raddr, rp.UA(), rp.Services())
 
 			k := addrmgr.NetAddressKey(rp.NA())
-			s.remotesMu.Lock()
-			s.remotes[k] = rp
-			n := len(s.remotes)
-			s.remotesMu.Unlock()
+			n := s.addRemote(k, rp)
 			s.peerConnected(n, k)
 
 			wait := make(chan struct{})
@@ -420,10 +527,7 @@ func (s *Syncer) connectToPersistent(ctx context.Context, raddr string) error {
 			}()
 
 			err = rp.Err()
-			s.remotesMu.Lock()
-			delete(s.remotes, k)
-			n = len(s.remotes)
-			s.remotesMu.Unlock()
+			n = s.removeRemote(k, rp)
 			s.peerDisconnected(n, k)
 			<-wait
 			if ctx.Err() != nil {
@@ -495,11 +599,7 @@ func (s *Syncer) connectToCandidates(ctx context.Context) error {
 			}
 			log.Infof("New peer %v %v %v", raddr, rp.UA(), rp.Services())
 
-			s.remotesMu.Lock()
-			delete(s.connectingRemotes, k)
-			s.remotes[k] = rp
-			n := len(s.remotes)
-			s.remotesMu.Unlock()
+			n := s.addRemote(k, rp)
 			s.peerConnected(n, k)
 
 			wait := make(chan struct{})
@@ -517,10 +617,7 @@ func (s *Syncer) connectToCandidates(ctx context.Context) error {
 			}
 
 			<-wait
-			s.remotesMu.Lock()
-			delete(s.remotes, k)
-			n = len(s.remotes)
-			s.remotesMu.Unlock()
+			n = s.removeRemote(k, rp)
 			s.peerDisconnected(n, k)
 		}()
 	}
@@ -561,7 +658,7 @@ func (s *Syncer) getTransactionsByHashes(ctx context.Context, txHashes []*chainh
 	var notFound []*wire.InvVect
 	var foundTxs []*wire.MsgTx
 
-	for walletID, w := range s.wallets {
+	for walletID, w := range s.walletsSnapshot(false) {
 		walletFoundTxs, _, err := w.GetTransactionsByHashes(ctx, txHashes)
 		if err != nil && !errors.Is(err, errors.NotExist) {
 			return nil, nil, errors.Errorf("[%d] Failed to look up transactions for getdata reply to peer: %v", walletID, err)
@@ -746,7 +843,7 @@ func (s *Syncer) handleBlockInvs(ctx context.Context, rp *p2p.RemotePeer, hashes
 func (s *Syncer) lowestRescanPoint(ctx context.Context) (*chainhash.Hash, error) {
 	var rescanChainHash *chainhash.Hash
 	var rescanBlockHeight int32 = -1
-	for _, w := range s.wallets {
+	for _, w := range s.walletsSnapshot(false) {
 		rescanPoint, err := w.RescanPoint(ctx)
 		if err != nil {
 			return nil, err
@@ -783,7 +880,7 @@ func (s *Syncer) lowestRescanPoint(ctx context.Context) (*chainhash.Hash, error)
 func (s *Syncer) handleTxInvs(ctx context.Context, rp *p2p.RemotePeer, hashes []*chainhash.Hash) {
 	const opf = "spv.handleTxInvs(%v)"
 
-	for _, wallet := range s.wallets {
+	for _, wallet := range s.walletsSnapshot(false) {
 		rpt, err := wallet.RescanPoint(ctx)
 		if err != nil {
 			op := errors.Opf(opf, rp.RemoteAddr())
@@ -849,7 +946,7 @@ ProcessTx:
 	}
 
 	// Save any relevant transaction.
-	for walletID, w := range s.wallets {
+	for walletID, w := range s.walletsSnapshot(false) {
 		relevant := s.filterRelevant(txs, walletID)
 		for _, tx := range relevant {
 			err := w.AcceptMempoolTx(ctx, tx)
@@ -1038,7 +1135,7 @@ func (s *Syncer) handleBlockAnnouncements(ctx context.Context, rp *p2p.RemotePee
 		return err
 	}
 
-	for key, w := range s.wallets {
+	for key, w := range s.walletsSnapshot(true) {
 		newBlocks := make([]*wallet.BlockNode, 0, len(headers))
 		var bestChain []*wallet.BlockNode
 		var matchingTxs map[chainhash.Hash][]*wire.MsgTx
@@ -1162,10 +1259,16 @@ func (s *Syncer) getHeaders(ctx context.Context, rp *p2p.RemotePeer) error {
 	var lastHeight int32
 
 	for {
+		start := time.Now()
 		headers, err := rp.Headers(ctx, locators, &hashStop)
 		if err != nil {
 			return err
 		}
+		s.headersReceived(rp, time.Since(start))
+
+		if err := s.checkCheckpoints(headers); err != nil {
+			return err
+		}
 
 		if len(headers) == 0 {
 			// Ensure that the peer provided headers through the height
@@ -1206,7 +1309,7 @@ func (s *Syncer) getHeaders(ctx context.Context, rp *p2p.RemotePeer) error {
 			return err
 		}
 
-		for walletID, w := range s.wallets {
+		for walletID, w := range s.walletsSnapshot(false) {
 			var added int
 			s.sidechainMu.Lock()
 			for _, n := range nodes {
@@ -1278,19 +1381,26 @@ func (s *Syncer) getHeaders(ctx context.Context, rp *p2p.RemotePeer) error {
 }
 
 func (s *Syncer) fetchMissingCFilters(ctx context.Context, rp *p2p.RemotePeer) error {
-	for walletID, w := range s.wallets {
-		s.fetchMissingCfiltersStart(walletID)
-		progress := make(chan wallet.MissingCFilterProgress, 1)
-		go w.FetchMissingCFiltersWithProgress(ctx, rp, progress)
-
-		for p := range progress {
-			if p.Err != nil {
-				return p.Err
-			}
-			s.fetchMissingCfiltersProgress(walletID, p.BlockHeightStart, p.BlockHeightEnd)
+	for walletID, w := range s.walletsSnapshot(false) {
+		if err := s.fetchMissingWalletCFilters(ctx, rp, walletID, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) fetchMissingWalletCFilters(ctx context.Context, rp *p2p.RemotePeer, walletID int, w *wallet.Wallet) error {
+	s.fetchMissingCfiltersStart(walletID)
+	progress := make(chan wallet.MissingCFilterProgress, 1)
+	go w.FetchMissingCFiltersWithProgress(ctx, rp, progress)
+
+	for p := range progress {
+		if p.Err != nil {
+			return p.Err
 		}
-		s.fetchMissingCfiltersFinished(walletID)
+		s.fetchMissingCfiltersProgress(walletID, p.BlockHeightStart, p.BlockHeightEnd)
 	}
+	s.fetchMissingCfiltersFinished(walletID)
 	return nil
 }
 
@@ -1307,8 +1417,16 @@ func (s *Syncer) startupSync(ctx context.Context, rp *p2p.RemotePeer) error {
 		return err
 	}
 
-	// Fetch any unseen headers from the peer.
+	// Fetch any unseen headers from the peer, importing the headers through
+	// the last checkpoint first if they are not yet fetched.
 	s.fetchHeadersStart(rp.InitialHeight())
+	if err := s.importCheckpointHeaders(ctx, rp); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warnf("Failed to import checkpoint headers, fetching headers "+
+			"from %v instead: %v", rp.RemoteAddr(), err)
+	}
 	log.Debugf("Fetching headers from %v", rp.RemoteAddr())
 	err := s.getHeaders(ctx, rp)
 	if err != nil {
@@ -1318,68 +1436,14 @@ func (s *Syncer) startupSync(ctx context.Context, rp *p2p.RemotePeer) error {
 	log.Debugf("Finished fetching headers from %v", rp.RemoteAddr())
 
 	if atomic.CompareAndSwapUint32(&s.atomicCatchUpTryLock, 0, 1) {
-		for walletID, w := range s.wallets {
-			err = func() error {
-				rescanPoint, err := w.RescanPoint(ctx)
-				if err != nil {
-					return err
-				}
-				walletBackend := &WalletBackend{
-					Syncer:   s,
-					WalletID: walletID,
-				}
-				if rescanPoint == nil {
-					if !s.loadedFilters[walletID] {
-						err = w.LoadActiveDataFilters(ctx, walletBackend, true)
-						if err != nil {
-							return err
-						}
-						s.loadedFilters[walletID] = true
-					}
-
-					s.synced(walletID)
-
-					return nil
-				}
-				// RescanPoint is != nil so we are not synced to the peer and
-				// check to see if it was previously synced
-				s.unsynced(walletID)
-
-				s.discoverAddressesStart(walletID)
-				err = w.DiscoverActiveAddresses(ctx, rp, rescanPoint, !w.Locked())
-				if err != nil {
-					return err
-				}
-
-				s.discoverAddressesFinished(walletID)
-
-				err = w.LoadActiveDataFilters(ctx, walletBackend, true)
-				if err != nil {
-					return err
-				}
-				s.loadedFilters[walletID] = true
-
-				s.rescanStart(walletID)
-
-				rescanBlock, err := w.BlockHeader(ctx, rescanPoint)
-				if err != nil {
-					return err
-				}
-				progress := make(chan wallet.RescanProgress, 1)
-				go w.RescanProgressFromHeight(ctx, walletBackend, int32(rescanBlock.Height), progress)
-
-				for p := range progress {
-					if p.Err != nil {
-						return p.Err
-					}
-					s.rescanProgress(walletID, p.ScannedThrough)
-				}
-				s.rescanFinished(walletID)
-
-				s.synced(walletID)
-
-				return nil
-			}()
+		for walletID, w := range s.walletsSnapshot(true) {
+			wctx, cancel := s.walletContext(ctx, walletID)
+			err = s.catchUpWallet(wctx, rp, walletID, w)
+			cancel()
+			if err != nil && ctx.Err() == nil && wctx.Err() != nil {
+				// the wallet was removed while catching up.
+				err = nil
+			}
 		}
 
 		atomic.StoreUint32(&s.atomicCatchUpTryLock, 0)
@@ -1388,7 +1452,7 @@ func (s *Syncer) startupSync(ctx context.Context, rp *p2p.RemotePeer) error {
 		}
 	}
 
-	for _, w := range s.wallets {
+	for _, w := range s.walletsSnapshot(false) {
 		unminedTxs, err := w.UnminedTransactions(ctx)
 		if err != nil {
 			log.Errorf("Cannot load unmined transactions for resending: %v", err)
@@ -1406,3 +1470,83 @@ func (s *Syncer) startupSync(ctx context.Context, rp *p2p.RemotePeer) error {
 
 	return nil
 }
+
+// catchUpWallet performs address discovery and rescans the wallet from its
+// rescan point, or loads the wallet's data filters if it has no rescan
+// point. Must be called with atomicCatchUpTryLock set.
+func (s *Syncer) catchUpWallet(ctx context.Context, rp *p2p.RemotePeer, walletID int, w *wallet.Wallet) error {
+	rescanPoint, err := w.RescanPoint(ctx)
+	if err != nil {
+		return err
+	}
+	walletBackend := &WalletBackend{
+		Syncer:   s,
+		WalletID: walletID,
+	}
+	if rescanPoint == nil {
+		if !s.filtersLoaded(walletID) {
+			err = w.LoadActiveDataFilters(ctx, walletBackend, true)
+			if err != nil {
+				return err
+			}
+			s.setFiltersLoaded(walletID)
+		}
+
+		s.synced(walletID)
+
+		return nil
+	}
+	// RescanPoint is != nil so we are not synced to the peer and
+	// check to see if it was previously synced
+	s.unsynced(walletID)
+
+	rescanBlock, err := w.BlockHeader(ctx, rescanPoint)
+	if err != nil {
+		return err
+	}
+	rescanHeight := int32(rescanBlock.Height)
+
+	// blocks before the wallet's birthday cannot contain
+	// relevant transactions, skip them.
+	birthdayHash, birthdayHeight, err := s.birthdayBlock(ctx, walletID, w)
+	if err != nil {
+		return err
+	}
+	if birthdayHash != nil && birthdayHeight > rescanHeight {
+		log.Infof("[%d] Starting address discovery and rescan from birthday block %v height %d",
+			walletID, birthdayHash, birthdayHeight)
+		rescanPoint = birthdayHash
+		rescanHeight = birthdayHeight
+	}
+
+	s.discoverAddressesStart(walletID)
+	err = w.DiscoverActiveAddresses(ctx, rp, rescanPoint, !w.Locked())
+	if err != nil {
+		return err
+	}
+
+	s.discoverAddressesFinished(walletID)
+
+	err = w.LoadActiveDataFilters(ctx, walletBackend, true)
+	if err != nil {
+		return err
+	}
+	s.setFiltersLoaded(walletID)
+
+	s.rescanStart(walletID)
+
+	progress := make(chan wallet.RescanProgress, 1)
+	go w.RescanProgressFromHeight(ctx, walletBackend, rescanHeight, progress)
+
+	for p := range progress {
+		if p.Err != nil {
+			return p.Err
+		}
+		s.rescanProgress(walletID, p.ScannedThrough)
+	}
+	s.rescanFinished(walletID)
+
+	s.synced(walletID)
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package spv
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/decred/dcrd/gcs/blockcf"
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/decred/dcrwallet/p2p/v2"
+	"github.com/decred/dcrwallet/wallet/v3"
+)
+
+// walletsSnapshot returns a copy of the wallets being synced. If
+// `excludeCatchingUp` is true, wallets added while the syncer is running
+// that are still fetching missing cfilters and headers are left out.
+func (s *Syncer) walletsSnapshot(excludeCatchingUp bool) map[int]*wallet.Wallet {
+	s.walletsMu.RLock()
+	defer s.walletsMu.RUnlock()
+
+	wallets := make(map[int]*wallet.Wallet, len(s.wallets))
+	for walletID, w := range s.wallets {
+		if excludeCatchingUp && s.catchingUp[walletID] {
+			continue
+		}
+		wallets[walletID] = w
+	}
+	return wallets
+}
+
+func (s *Syncer) filtersLoaded(walletID int) bool {
+	s.walletsMu.RLock()
+	defer s.walletsMu.RUnlock()
+	return s.loadedFilters[walletID]
+}
+
+func (s *Syncer) setFiltersLoaded(walletID int) {
+	s.walletsMu.Lock()
+	if _, ok := s.wallets[walletID]; ok {
+		s.loadedFilters[walletID] = true
+	}
+	s.walletsMu.Unlock()
+}
+
+// walletContext returns a context that is canceled when `ctx` is done or
+// when the wallet is removed with RemoveWallet.
+func (s *Syncer) walletContext(ctx context.Context, walletID int) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.walletsMu.RLock()
+	removed, ok := s.walletRemoved[walletID]
+	s.walletsMu.RUnlock()
+	if !ok {
+		cancel()
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-removed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// AddWallet adds a wallet to the wallets being synced. If the syncer is
+// running, the wallet's missing cfilters and headers are fetched, its tx
+// filter is loaded and it is rescanned in the background without
+// restarting the sync of the other wallets. `birthday` may be nil.
+func (s *Syncer) AddWallet(walletID int, w *wallet.Wallet, birthday *Birthday) error {
+	s.remotesMu.Lock()
+	defer s.remotesMu.Unlock()
+
+	s.walletsMu.Lock()
+	if _, ok := s.wallets[walletID]; ok {
+		s.walletsMu.Unlock()
+		return errors.E(errors.Exist, "wallet is already being synced")
+	}
+
+	s.wallets[walletID] = w
+	s.atomicWalletsSynced[walletID] = new(uint32)
+	s.walletRemoved[walletID] = make(chan struct{})
+	if s.chainParams == nil {
+		s.chainParams = w.ChainParams()
+	}
+	if birthday != nil {
+		if s.birthdays == nil {
+			s.birthdays = make(map[int]*Birthday)
+		}
+		s.birthdays[walletID] = birthday
+	}
+	if s.runCtx != nil {
+		s.catchingUp[walletID] = true
+	}
+	s.walletsMu.Unlock()
+
+	s.filterMu.Lock()
+	s.rescanFilter[walletID] = wallet.NewRescanFilter(nil, nil)
+	s.filterData[walletID] = &blockcf.Entries{}
+	s.filterMu.Unlock()
+
+	if s.runCtx != nil {
+		s.catchUps.Add(1)
+		go s.syncAddedWallet(s.runCtx, walletID, w)
+	}
+
+	return nil
+}
+
+// RemoveWallet stops syncing the wallet with id `walletID`. Address
+// discovery or a rescan of the wallet in progress is canceled, the other
+// wallets continue syncing. At least one wallet must remain.
+func (s *Syncer) RemoveWallet(walletID int) error {
+	s.walletsMu.Lock()
+	defer s.walletsMu.Unlock()
+
+	if _, ok := s.wallets[walletID]; !ok {
+		return errors.E(errors.NotExist, "wallet is not being synced")
+	}
+	if len(s.wallets) == 1 {
+		return errors.E(errors.Invalid, "cannot stop syncing the only wallet being synced")
+	}
+
+	// the rescan filter of the wallet is kept as it may still be read by
+	// block scans in progress, it is replaced if the wallet is added again.
+	delete(s.wallets, walletID)
+	delete(s.atomicWalletsSynced, walletID)
+	delete(s.loadedFilters, walletID)
+	delete(s.catchingUp, walletID)
+	close(s.walletRemoved[walletID])
+	delete(s.walletRemoved, walletID)
+
+	return nil
+}
+
+// syncAddedWallet syncs a wallet added while the syncer is running, retrying
+// with another peer if syncing with a peer fails.
+func (s *Syncer) syncAddedWallet(ctx context.Context, walletID int, w *wallet.Wallet) {
+	defer s.catchUps.Done()
+
+	ctx, cancel := s.walletContext(ctx, walletID)
+	defer cancel()
+
+	for {
+		err := s.catchUpAddedWallet(ctx, walletID, w)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		log.Warnf("[%d] Failed to sync wallet: %v", walletID, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (s *Syncer) catchUpAddedWallet(ctx context.Context, walletID int, w *wallet.Wallet) error {
+	rp, err := s.pickRemote(pickAny)
+	if err != nil {
+		return err
+	}
+
+	if err := s.fetchMissingWalletCFilters(ctx, rp, walletID, w); err != nil {
+		return err
+	}
+
+	// the current locators are of the other wallets, fetch headers using
+	// locators of the lowest chain tip which may be the added wallet's tip.
+	s.locatorMu.Lock()
+	s.currentLocators = nil
+	s.locatorMu.Unlock()
+
+	if err := s.catchUpAddedWalletHeaders(ctx, rp, walletID); err != nil {
+		return err
+	}
+
+	s.walletsMu.Lock()
+	delete(s.catchingUp, walletID)
+	s.walletsMu.Unlock()
+
+	// wait for any catch up of the other wallets to complete.
+	for !atomic.CompareAndSwapUint32(&s.atomicCatchUpTryLock, 0, 1) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	defer atomic.StoreUint32(&s.atomicCatchUpTryLock, 0)
+
+	return s.catchUpWallet(ctx, rp, walletID, w)
+}
+
+// catchUpAddedWalletHeaders fetches the headers from the lowest wallet chain
+// tip. The headers fetch is reported finished when it fails too.
+func (s *Syncer) catchUpAddedWalletHeaders(ctx context.Context, rp *p2p.RemotePeer, walletID int) error {
+	s.fetchHeadersStart(rp.InitialHeight())
+	defer s.fetchHeadersFinished()
+
+	if err := s.importCheckpointHeaders(ctx, rp); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Warnf("[%d] Failed to import checkpoint headers: %v", walletID, err)
+	}
+	return s.getHeaders(ctx, rp)
+}
@@ -0,0 +1,274 @@
+package dcrlibwallet
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/wire"
+	"github.com/raedahgroup/dcrlibwallet/spv/simnet"
+)
+
+// simnetTimeout is how long the tests wait for the wallet to process changes
+// to the harness chain.
+const simnetTimeout = 30 * time.Second
+
+// simnetTest is a wallet syncing against a simnet harness.
+type simnetTest struct {
+	t       *testing.T
+	harness *simnet.Harness
+	mw      *MultiWallet
+	wallet  *Wallet
+	events  <-chan *Event
+	cancel  func()
+}
+
+// newSimnetTest creates a harness with `blocks` mined blocks and a wallet
+// that is set to sync against the harness, and subscribes to the events of
+// the wallet. Sync is not started.
+func newSimnetTest(t *testing.T, blocks int) *simnetTest {
+	harness, err := simnet.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = harness.MineBlocks(blocks); err != nil {
+		harness.Close()
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "dcrlibwallet-simnet")
+	if err != nil {
+		harness.Close()
+		t.Fatal(err)
+	}
+
+	mw, err := NewMultiWallet(dir, "", "simnet")
+	if err != nil {
+		harness.Close()
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	test := &simnetTest{t: t, harness: harness, mw: mw}
+	ctx, cancel := context.WithCancel(context.Background())
+	test.cancel = func() {
+		cancel()
+		mw.Shutdown()
+		harness.Close()
+		os.RemoveAll(dir)
+	}
+
+	mw.SetStringConfigValueForKey(SpvPersistentPeerAddressesConfigKey, harness.Addr())
+	test.wallet, err = mw.CreateNewWallet("passphrase", PassphraseTypePass)
+	if err != nil {
+		test.cancel()
+		t.Fatal(err)
+	}
+	test.events = mw.Subscribe(ctx, &EventFilter{SlowConsumerPolicy: Unbounded})
+
+	return test
+}
+
+// waitForEvent returns the first event received that `match` returns true
+// for.
+func (test *simnetTest) waitForEvent(description string, match func(*Event) bool) *Event {
+	test.t.Helper()
+
+	timeout := time.After(simnetTimeout)
+	for {
+		select {
+		case event := <-test.events:
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			test.t.Fatalf("timed out waiting for %s", description)
+		}
+	}
+}
+
+// sync starts SPV sync and waits for the sync to complete.
+func (test *simnetTest) sync() {
+	test.t.Helper()
+
+	if err := test.mw.SpvSync(); err != nil {
+		test.t.Fatal(err)
+	}
+	test.waitForEvent("sync to complete", func(event *Event) bool {
+		return event.Type == EventSyncStateChanged && event.Synced
+	})
+}
+
+// payWallet pays the wallet's current address through the harness and waits
+// for the wallet to see the unmined transaction.
+func (test *simnetTest) payWallet(amount int64) *wire.MsgTx {
+	test.t.Helper()
+
+	address, err := test.wallet.CurrentAddress(0)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	tx, err := test.harness.PayToAddress(address, amount)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+
+	txHash := tx.TxHash().String()
+	test.waitForEvent("the new transaction", func(event *Event) bool {
+		return event.Type == EventNewTransaction && event.Transaction.Hash == txHash
+	})
+	return tx
+}
+
+// indexedTx returns the indexed transaction with hash `txHash`.
+func (test *simnetTest) indexedTx(txHash chainhash.Hash) *Transaction {
+	test.t.Helper()
+
+	transactions, err := test.wallet.GetTransactionsRaw(0, 0, TxFilterAll, true)
+	if err != nil {
+		test.t.Fatal(err)
+	}
+	for i := range transactions {
+		if transactions[i].Hash == txHash.String() {
+			return &transactions[i]
+		}
+	}
+	test.t.Fatalf("transaction %v is not indexed", txHash)
+	return nil
+}
+
+func TestSpvSyncToTip(t *testing.T) {
+	test := newSimnetTest(t, 50)
+	defer test.cancel()
+
+	test.sync()
+	_, tipHeight := test.harness.Tip()
+	if bestBlock := test.wallet.GetBestBlock(); bestBlock != tipHeight {
+		t.Fatalf("wallet synced to height %d, expected %d", bestBlock, tipHeight)
+	}
+	if !test.mw.IsSynced() || test.harness.ConnectedPeers() != 1 {
+		t.Fatal("wallet is not synced with the harness")
+	}
+
+	// blocks mined after the sync are attached.
+	if _, err := test.harness.MineBlocks(5); err != nil {
+		t.Fatal(err)
+	}
+	test.waitForEvent("the new blocks", func(event *Event) bool {
+		return event.Type == EventBlockAttached && event.BlockHeight == tipHeight+5
+	})
+}
+
+func TestSpvSyncIndexesTransactions(t *testing.T) {
+	test := newSimnetTest(t, 20)
+	defer test.cancel()
+
+	test.sync()
+	tx := test.payWallet(1e8)
+	txHash := tx.TxHash()
+	if indexed := test.indexedTx(txHash); indexed.BlockHeight != BlockHeightInvalid {
+		t.Fatalf("unmined transaction indexed at height %d", indexed.BlockHeight)
+	}
+
+	if _, err := test.harness.MineBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+	_, tipHeight := test.harness.Tip()
+	test.waitForEvent("the transaction to be confirmed", func(event *Event) bool {
+		return event.Type == EventTransactionConfirmed && event.Transaction.Hash == txHash.String()
+	})
+
+	indexed := test.indexedTx(txHash)
+	if indexed.BlockHeight != tipHeight || indexed.Amount != 1e8 || indexed.Direction != TxDirectionReceived {
+		t.Fatalf("transaction indexed at height %d with amount %d and direction %d",
+			indexed.BlockHeight, indexed.Amount, indexed.Direction)
+	}
+}
+
+func TestSpvSyncReorg(t *testing.T) {
+	test := newSimnetTest(t, 20)
+	defer test.cancel()
+
+	test.sync()
+	tx := test.payWallet(1e8)
+	txHash := tx.TxHash()
+	if _, err := test.harness.MineBlocks(2); err != nil {
+		t.Fatal(err)
+	}
+	_, tipHeight := test.harness.Tip()
+	test.waitForEvent("the new blocks", func(event *Event) bool {
+		return event.Type == EventBlockAttached && event.BlockHeight == tipHeight
+	})
+
+	// the transaction is mined again in the first block of the new chain.
+	if _, err := test.harness.Reorg(2); err != nil {
+		t.Fatal(err)
+	}
+	reorg := test.waitForEvent("the reorg", func(event *Event) bool {
+		return event.Type == EventReorg
+	})
+	if reorg.BlockHeight != tipHeight-1 || reorg.ReorgDepth != 2 {
+		t.Fatalf("reorg of %d blocks from height %d, expected 2 blocks from height %d",
+			reorg.ReorgDepth, reorg.BlockHeight, tipHeight-1)
+	}
+	test.waitForEvent("the new chain", func(event *Event) bool {
+		return event.Type == EventBlockAttached && event.BlockHeight == tipHeight+1
+	})
+
+	if indexed := test.indexedTx(txHash); indexed.BlockHeight != tipHeight-1 {
+		t.Fatalf("transaction indexed at height %d after the reorg, expected %d",
+			indexed.BlockHeight, tipHeight-1)
+	}
+}
+
+// rescanListener reports the end of rescans.
+type rescanListener struct {
+	ended chan error
+}
+
+func (l *rescanListener) OnBlocksRescanStarted(walletID int)                  {}
+func (l *rescanListener) OnBlocksRescanProgress(*HeadersRescanProgressReport) {}
+func (l *rescanListener) OnBlocksRescanEnded(walletID int, err error)         { l.ended <- err }
+
+func TestSpvSyncRescan(t *testing.T) {
+	test := newSimnetTest(t, 20)
+	defer test.cancel()
+
+	test.sync()
+	tx := test.payWallet(1e8)
+	txHash := tx.TxHash()
+	if _, err := test.harness.MineBlocks(3); err != nil {
+		t.Fatal(err)
+	}
+	_, tipHeight := test.harness.Tip()
+	test.waitForEvent("the new blocks", func(event *Event) bool {
+		return event.Type == EventBlockAttached && event.BlockHeight == tipHeight
+	})
+
+	listener := &rescanListener{ended: make(chan error, 1)}
+	test.mw.SetBlocksRescanProgressListener(listener)
+	if err := test.mw.RescanBlocks(test.wallet.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-listener.ended:
+		if err != nil {
+			t.Fatalf("rescan failed: %v", err)
+		}
+	case <-time.After(simnetTimeout):
+		t.Fatal("timed out waiting for the rescan")
+	}
+
+	// the index of the rescanned blocks is rebuilt.
+	if indexed := test.indexedTx(txHash); indexed.BlockHeight != tipHeight-2 {
+		t.Fatalf("transaction indexed at height %d after the rescan, expected %d",
+			indexed.BlockHeight, tipHeight-2)
+	}
+	if test.mw.IsRescanning() {
+		t.Fatal("rescan did not end")
+	}
+}
@@ -0,0 +1,355 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrec"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/gcs"
+	"github.com/decred/dcrd/gcs/blockcf"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+	w "github.com/decred/dcrwallet/wallet/v3"
+	"github.com/decred/dcrwallet/wallet/v3/txrules"
+	"github.com/decred/dcrwallet/wallet/v3/txsizes"
+)
+
+// SweepProgressListener receives progress updates of SweepPrivateKey.
+type SweepProgressListener interface {
+	OnSweepScanProgress(walletID int, scannedHeight, tipHeight int32)
+	// OnSweepOutputsFound reports the mature outputs that are swept and the
+	// number of outputs of the key that are not mature yet and are left
+	// unswept.
+	OnSweepOutputsFound(walletID int, outputCount int32, totalAmount int64, immatureCount int32)
+	OnSweepPublished(walletID int, txHash string, amount, fee int64)
+	OnSweepEnded(walletID int, err error)
+}
+
+// sweepProgressInterval is the number of blocks scanned between progress
+// reports while sweeping a private key.
+const sweepProgressInterval = 2000
+
+// sweepOutput is an unspent output paying to the key being swept.
+// `pkScript` is the P2PKH script of the key, tagged with a stake opcode for
+// outputs of the stake tree.
+type sweepOutput struct {
+	outpoint wire.OutPoint
+	amount   int64
+	pkScript []byte
+	mature   bool
+}
+
+// sweepMaturity is the number of blocks after which the outputs of
+// coinbases, votes and revocations, and the change outputs of tickets can
+// be spent.
+type sweepMaturity struct {
+	coinbase    int32
+	stakeChange int32
+}
+
+// sweepChain provides the main chain blocks scanned for the outputs of a
+// swept key.
+type sweepChain interface {
+	// tipHeight returns the height of the main chain tip.
+	tipHeight(ctx context.Context) int32
+
+	// block returns the hash and header of the main chain block at `height`
+	// and its cfilter. The cfilter is nil if it is not available.
+	block(ctx context.Context, height int32) (*chainhash.Hash, *wire.BlockHeader, *gcs.Filter, error)
+}
+
+// walletSweepChain is a sweepChain that reads the blocks from the wallet.
+type walletSweepChain struct {
+	wallet *w.Wallet
+}
+
+func (c walletSweepChain) tipHeight(ctx context.Context) int32 {
+	_, tipHeight := c.wallet.MainChainTip(ctx)
+	return tipHeight
+}
+
+func (c walletSweepChain) block(ctx context.Context, height int32) (*chainhash.Hash, *wire.BlockHeader, *gcs.Filter, error) {
+	blockInfo, err := c.wallet.BlockInfo(ctx, w.NewBlockIdentifierFromHeight(height))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	header := new(wire.BlockHeader)
+	if err = header.Deserialize(bytes.NewReader(blockInfo.Header)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	filter, err := c.wallet.CFilter(ctx, &blockInfo.Hash)
+	if err != nil {
+		filter = nil
+	}
+
+	return &blockInfo.Hash, header, filter, nil
+}
+
+func (wallet *Wallet) SetSweepProgressListener(listener SweepProgressListener) {
+	wallet.sweepProgressListener = listener
+}
+
+// SweepPrivateKey sends all the funds of the WIF encoded private key `wif`
+// to a new internal address of `destAccount` and returns the hash of the
+// sweep transaction. The whole main chain is scanned for the outputs of the
+// key, see SweepPrivateKeyFromHeight. `feeRate` is in atoms/kB.
+func (wallet *Wallet) SweepPrivateKey(wif string, destAccount int32, feeRate int64) ([]byte, error) {
+	return wallet.SweepPrivateKeyFromHeight(wif, destAccount, feeRate, 0)
+}
+
+// SweepPrivateKeyFromHeight is like SweepPrivateKey but only scans the main
+// chain blocks from `startHeight`, which should be the height of the first
+// block that could contain a payment to the key. The outputs of the key are
+// found by matching the key's P2PKH script against the cfilters of the
+// blocks, blocks are only fetched from the network after the first cfilter
+// match. Outputs of the regular tree and the vote, revocation and ticket
+// change outputs of the stake tree are swept once they are mature, the
+// number of immature outputs is reported to OnSweepOutputsFound. Unconfirmed
+// outputs are not known to the wallet and are not swept, the key can be
+// swept again after they are mined. The key is only used to sign the sweep
+// transaction and is not saved to the wallet.
+func (wallet *Wallet) SweepPrivateKeyFromHeight(wif string, destAccount int32, feeRate int64, startHeight int32) ([]byte, error) {
+	if err := validateFeeRate(feeRate, false); err != nil {
+		return nil, err
+	}
+
+	if startHeight < 0 || startHeight > wallet.GetBestBlock() {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	n, err := wallet.internal.NetworkBackend()
+	if err != nil {
+		return nil, errors.New(ErrNotConnected)
+	}
+
+	listener := wallet.sweepProgressListener
+	txHash, err := wallet.sweepPrivateKey(n, wif, uint32(destAccount), dcrutil.Amount(feeRate), startHeight, listener)
+	if listener != nil {
+		listener.OnSweepEnded(wallet.ID, err)
+	}
+	return txHash, err
+}
+
+func (wallet *Wallet) sweepPrivateKey(n w.NetworkBackend, wifStr string, destAccount uint32, feeRate dcrutil.Amount,
+	startHeight int32, listener SweepProgressListener) ([]byte, error) {
+
+	wif, err := dcrutil.DecodeWIF(wifStr, wallet.chainParams.PrivateKeyID)
+	if err != nil || wif.DSA() != dcrec.STEcdsaSecp256k1 {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	addr, err := dcrutil.NewAddressPubKeyHash(dcrutil.Hash160(wif.PubKey()), wallet.chainParams, dcrec.STEcdsaSecp256k1)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := wallet.shutdownContextWithCancel()
+	defer cancel()
+
+	progress := func(scannedHeight, tipHeight int32) {
+		if listener != nil {
+			listener.OnSweepScanProgress(wallet.ID, scannedHeight, tipHeight)
+		}
+	}
+	maturity := sweepMaturity{
+		coinbase:    int32(wallet.chainParams.CoinbaseMaturity),
+		stakeChange: int32(wallet.chainParams.SStxChangeMaturity),
+	}
+	chain := walletSweepChain{wallet.internal}
+	found, err := findSweepOutputs(ctx, chain, n, pkScript, startHeight, maturity, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputs []sweepOutput
+	var totalAmount int64
+	for _, output := range found {
+		if output.mature {
+			outputs = append(outputs, output)
+			totalAmount += output.amount
+		}
+	}
+	if listener != nil {
+		immatureCount := int32(len(found) - len(outputs))
+		listener.OnSweepOutputsFound(wallet.ID, int32(len(outputs)), totalAmount, immatureCount)
+	}
+	if len(outputs) == 0 {
+		return nil, errors.New(ErrInsufficientBalance)
+	}
+
+	destAddr, err := wallet.internal.NewChangeAddress(ctx, destAccount)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	destScript, err := txscript.PayToAddrScript(destAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	msgTx := wire.NewMsgTx()
+	scriptSizes := make([]int, len(outputs))
+	for i := range outputs {
+		msgTx.AddTxIn(wire.NewTxIn(&outputs[i].outpoint, outputs[i].amount, nil))
+		scriptSizes[i] = txsizes.RedeemP2PKHSigScriptSize
+	}
+
+	txOut := wire.NewTxOut(0, destScript)
+	size := txsizes.EstimateSerializeSize(scriptSizes, []*wire.TxOut{txOut}, 0)
+	fee := txrules.FeeForSerializeSize(feeRate, size)
+	txOut.Value = totalAmount - int64(fee)
+	if txOut.Value <= 0 || txrules.IsDustOutput(txOut, feeRate) {
+		return nil, errors.New(ErrInsufficientBalance)
+	}
+	msgTx.AddTxOut(txOut)
+
+	for i := range msgTx.TxIn {
+		sigScript, err := txscript.SignatureScript(msgTx, i, outputs[i].pkScript, txscript.SigHashAll, wif.PrivKey, true)
+		if err != nil {
+			return nil, fmt.Errorf("error signing input %d: %v", i, err)
+		}
+		msgTx.TxIn[i].SignatureScript = sigScript
+	}
+
+	var serializedTx bytes.Buffer
+	serializedTx.Grow(msgTx.SerializeSize())
+	if err = msgTx.Serialize(&serializedTx); err != nil {
+		return nil, err
+	}
+
+	// the sweep transaction pays to this wallet, publishing it through the
+	// wallet also records it as an unmined transaction.
+	txHash, err := wallet.internal.PublishTransaction(ctx, msgTx, serializedTx.Bytes(), n)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if listener != nil {
+		listener.OnSweepPublished(wallet.ID, txHash.String(), txOut.Value, int64(fee))
+	}
+
+	return txHash[:], nil
+}
+
+// findSweepOutputs returns the unspent outputs of the main chain blocks from
+// `startHeight` paying to `pkScript`. Blocks are only fetched from the
+// network if their cfilter matches the script or one of the outputs found in
+// earlier blocks. Immature outputs are returned with `mature` false.
+// `progress` is called periodically with the scanned height.
+func findSweepOutputs(ctx context.Context, chain sweepChain, n w.NetworkBackend, pkScript []byte, startHeight int32,
+	maturity sweepMaturity, progress func(scannedHeight, tipHeight int32)) ([]sweepOutput, error) {
+
+	tipHeight := chain.tipHeight(ctx)
+
+	var outputs []sweepOutput
+	for height := startHeight; height <= tipHeight; height++ {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.New(ErrContextCanceled)
+		}
+
+		blockHash, header, filter, err := chain.block(ctx, height)
+		if err != nil {
+			return nil, err
+		}
+
+		var entries blockcf.Entries
+		entries.AddRegularPkScript(pkScript)
+		for i := range outputs {
+			entries.AddOutPoint(&outputs[i].outpoint)
+		}
+
+		// the block is fetched and checked directly if there is no cfilter
+		// for it.
+		if filter == nil || filter.MatchAny(blockcf.Key(&header.MerkleRoot), entries) {
+			blocks, err := n.Blocks(ctx, []*chainhash.Hash{blockHash})
+			if err != nil {
+				return nil, err
+			}
+
+			outputs = sweepBlockOutputs(blocks[0], pkScript, outputs, tipHeight-height, maturity)
+		}
+
+		if (height-startHeight)%sweepProgressInterval == 0 {
+			progress(height, tipHeight)
+		}
+	}
+
+	progress(tipHeight, tipHeight)
+	return outputs, nil
+}
+
+// sweepBlockOutputs removes the outputs spent by the regular and stake
+// transactions of `block` from `outputs` and adds the outputs that pay to
+// `pkScript`, including the vote, revocation and ticket change outputs that
+// pay to the stake tagged `pkScript`. `depth` is the number of blocks mined
+// on top of `block` and decides whether the added outputs are mature.
+func sweepBlockOutputs(block *wire.MsgBlock, pkScript []byte, outputs []sweepOutput, depth int32,
+	maturity sweepMaturity) []sweepOutput {
+
+	spend := func(tx *wire.MsgTx) {
+		for _, txIn := range tx.TxIn {
+			for i := range outputs {
+				if outputs[i].outpoint.Hash == txIn.PreviousOutPoint.Hash &&
+					outputs[i].outpoint.Index == txIn.PreviousOutPoint.Index {
+					outputs = append(outputs[:i], outputs[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+
+	add := func(tx *wire.MsgTx, index int, tree int8, script []byte, mature bool) {
+		txHash := tx.TxHash()
+		outputs = append(outputs, sweepOutput{
+			outpoint: *wire.NewOutPoint(&txHash, uint32(index), tree),
+			amount:   tx.TxOut[index].Value,
+			pkScript: script,
+			mature:   mature,
+		})
+	}
+
+	for _, tx := range block.STransactions {
+		// outputs of both trees can be spent by stake transactions, e.g.
+		// to purchase tickets.
+		spend(tx)
+
+		for i, txOut := range tx.TxOut {
+			if txOut.Version != 0 || len(txOut.PkScript) != len(pkScript)+1 ||
+				!bytes.Equal(txOut.PkScript[1:], pkScript) {
+				continue
+			}
+
+			// ticket outputs tagged with OP_SSTX can only be spent by votes
+			// and revocations and are not swept.
+			switch txOut.PkScript[0] {
+			case txscript.OP_SSGEN, txscript.OP_SSRTX:
+				add(tx, i, wire.TxTreeStake, txOut.PkScript, depth >= maturity.coinbase)
+			case txscript.OP_SSTXCHANGE:
+				add(tx, i, wire.TxTreeStake, txOut.PkScript, depth >= maturity.stakeChange)
+			}
+		}
+	}
+
+	for txIndex, tx := range block.Transactions {
+		spend(tx)
+
+		// the first regular transaction of a block is the coinbase.
+		mature := txIndex != 0 || depth >= maturity.coinbase
+		for i, txOut := range tx.TxOut {
+			if txOut.Version == 0 && bytes.Equal(txOut.PkScript, pkScript) {
+				add(tx, i, wire.TxTreeRegular, pkScript, mature)
+			}
+		}
+	}
+
+	return outputs
+}
@@ -0,0 +1,244 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/gcs"
+	"github.com/decred/dcrd/gcs/blockcf"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	w "github.com/decred/dcrwallet/wallet/v3"
+)
+
+// mockSweepChain is a main chain of blocks. Blocks without a cfilter are
+// fetched by findSweepOutputs without checking a filter.
+type mockSweepChain struct {
+	blocks   []*wire.MsgBlock
+	noFilter map[int32]bool
+}
+
+func (c *mockSweepChain) tipHeight(ctx context.Context) int32 {
+	return int32(len(c.blocks) - 1)
+}
+
+func (c *mockSweepChain) block(ctx context.Context, height int32) (*chainhash.Hash, *wire.BlockHeader, *gcs.Filter, error) {
+	block := c.blocks[height]
+	hash := block.BlockHash()
+	if c.noFilter[height] {
+		return &hash, &block.Header, nil, nil
+	}
+
+	filter, err := blockcf.Regular(block)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &hash, &block.Header, filter, nil
+}
+
+// mockSweepNetwork serves the blocks of a mockSweepChain and records the
+// heights of the blocks fetched.
+type mockSweepNetwork struct {
+	w.NetworkBackend
+	chain   *mockSweepChain
+	fetched []int32
+}
+
+func (n *mockSweepNetwork) Blocks(ctx context.Context, blockHashes []*chainhash.Hash) ([]*wire.MsgBlock, error) {
+	blocks := make([]*wire.MsgBlock, 0, len(blockHashes))
+	for _, hash := range blockHashes {
+		var found bool
+		for height, block := range n.chain.blocks {
+			if block.BlockHash() == *hash {
+				n.fetched = append(n.fetched, int32(height))
+				blocks = append(blocks, block)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown block %v", hash)
+		}
+	}
+	return blocks, nil
+}
+
+func sweepTestScript(b byte) []byte {
+	script := []byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20}
+	script = append(script, bytes.Repeat([]byte{b}, 20)...)
+	return append(script, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)
+}
+
+// sweepTestBlock returns a block at `height` with a coinbase paying to
+// `coinbaseScript` followed by `txs`, and the stake transactions `stxs`.
+func sweepTestBlock(height uint32, coinbaseScript []byte, txs, stxs []*wire.MsgTx) *wire.MsgBlock {
+	coinbase := wire.NewMsgTx()
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex, wire.TxTreeRegular), 0, nil))
+	coinbase.AddTxOut(wire.NewTxOut(int64(height)+1000, coinbaseScript))
+
+	block := &wire.MsgBlock{
+		Header:        wire.BlockHeader{Height: height},
+		Transactions:  append([]*wire.MsgTx{coinbase}, txs...),
+		STransactions: stxs,
+	}
+	return block
+}
+
+func sweepTestTx(spend *wire.OutPoint, pkScript []byte, amount int64) *wire.MsgTx {
+	tx := wire.NewMsgTx()
+	if spend == nil {
+		spend = wire.NewOutPoint(&chainhash.Hash{1}, 0, wire.TxTreeRegular)
+	}
+	tx.AddTxIn(wire.NewTxIn(spend, amount, nil))
+	tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+	return tx
+}
+
+func TestFindSweepOutputs(t *testing.T) {
+	sweptScript := sweepTestScript(0x01)
+	otherScript := sweepTestScript(0x02)
+
+	paySpentByRegularTx := sweepTestTx(nil, sweptScript, 100)
+	spentByRegularTx := &wire.OutPoint{Hash: paySpentByRegularTx.TxHash(), Index: 0}
+
+	paySpentByStakeTx := sweepTestTx(nil, sweptScript, 200)
+	spentByStakeTx := &wire.OutPoint{Hash: paySpentByStakeTx.TxHash(), Index: 0}
+
+	payUnspent := sweepTestTx(nil, sweptScript, 300)
+
+	chain := &mockSweepChain{
+		blocks: []*wire.MsgBlock{
+			sweepTestBlock(0, otherScript, nil, nil),
+			sweepTestBlock(1, otherScript, []*wire.MsgTx{paySpentByRegularTx, paySpentByStakeTx}, nil),
+			sweepTestBlock(2, otherScript, nil, nil),
+			sweepTestBlock(3, otherScript, []*wire.MsgTx{sweepTestTx(spentByRegularTx, otherScript, 100)}, nil),
+			sweepTestBlock(4, otherScript, nil, []*wire.MsgTx{sweepTestTx(spentByStakeTx, otherScript, 200)}),
+			sweepTestBlock(5, otherScript, []*wire.MsgTx{payUnspent}, nil),
+			// coinbase outputs are found but immature until the maturity.
+			sweepTestBlock(6, sweptScript, nil, nil),
+			sweepTestBlock(7, otherScript, nil, nil),
+		},
+		// the stake transaction is not a valid ticket purchase so its
+		// spend is not committed to the cfilter, fetch the block.
+		noFilter: map[int32]bool{4: true},
+	}
+
+	n := &mockSweepNetwork{chain: chain}
+	var progress []int32
+	maturity := sweepMaturity{coinbase: 2, stakeChange: 1}
+	outputs, err := findSweepOutputs(context.Background(), chain, n, sweptScript, 0, maturity, func(scannedHeight, tipHeight int32) {
+		progress = append(progress, scannedHeight)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outputs) != 2 || outputs[0].outpoint.Hash != payUnspent.TxHash() || outputs[0].amount != 300 ||
+		!outputs[0].mature || outputs[1].amount != 1006 || outputs[1].mature {
+		t.Fatalf("unexpected sweep outputs %+v", outputs)
+	}
+
+	// blocks 0, 2 and 7 do not match the script or the found outputs.
+	for _, height := range n.fetched {
+		if height == 0 || height == 2 || height == 7 {
+			t.Fatalf("block %d was fetched although its cfilter does not match", height)
+		}
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 7 {
+		t.Fatalf("scan progress %v did not end at the tip", progress)
+	}
+}
+
+func TestFindSweepOutputsStartHeight(t *testing.T) {
+	sweptScript := sweepTestScript(0x01)
+	otherScript := sweepTestScript(0x02)
+
+	chain := &mockSweepChain{
+		blocks: []*wire.MsgBlock{
+			sweepTestBlock(0, otherScript, nil, nil),
+			sweepTestBlock(1, otherScript, []*wire.MsgTx{sweepTestTx(nil, sweptScript, 100)}, nil),
+			sweepTestBlock(2, otherScript, []*wire.MsgTx{sweepTestTx(nil, sweptScript, 200)}, nil),
+		},
+		noFilter: map[int32]bool{0: true, 1: true},
+	}
+
+	n := &mockSweepNetwork{chain: chain}
+	maturity := sweepMaturity{coinbase: 2, stakeChange: 1}
+	outputs, err := findSweepOutputs(context.Background(), chain, n, sweptScript, 2, maturity, func(int32, int32) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// blocks before the start height are not scanned.
+	if len(outputs) != 1 || outputs[0].amount != 200 {
+		t.Fatalf("unexpected sweep outputs %+v", outputs)
+	}
+	for _, height := range n.fetched {
+		if height < 2 {
+			t.Fatalf("block %d before the start height was fetched", height)
+		}
+	}
+}
+
+func TestFindSweepOutputsStakeTree(t *testing.T) {
+	sweptScript := sweepTestScript(0x01)
+	otherScript := sweepTestScript(0x02)
+	tagged := func(opcode byte) []byte {
+		return append([]byte{opcode}, sweptScript...)
+	}
+
+	// a ticket paying its change to the key, the OP_SSTX output can only be
+	// spent by a vote and is not swept.
+	ticket := sweepTestTx(nil, tagged(txscript.OP_SSTX), 400)
+	ticket.AddTxOut(wire.NewTxOut(0, otherScript))
+	ticket.AddTxOut(wire.NewTxOut(50, tagged(txscript.OP_SSTXCHANGE)))
+	vote := sweepTestTx(nil, tagged(txscript.OP_SSGEN), 500)
+	revocation := sweepTestTx(nil, tagged(txscript.OP_SSRTX), 600)
+
+	chain := &mockSweepChain{
+		blocks: []*wire.MsgBlock{
+			sweepTestBlock(0, otherScript, nil, nil),
+			sweepTestBlock(1, otherScript, nil, []*wire.MsgTx{ticket, vote}),
+			sweepTestBlock(2, otherScript, nil, []*wire.MsgTx{revocation}),
+			sweepTestBlock(3, otherScript, nil, nil),
+		},
+		// the stake transactions are not valid, fetch their blocks.
+		noFilter: map[int32]bool{1: true, 2: true},
+	}
+
+	n := &mockSweepNetwork{chain: chain}
+	maturity := sweepMaturity{coinbase: 2, stakeChange: 1}
+	outputs, err := findSweepOutputs(context.Background(), chain, n, sweptScript, 0, maturity, func(int32, int32) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		hash     chainhash.Hash
+		index    uint32
+		amount   int64
+		mature   bool
+		pkScript []byte
+	}{
+		{ticket.TxHash(), 2, 50, true, tagged(txscript.OP_SSTXCHANGE)},
+		{vote.TxHash(), 0, 500, true, tagged(txscript.OP_SSGEN)},
+		// the revocation is one block deep, below the coinbase maturity.
+		{revocation.TxHash(), 0, 600, false, tagged(txscript.OP_SSRTX)},
+	}
+	if len(outputs) != len(expected) {
+		t.Fatalf("unexpected sweep outputs %+v", outputs)
+	}
+	for i, e := range expected {
+		output := outputs[i]
+		if output.outpoint.Hash != e.hash || output.outpoint.Index != e.index ||
+			output.outpoint.Tree != wire.TxTreeStake || output.amount != e.amount || output.mature != e.mature {
+			t.Fatalf("unexpected sweep output %d %+v", i, output)
+		}
+		if !bytes.Equal(output.pkScript, e.pkScript) {
+			t.Fatalf("sweep output %d is not signed with its stake tagged script", i)
+		}
+	}
+}
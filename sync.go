@@ -23,15 +23,20 @@ type syncData struct {
 	synced       bool
 	syncing      bool
 	cancelSync   context.CancelFunc
-	cancelRescan context.CancelFunc
+	spvSyncer    *spv.Syncer
 	syncCanceled chan bool
 
 	// Flag to notify syncCanceled callback if the sync was canceled so as to be restarted.
 	restartSyncRequested bool
 
-	rescanning     bool
+	// the running or queued rescan of each wallet, keyed by wallet id.
+	rescans        map[int]*walletRescan
 	connectedPeers int32
 
+	// header checkpoints set with SetHeaderCheckpoints, the default
+	// checkpoints of the network are used if nil.
+	checkpoints *spv.Checkpoints
+
 	*activeSyncData
 }
 
@@ -97,6 +102,9 @@ func (mw *MultiWallet) IsSyncProgressListenerRegisteredFor(uniqueIdentifier stri
 	return exists
 }
 
+// AddSyncProgressListener adds a listener that is called directly with the
+// progress of every sync stage. Only the start and end of syncs are also
+// published to subscribers as EventSyncStateChanged.
 func (mw *MultiWallet) AddSyncProgressListener(syncProgressListener SyncProgressListener, uniqueIdentifier string) error {
 	if mw.IsSyncProgressListenerRegisteredFor(uniqueIdentifier) {
 		return errors.New(ErrListenerAlreadyExist)
@@ -173,14 +181,35 @@ func (mw *MultiWallet) SyncInactiveForPeriod(totalInactiveSeconds int64) {
 	}
 }
 
+// SpvSync starts SPV sync for the wallets whose sync is not paused with
+// PauseWalletSync.
 func (mw *MultiWallet) SpvSync() error {
 	// prevent an attempt to sync when the previous syncing has not been canceled
 	if mw.IsSyncing() || mw.IsSynced() {
 		return errors.New(ErrSyncAlreadyInProgress)
 	}
 
+	mw.selectSyncWallets(nil)
+	return mw.spvSync()
+}
+
+// spvSync starts SPV sync for the wallets whose sync is not paused or
+// skipped.
+func (mw *MultiWallet) spvSync() error {
+	if mw.IsSyncing() || mw.IsSynced() {
+		return errors.New(ErrSyncAlreadyInProgress)
+	}
+
+	// host names of peers are resolved through the proxy, if any, to avoid
+	// leaking lookups outside the proxy.
+	proxy := mw.Proxy()
+	lookup := net.LookupIP
+	if proxy != nil {
+		lookup = proxy.lookupIP
+	}
+
 	addr := &net.TCPAddr{IP: net.ParseIP("::1"), Port: 0}
-	addrManager := addrmgr.New(mw.rootDir, net.LookupIP) // TODO: be mindful of tor
+	addrManager := addrmgr.New(mw.rootDir, lookup)
 	lp := p2p.NewLocalPeer(mw.chainParams, addr, addrManager)
 
 	var validPeerAddresses []string
@@ -201,23 +230,67 @@ func (mw *MultiWallet) SpvSync() error {
 		}
 	}
 
+	// peers cannot be found through a proxy that cannot resolve the DNS
+	// seeds, persistent peers must be set instead.
+	if proxy != nil {
+		if err := proxy.checkPeers(validPeerAddresses); err != nil {
+			return err
+		}
+	}
+
 	// init activeSyncData to be used to hold data used
 	// to calculate sync estimates only during sync
 	mw.initActiveSyncData()
 
 	wallets := make(map[int]*w.Wallet)
 	for id, wallet := range mw.wallets {
+		if wallet.isSyncPaused() {
+			continue
+		}
 		wallets[id] = wallet.internal
+		wallet.syncStateMu.Lock()
 		wallet.waiting = true
 		wallet.syncing = true
+		wallet.syncStateMu.Unlock()
+	}
+	if len(wallets) == 0 {
+		return errors.New(ErrInvalid)
 	}
 
 	syncer := spv.NewSyncer(wallets, lp)
 	syncer.SetNotifications(mw.spvSyncNotificationCallbacks())
+	syncer.SetBirthdays(mw.walletBirthdays())
 	if len(validPeerAddresses) > 0 {
 		syncer.SetPersistentPeers(validPeerAddresses)
 	}
 
+	bannedPeers, err := mw.bannedPeers()
+	if err != nil {
+		return err
+	}
+	syncer.SetBannedPeers(bannedPeers)
+
+	mw.syncData.mu.RLock()
+	checkpoints := mw.syncData.checkpoints
+	mw.syncData.mu.RUnlock()
+	if checkpoints == nil {
+		checkpoints, err = spv.DefaultCheckpoints(mw.chainParams.Name)
+		if err != nil {
+			// sync without checkpoints rather than not at all.
+			log.Errorf("Invalid default header checkpoints: %v", err)
+		}
+	}
+	if checkpoints != nil {
+		syncer.SetCheckpoints(checkpoints)
+	}
+
+	if proxy != nil {
+		syncer.SetDialFunc(proxy.socksProxy("").DialContext)
+		if proxy.IsTor {
+			syncer.SetSeeder(proxy.seeder(mw))
+		}
+	}
+
 	mw.setNetworkBackend(syncer)
 
 	ctx, cancel := mw.contextWithShutdownCancel()
@@ -229,11 +302,13 @@ func (mw *MultiWallet) SpvSync() error {
 	mw.syncData.restartSyncRequested = false
 	mw.syncData.syncing = true
 	mw.syncData.cancelSync = cancel
+	mw.syncData.spvSyncer = syncer
 	mw.syncData.mu.Unlock()
 
 	for _, listener := range mw.syncProgressListeners() {
 		listener.OnSyncStarted(restartSyncRequested)
 	}
+	mw.publishSyncStateChanged()
 
 	// syncer.Run uses a wait group to block the thread until the sync context
 	// expires or is canceled or some other error occurs such as
@@ -258,13 +333,218 @@ func (mw *MultiWallet) SpvSync() error {
 	return nil
 }
 
+// SpvSyncWallets starts SPV sync for only the wallets with ids in
+// `walletIDs` whose sync is not paused with PauseWalletSync. The other
+// wallets are left out of this sync only, unlike PauseWalletSync which is
+// saved, and are synced again when SpvSync is next called. Use
+// ResumeWalletSync to add a wallet to the running sync.
+func (mw *MultiWallet) SpvSyncWallets(walletIDs []int) error {
+	if len(walletIDs) == 0 {
+		return errors.New(ErrInvalid)
+	}
+
+	syncWallets := make(map[int]bool, len(walletIDs))
+	for _, walletID := range walletIDs {
+		if mw.WalletWithID(walletID) == nil {
+			return errors.New(ErrNotExist)
+		}
+		syncWallets[walletID] = true
+	}
+
+	if mw.IsSyncing() || mw.IsSynced() {
+		return errors.New(ErrSyncAlreadyInProgress)
+	}
+
+	mw.selectSyncWallets(syncWallets)
+	return mw.spvSync()
+}
+
+// selectSyncWallets skips the sync of the wallets that are not in
+// `syncWallets`. No wallet is skipped if `syncWallets` is nil.
+func (mw *MultiWallet) selectSyncWallets(syncWallets map[int]bool) {
+	for id, wallet := range mw.wallets {
+		wallet.syncPausedMu.Lock()
+		wallet.syncSkipped = syncWallets != nil && !syncWallets[id]
+		wallet.syncPausedMu.Unlock()
+	}
+}
+
+// PauseWalletSync stops the SPV sync of a wallet without restarting the sync
+// of the other wallets. The pause is saved in the wallet's config and the
+// wallet is left out when sync is next started, until ResumeWalletSync is
+// called. At least one wallet must remain syncing.
+func (mw *MultiWallet) PauseWalletSync(walletID int) error {
+	wallet := mw.WalletWithID(walletID)
+	if wallet == nil {
+		return errors.New(ErrNotExist)
+	}
+
+	wallet.syncPausedMu.RLock()
+	paused, skipped := wallet.syncPaused, wallet.syncSkipped
+	wallet.syncPausedMu.RUnlock()
+	if paused {
+		return nil
+	}
+	if err := wallet.setSyncPaused(true); err != nil {
+		return err
+	}
+	if skipped {
+		// the wallet is not part of the running sync.
+		return nil
+	}
+
+	syncer, err := mw.spvSyncer()
+	if err == nil {
+		if err = syncer.RemoveWallet(walletID); err != nil {
+			if resetErr := wallet.setSyncPaused(false); resetErr != nil {
+				log.Errorf("[%d] Error resetting sync pause: %v", walletID, resetErr)
+			}
+			return translateError(err)
+		}
+		if wallet.WalletOpened() {
+			wallet.internal.SetNetworkBackend(nil)
+		}
+	}
+
+	wallet.syncStateMu.Lock()
+	wallet.synced = false
+	wallet.syncing = false
+	wallet.waiting = false
+	wallet.syncStateMu.Unlock()
+
+	if syncer != nil {
+		// the sync is completed if the paused wallet was the only wallet
+		// that was not synced.
+		mw.syncData.mu.RLock()
+		allWalletsSynced := mw.syncData.synced
+		mw.syncData.mu.RUnlock()
+		if !allWalletsSynced {
+			mw.checkWalletsSynced(true)
+		}
+	}
+
+	return nil
+}
+
+// ResumeWalletSync resumes the SPV sync of a wallet paused with
+// PauseWalletSync or left out by SpvSyncWallets. If sync is running, the
+// wallet is added to the running sync, its missing headers are fetched and
+// it is rescanned without restarting the sync of the other wallets.
+func (mw *MultiWallet) ResumeWalletSync(walletID int) error {
+	wallet := mw.WalletWithID(walletID)
+	if wallet == nil {
+		return errors.New(ErrNotExist)
+	}
+
+	wallet.syncPausedMu.RLock()
+	paused, skipped := wallet.syncPaused, wallet.syncSkipped
+	wallet.syncPausedMu.RUnlock()
+	if !paused && !skipped {
+		return nil
+	}
+
+	syncer, err := mw.spvSyncer()
+	if err != nil {
+		// the wallet is synced when sync is next started.
+		return wallet.setSyncPaused(false)
+	}
+
+	if !wallet.WalletOpened() {
+		return errors.New(ErrWalletNotLoaded)
+	}
+
+	if err = wallet.setSyncPaused(false); err != nil {
+		return err
+	}
+
+	wallet.syncStateMu.Lock()
+	wallet.synced = false
+	wallet.syncing = true
+	wallet.waiting = true
+	wallet.syncStateMu.Unlock()
+
+	mw.syncData.mu.Lock()
+	wasSynced, wasSyncing := mw.syncData.synced, mw.syncData.syncing
+	mw.syncData.synced = false
+	mw.syncData.syncing = true
+	mw.syncData.mu.Unlock()
+	mw.publishSyncStateChanged()
+
+	wallet.internal.SetNetworkBackend(&spv.WalletBackend{
+		Syncer:   syncer,
+		WalletID: walletID,
+	})
+
+	if err = syncer.AddWallet(walletID, wallet.internal, wallet.spvBirthday()); err != nil {
+		// restore the state the wallet and the sync were in before the
+		// wallet was resumed.
+		wallet.internal.SetNetworkBackend(nil)
+		wallet.syncStateMu.Lock()
+		wallet.syncing = false
+		wallet.waiting = false
+		wallet.syncStateMu.Unlock()
+
+		wallet.syncPausedMu.Lock()
+		wallet.syncSkipped = skipped
+		wallet.syncPausedMu.Unlock()
+		if paused {
+			if resetErr := wallet.setSyncPaused(true); resetErr != nil {
+				log.Errorf("[%d] Error resetting sync pause: %v", walletID, resetErr)
+			}
+		}
+
+		mw.syncData.mu.Lock()
+		mw.syncData.synced = wasSynced
+		mw.syncData.syncing = wasSyncing
+		mw.syncData.mu.Unlock()
+		mw.publishSyncStateChanged()
+
+		return translateError(err)
+	}
+
+	return nil
+}
+
+// IsSyncPaused returns true if the wallet is not synced by SPV sync, either
+// because its sync was paused with PauseWalletSync or because it was left out
+// of the current sync by SpvSyncWallets.
+func (wallet *Wallet) IsSyncPaused() bool {
+	return wallet.isSyncPaused()
+}
+
+func (wallet *Wallet) isSyncPaused() bool {
+	wallet.syncPausedMu.RLock()
+	defer wallet.syncPausedMu.RUnlock()
+	return wallet.syncPaused || wallet.syncSkipped
+}
+
+// setSyncPaused saves whether the wallet's sync is paused in the wallet's
+// config. The wallet is no longer skipped if it was left out of the current
+// sync by SpvSyncWallets.
+func (wallet *Wallet) setSyncPaused(paused bool) error {
+	if wallet.setUserConfigValue == nil {
+		return errors.New(ErrFailedPrecondition)
+	}
+
+	wallet.syncPausedMu.Lock()
+	defer wallet.syncPausedMu.Unlock()
+
+	if err := wallet.setUserConfigValue(SyncPausedConfigKey, paused); err != nil {
+		return err
+	}
+	wallet.syncPaused = paused
+	wallet.syncSkipped = false
+	return nil
+}
+
+// RestartSpvSync restarts SPV sync for the wallets that were being synced.
 func (mw *MultiWallet) RestartSpvSync() error {
 	mw.syncData.mu.Lock()
 	mw.syncData.restartSyncRequested = true
 	mw.syncData.mu.Unlock()
 
 	mw.CancelSync() // necessary to unset the network backend.
-	return mw.SpvSync()
+	return mw.spvSync()
 }
 
 func (mw *MultiWallet) CancelSync() {
@@ -298,14 +578,28 @@ func (mw *MultiWallet) CancelSync() {
 }
 
 func (wallet *Wallet) IsWaiting() bool {
+	wallet.syncStateMu.RLock()
+	defer wallet.syncStateMu.RUnlock()
 	return wallet.waiting
 }
 
+// setWaiting sets whether the wallet is waiting for the headers fetch to
+// reach its chain tip.
+func (wallet *Wallet) setWaiting(waiting bool) {
+	wallet.syncStateMu.Lock()
+	wallet.waiting = waiting
+	wallet.syncStateMu.Unlock()
+}
+
 func (wallet *Wallet) IsSynced() bool {
+	wallet.syncStateMu.RLock()
+	defer wallet.syncStateMu.RUnlock()
 	return wallet.synced
 }
 
 func (wallet *Wallet) IsSyncing() bool {
+	wallet.syncStateMu.RLock()
+	defer wallet.syncStateMu.RUnlock()
 	return wallet.syncing
 }
 
@@ -0,0 +1,62 @@
+package dcrlibwallet
+
+import (
+	"errors"
+	"testing"
+)
+
+// testConfig is an in-memory wallet config.
+type testConfig map[string]interface{}
+
+func (config testConfig) set(key string, value interface{}) error {
+	config[key] = value
+	return nil
+}
+
+func TestSyncPauseSaved(t *testing.T) {
+	config := make(testConfig)
+	wallet := &Wallet{ID: 1, setUserConfigValue: config.set}
+
+	if err := wallet.setSyncPaused(true); err != nil {
+		t.Fatal(err)
+	}
+	if !wallet.IsSyncPaused() || config[SyncPausedConfigKey] != true {
+		t.Fatal("sync pause was not saved")
+	}
+
+	// the pause is kept if it could not be saved.
+	wallet.setUserConfigValue = func(key string, value interface{}) error {
+		return errors.New("save failed")
+	}
+	if err := wallet.setSyncPaused(false); err == nil {
+		t.Fatal("expected an error saving the sync pause")
+	}
+	if !wallet.IsSyncPaused() {
+		t.Fatal("sync resumed although the change was not saved")
+	}
+}
+
+func TestSelectSyncWallets(t *testing.T) {
+	config := make(testConfig)
+	paused := &Wallet{ID: 1, setUserConfigValue: config.set}
+	if err := paused.setSyncPaused(true); err != nil {
+		t.Fatal(err)
+	}
+	selected := &Wallet{ID: 2}
+	other := &Wallet{ID: 3}
+	mw := &MultiWallet{wallets: map[int]*Wallet{1: paused, 2: selected, 3: other}}
+
+	mw.selectSyncWallets(map[int]bool{2: true})
+	if !paused.IsSyncPaused() || selected.IsSyncPaused() || !other.IsSyncPaused() {
+		t.Fatal("only the selected wallet should be synced")
+	}
+
+	// selecting wallets does not change the saved pause of other wallets.
+	mw.selectSyncWallets(nil)
+	if !paused.IsSyncPaused() || selected.IsSyncPaused() || other.IsSyncPaused() {
+		t.Fatal("all wallets that are not paused should be synced")
+	}
+	if config[SyncPausedConfigKey] != true {
+		t.Fatal("saved sync pause changed")
+	}
+}
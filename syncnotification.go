@@ -28,6 +28,43 @@ func (mw *MultiWallet) spvSyncNotificationCallbacks() *spv.Notifications {
 		RescanStarted:                mw.rescanStarted,
 		RescanProgress:               mw.rescanProgress,
 		RescanFinished:               mw.rescanFinished,
+		BirthdayHeightResolved:       mw.birthdayHeightResolved,
+	}
+}
+
+// walletBirthdays returns the birthdays of the wallets that have one, for use
+// by the SPV syncer.
+func (mw *MultiWallet) walletBirthdays() map[int]*spv.Birthday {
+	birthdays := make(map[int]*spv.Birthday)
+	for id, wallet := range mw.wallets {
+		if birthday := wallet.spvBirthday(); birthday != nil {
+			birthdays[id] = birthday
+		}
+	}
+	return birthdays
+}
+
+// spvBirthday returns the birthday of the wallet for use by the SPV syncer
+// or nil if the wallet has no birthday.
+func (wallet *Wallet) spvBirthday() *spv.Birthday {
+	if wallet.BirthdayHeight <= 0 && wallet.BirthdayTimestamp <= 0 {
+		return nil
+	}
+	return &spv.Birthday{
+		Height:    wallet.BirthdayHeight,
+		Timestamp: wallet.BirthdayTimestamp,
+	}
+}
+
+func (mw *MultiWallet) birthdayHeightResolved(walletID int, height int32) {
+	wallet := mw.WalletWithID(walletID)
+	if wallet == nil {
+		return
+	}
+
+	wallet.BirthdayHeight = height
+	if err := mw.db.Save(wallet); err != nil {
+		log.Errorf("[%d] Error saving wallet birthday height: %v", walletID, err)
 	}
 }
 
@@ -69,7 +106,7 @@ func (mw *MultiWallet) fetchHeadersStarted(peerInitialHeight int32) {
 	}
 
 	for _, wallet := range mw.wallets {
-		wallet.waiting = true
+		wallet.setWaiting(true)
 	}
 
 	lowestBlockHeight := mw.GetLowestBlock().Height
@@ -101,9 +138,12 @@ func (mw *MultiWallet) fetchHeadersProgress(lastFetchedHeaderHeight int32, lastF
 	}
 
 	for _, wallet := range mw.wallets {
+		bestBlock := wallet.GetBestBlock()
+		wallet.syncStateMu.Lock()
 		if wallet.waiting {
-			wallet.waiting = wallet.GetBestBlock() > lastFetchedHeaderHeight
+			wallet.waiting = bestBlock > lastFetchedHeaderHeight
 		}
+		wallet.syncStateMu.Unlock()
 	}
 
 	headersLeftToFetch := mw.estimateBlockHeadersCountAfter(lastFetchedHeaderTime)
@@ -517,11 +557,14 @@ func (mw *MultiWallet) resetSyncData() {
 	mw.syncData.syncing = false
 	mw.syncData.synced = false
 	mw.syncData.cancelSync = nil
+	mw.syncData.spvSyncer = nil
 	mw.syncData.activeSyncData = nil
 	mw.syncData.mu.Unlock()
 
+	mw.publishSyncStateChanged()
+
 	for _, wallet := range mw.wallets {
-		wallet.waiting = true
+		wallet.setWaiting(true)
 		wallet.LockWallet() // lock wallet if previously unlocked to perform account discovery.
 	}
 }
@@ -536,16 +579,33 @@ func (mw *MultiWallet) synced(walletID int, synced bool) {
 	}
 
 	wallet := mw.wallets[walletID]
+	wallet.syncStateMu.Lock()
 	wallet.synced = synced
 	wallet.syncing = false
+	wallet.syncStateMu.Unlock()
 	wallet.LockWallet() // lock wallet if previously unlocked to perform account discovery.
 
-	if mw.OpenedWalletsCount() == mw.SyncedWalletsCount() {
+	mw.checkWalletsSynced(synced)
+}
+
+// checkWalletsSynced marks the sync as completed if all opened wallets that
+// are not paused are synced.
+func (mw *MultiWallet) checkWalletsSynced(synced bool) {
+	var pausedWallets int32
+	for _, wallet := range mw.wallets {
+		if wallet.WalletOpened() && wallet.isSyncPaused() && !wallet.IsSynced() {
+			pausedWallets++
+		}
+	}
+
+	if mw.OpenedWalletsCount() == mw.SyncedWalletsCount()+pausedWallets {
 		mw.syncData.mu.Lock()
 		mw.syncData.syncing = false
 		mw.syncData.synced = true
 		mw.syncData.mu.Unlock()
 
+		mw.publishSyncStateChanged()
+
 		// begin indexing transactions after sync is completed,
 		// syncProgressListeners.OnSynced() will be invoked after transactions are indexed
 		var txIndexing errgroup.Group
@@ -178,6 +178,21 @@ func (wallet *Wallet) TicketPrice(ctx context.Context) (*TicketPriceResponse, er
 
 // PurchaseTickets purchases tickets from the wallet. Returns a slice of hashes for tickets purchased
 func (wallet *Wallet) PurchaseTickets(ctx context.Context, request *PurchaseTicketsRequest, vspHost string) ([]string, error) {
+	lock := make(chan time.Time, 1)
+	defer func() {
+		lock <- time.Time{} // send matters, not the value
+	}()
+	err := wallet.internal.Unlock(ctx, request.Passphrase, lock)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return wallet.purchaseTickets(ctx, request, vspHost)
+}
+
+// purchaseTickets validates the ticket purchase request and purchases the
+// requested tickets. The wallet must be unlocked before this is called.
+func (wallet *Wallet) purchaseTickets(ctx context.Context, request *PurchaseTicketsRequest, vspHost string) ([]string, error) {
 	var err error
 
 	// fetch redeem script, ticket address, pool address and pool fee if vsp host isn't empty
@@ -236,15 +251,6 @@ func (wallet *Wallet) PurchaseTickets(ctx context.Context, request *PurchaseTick
 		return nil, errors.New("Negative fees per KB given")
 	}
 
-	lock := make(chan time.Time, 1)
-	defer func() {
-		lock <- time.Time{} // send matters, not the value
-	}()
-	err = wallet.internal.Unlock(ctx, request.Passphrase, lock)
-	if err != nil {
-		return nil, translateError(err)
-	}
-
 	purchaseTicketsRequest := &w.PurchaseTicketsRequest{
 		Count:         numTickets,
 		SourceAccount: request.Account,
@@ -266,6 +272,9 @@ func (wallet *Wallet) PurchaseTickets(ctx context.Context, request *PurchaseTick
 	hashes := make([]string, len(purchasedTickets))
 	for i, hash := range purchasedTickets {
 		hashes[i] = hash.String()
+		if vspHost != "" {
+			wallet.saveVSPTicketInfo(hashes[i], &VSPTicketInfo{VSPHost: vspHost})
+		}
 	}
 
 	return hashes, nil
@@ -283,7 +292,7 @@ func (wallet *Wallet) updateTicketPurchaseRequestWithVSPInfo(vspHost string, req
 	}
 
 	// invoke vsp api
-	ticketPurchaseInfo, err := CallVSPTicketInfoAPI(vspHost, pubKeyAddr)
+	ticketPurchaseInfo, err := callVSPTicketInfoAPI(wallet.httpClient(), vspHost, pubKeyAddr)
 	if err != nil {
 		return fmt.Errorf("vsp connection error: %s", err.Error())
 	}
@@ -294,13 +303,8 @@ func (wallet *Wallet) updateTicketPurchaseRequestWithVSPInfo(vspHost string, req
 		return fmt.Errorf("invalid vsp purchase ticket response: %s", err.Error())
 	}
 
-	ctx := wallet.shutdownContext()
-
-	// unlock wallet and import the decoded script
-	lock := make(chan time.Time, 1)
-	wallet.internal.Unlock(ctx, request.Passphrase, lock)
-	err = wallet.internal.ImportScript(ctx, rs)
-	lock <- time.Time{}
+	// import the decoded script, the wallet is expected to be unlocked at this point
+	err = wallet.internal.ImportScript(wallet.shutdownContext(), rs)
 	if err != nil && !errors.Is(errors.Exist, err) {
 		return fmt.Errorf("error importing vsp redeem script: %s", err.Error())
 	}
@@ -312,7 +316,13 @@ func (wallet *Wallet) updateTicketPurchaseRequestWithVSPInfo(vspHost string, req
 	return nil
 }
 
-func CallVSPTicketInfoAPI(vspHost, pubKeyAddr string) (ticketPurchaseInfo *VSPTicketPurchaseInfo, err error) {
+// CallVSPTicketInfoAPI requests the ticket purchase info of `pubKeyAddr` from
+// the VSP at `vspHost` through the saved proxy, if any.
+func (mw *MultiWallet) CallVSPTicketInfoAPI(vspHost, pubKeyAddr string) (ticketPurchaseInfo *VSPTicketPurchaseInfo, err error) {
+	return callVSPTicketInfoAPI(mw.HTTPClient(), vspHost, pubKeyAddr)
+}
+
+func callVSPTicketInfoAPI(client *http.Client, vspHost, pubKeyAddr string) (ticketPurchaseInfo *VSPTicketPurchaseInfo, err error) {
 	apiUrl := fmt.Sprintf("%s/api/v2/purchaseticket", strings.TrimSuffix(vspHost, "/"))
 	data := url.Values{}
 	data.Set("UserPubKeyAddr", pubKeyAddr)
@@ -324,23 +334,30 @@ func CallVSPTicketInfoAPI(vspHost, pubKeyAddr string) (ticketPurchaseInfo *VSPTi
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
 
-	var apiResponse map[string]interface{}
+	var apiResponse struct {
+		Status  string                 `json:"status"`
+		Message string                 `json:"message"`
+		Data    *VSPTicketPurchaseInfo `json:"data"`
+	}
 	err = json.NewDecoder(resp.Body).Decode(&apiResponse)
-	if err == nil {
-		data := apiResponse["data"].(map[string]interface{})
-		ticketPurchaseInfo = &VSPTicketPurchaseInfo{
-			Script:        data["Script"].(string),
-			PoolFees:      data["PoolFees"].(float64),
-			PoolAddress:   data["PoolAddress"].(string),
-			TicketAddress: data["TicketAddress"].(string),
-		}
+	if err != nil {
+		return nil, fmt.Errorf("invalid vsp response: %v", err)
+	}
+
+	if apiResponse.Status != "success" || apiResponse.Data == nil {
+		return nil, fmt.Errorf("vsp error: %s", apiResponse.Message)
+	}
+
+	ticketPurchaseInfo = apiResponse.Data
+	if ticketPurchaseInfo.Script == "" || ticketPurchaseInfo.TicketAddress == "" {
+		return nil, fmt.Errorf("invalid vsp response: missing redeem script or ticket address")
 	}
+
 	return
 }
@@ -0,0 +1,425 @@
+package dcrlibwallet
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/stake/v2"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+// reading/writing of properties of this struct are protected by mutex.
+type ticketBuyerData struct {
+	mu sync.RWMutex
+
+	config    *TicketBuyerConfig
+	cancel    context.CancelFunc
+	listeners map[string]TicketBuyerNotificationListener
+
+	// generation is incremented each time the ticket buyer is started so
+	// that a stopped ticket buyer does not clear the state of a ticket buyer
+	// started after it.
+	generation uint64
+}
+
+func (wallet *Wallet) AddTicketBuyerNotificationListener(listener TicketBuyerNotificationListener, uniqueIdentifier string) error {
+	wallet.ticketBuyer.mu.Lock()
+	defer wallet.ticketBuyer.mu.Unlock()
+
+	if _, ok := wallet.ticketBuyer.listeners[uniqueIdentifier]; ok {
+		return errors.New(ErrListenerAlreadyExist)
+	}
+
+	wallet.ticketBuyer.listeners[uniqueIdentifier] = listener
+	return nil
+}
+
+func (wallet *Wallet) RemoveTicketBuyerNotificationListener(uniqueIdentifier string) {
+	wallet.ticketBuyer.mu.Lock()
+	delete(wallet.ticketBuyer.listeners, uniqueIdentifier)
+	wallet.ticketBuyer.mu.Unlock()
+}
+
+// TicketBuyerConfig returns the ticket buyer config previously saved for this
+// wallet or nil if the ticket buyer was never configured.
+func (wallet *Wallet) TicketBuyerConfig() *TicketBuyerConfig {
+	cfg := &TicketBuyerConfig{}
+	err := wallet.ReadUserConfigValue(TicketBuyerConfigKey, cfg)
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// StartTicketBuyer saves `cfg` to the wallet config and starts purchasing
+// tickets with every new block for as long as the wallet is synced and has
+// more than `cfg.BalanceToMaintain` spendable in the purchase account. The
+// wallet is unlocked with `privPass` for each purchase and locked again after
+// the purchase, as the passphrase is only held in memory.
+// The ticket buyer is restarted automatically when the wallet is next opened
+// until StopTicketBuyer is called, but since the passphrase is not saved, the
+// resumed ticket buyer only purchases tickets while the wallet is unlocked.
+// Call StopTicketBuyer and StartTicketBuyer to provide the passphrase again.
+func (wallet *Wallet) StartTicketBuyer(cfg *TicketBuyerConfig, privPass []byte) error {
+	defer func() {
+		for i := range privPass {
+			privPass[i] = 0
+		}
+	}()
+
+	if wallet.IsWatchingOnlyWallet() {
+		return errors.New(ErrWalletIsWatchOnly)
+	}
+
+	err := wallet.validateTicketBuyerConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	// the ticket buyer is reserved before the passphrase is checked so that
+	// concurrent calls cannot start more than one ticket buyer.
+	ctx, generation, err := wallet.reserveTicketBuyer(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = wallet.saveTicketBuyerConfig(cfg, privPass)
+	if err != nil {
+		wallet.releaseTicketBuyer(generation)
+		return err
+	}
+
+	passphrase := make([]byte, len(privPass))
+	copy(passphrase, privPass)
+	wallet.runTicketBuyer(ctx, generation, cfg, passphrase)
+	return nil
+}
+
+// saveTicketBuyerConfig checks that `privPass` is the wallet's private
+// passphrase and saves `cfg` as the config of an enabled ticket buyer.
+func (wallet *Wallet) saveTicketBuyerConfig(cfg *TicketBuyerConfig, privPass []byte) error {
+	err := wallet.verifyPrivatePassphrase(privPass)
+	if err != nil {
+		return err
+	}
+
+	if err = wallet.setUserConfigValue(TicketBuyerConfigKey, cfg); err != nil {
+		return err
+	}
+	return wallet.setUserConfigValue(TicketBuyerEnabledConfigKey, true)
+}
+
+// StopTicketBuyer stops the ticket buyer if it is running and prevents it
+// from being restarted when the wallet is next opened.
+func (wallet *Wallet) StopTicketBuyer() error {
+	wallet.ticketBuyer.mu.Lock()
+	cancel := wallet.ticketBuyer.cancel
+	wallet.ticketBuyer.cancel = nil
+	wallet.ticketBuyer.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	return wallet.setUserConfigValue(TicketBuyerEnabledConfigKey, false)
+}
+
+func (wallet *Wallet) IsTicketBuyerRunning() bool {
+	wallet.ticketBuyer.mu.RLock()
+	defer wallet.ticketBuyer.mu.RUnlock()
+	return wallet.ticketBuyer.cancel != nil
+}
+
+// resumeTicketBuyer restarts the ticket buyer using the previously saved
+// config if the ticket buyer was running when the wallet was last closed.
+func (wallet *Wallet) resumeTicketBuyer() {
+	if !wallet.ReadBoolConfigValueForKey(TicketBuyerEnabledConfigKey, false) {
+		return
+	}
+
+	cfg := wallet.TicketBuyerConfig()
+	if cfg == nil {
+		return
+	}
+
+	if err := wallet.validateTicketBuyerConfig(cfg); err != nil {
+		log.Errorf("[%d] Saved ticket buyer config is invalid: %v", wallet.ID, err)
+		return
+	}
+
+	ctx, generation, err := wallet.reserveTicketBuyer(cfg)
+	if err != nil {
+		return
+	}
+
+	log.Infof("[%d] Resuming ticket buyer, tickets are only purchased while the wallet is unlocked", wallet.ID)
+	wallet.runTicketBuyer(ctx, generation, cfg, nil)
+}
+
+func (wallet *Wallet) validateTicketBuyerConfig(cfg *TicketBuyerConfig) error {
+	if cfg == nil || cfg.BalanceToMaintain < 0 || cfg.Expiry < 0 || cfg.MaxFeeRate < 0 {
+		return errors.New(ErrInvalid)
+	}
+
+	if _, err := wallet.AccountNameRaw(uint32(cfg.PurchaseAccount)); err != nil {
+		return translateError(err)
+	}
+
+	if cfg.VSPHost != "" {
+		if _, err := wallet.vspPubKey(cfg.VSPHost, cfg.VSPPubKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reserveTicketBuyer marks the ticket buyer as running with `cfg` and returns
+// the context that stops it. ErrTicketBuyerRunning is returned if the ticket
+// buyer is already running.
+func (wallet *Wallet) reserveTicketBuyer(cfg *TicketBuyerConfig) (context.Context, uint64, error) {
+	wallet.ticketBuyer.mu.Lock()
+	defer wallet.ticketBuyer.mu.Unlock()
+
+	if wallet.ticketBuyer.cancel != nil {
+		return nil, 0, errors.New(ErrTicketBuyerRunning)
+	}
+
+	ctx, cancel := wallet.shutdownContextWithCancel()
+	wallet.ticketBuyer.generation++
+	wallet.ticketBuyer.config = cfg
+	wallet.ticketBuyer.cancel = cancel
+	return ctx, wallet.ticketBuyer.generation, nil
+}
+
+// releaseTicketBuyer clears the ticket buyer reserved with `generation`
+// unless it was stopped and another ticket buyer was started since.
+func (wallet *Wallet) releaseTicketBuyer(generation uint64) {
+	wallet.ticketBuyer.mu.Lock()
+	defer wallet.ticketBuyer.mu.Unlock()
+
+	if wallet.ticketBuyer.generation == generation && wallet.ticketBuyer.cancel != nil {
+		wallet.ticketBuyer.cancel()
+		wallet.ticketBuyer.cancel = nil
+	}
+}
+
+func (wallet *Wallet) runTicketBuyer(ctx context.Context, generation uint64, cfg *TicketBuyerConfig, passphrase []byte) {
+	go func() {
+		n := wallet.internal.NtfnServer.MainTipChangedNotifications()
+		defer n.Done() // disassociate this notification client from server when this function exits.
+
+		log.Infof("[%d] Ticket buyer started", wallet.ID)
+		wallet.publishTicketBuyerStarted()
+
+		// only notify the listeners once if the wallet remains locked
+		// over several blocks.
+		var lockedErrorPublished bool
+
+		for {
+			select {
+			case <-ctx.Done():
+				for i := range passphrase {
+					passphrase[i] = 0
+				}
+
+				// the ticket buyer may have been restarted since this
+				// ticket buyer was stopped.
+				wallet.releaseTicketBuyer(generation)
+
+				log.Infof("[%d] Ticket buyer stopped", wallet.ID)
+				wallet.publishTicketBuyerStopped()
+				return
+
+			case v := <-n.C:
+				if !wallet.IsSynced() {
+					continue
+				}
+
+				if passphrase == nil && wallet.IsLocked() {
+					if !lockedErrorPublished {
+						wallet.publishTicketBuyerError(errors.New(ErrWalletLocked))
+						lockedErrorPublished = true
+					}
+					continue
+				}
+				lockedErrorPublished = false
+
+				err := wallet.buyTickets(ctx, cfg, passphrase, v.NewHeight)
+				if err != nil && ctx.Err() == nil {
+					log.Errorf("[%d] Ticket buyer error: %v", wallet.ID, err)
+					wallet.publishTicketBuyerError(err)
+				}
+			}
+		}
+	}()
+}
+
+// buyTickets purchases as many tickets as the spendable balance of the
+// purchase account allows while keeping `cfg.BalanceToMaintain` in the account.
+// If `passphrase` is set and the wallet is locked, the wallet is unlocked for
+// the purchase and locked again after the purchase.
+func (wallet *Wallet) buyTickets(ctx context.Context, cfg *TicketBuyerConfig, passphrase []byte, tipHeight int32) error {
+	if cfg.MaxFeeRate > 0 {
+		ticketFeeRate, err := wallet.estimateTicketFeeRate(ctx)
+		if err != nil {
+			log.Debugf("[%d] Ticket buyer: unable to estimate ticket fee rate: %v", wallet.ID, err)
+			return nil
+		}
+		if ticketFeeRate > dcrutil.Amount(cfg.MaxFeeRate) {
+			return errors.Errorf("ticket fee rate %v/kB exceeds max fee rate %v/kB", ticketFeeRate, dcrutil.Amount(cfg.MaxFeeRate))
+		}
+	}
+
+	ticketPrice, err := wallet.internal.NextStakeDifficulty(ctx)
+	if err != nil {
+		// ticket price is not known for this block yet, try again at the next block.
+		log.Debugf("[%d] Ticket buyer: unable to determine ticket price: %v", wallet.ID, err)
+		return nil
+	}
+
+	spendable, err := wallet.SpendableForAccount(cfg.PurchaseAccount, DefaultRequiredConfirmations)
+	if err != nil {
+		return err
+	}
+
+	availableBalance := spendable - cfg.BalanceToMaintain
+	if ticketPrice <= 0 || availableBalance < int64(ticketPrice) {
+		return nil
+	}
+
+	numTickets := availableBalance / int64(ticketPrice)
+	if maxTickets := int64(wallet.chainParams.MaxFreshStakePerBlock); numTickets > maxTickets {
+		numTickets = maxTickets
+	}
+
+	request := &PurchaseTicketsRequest{
+		Account:               uint32(cfg.PurchaseAccount),
+		RequiredConfirmations: DefaultRequiredConfirmations,
+		NumTickets:            uint32(numTickets),
+	}
+	if cfg.Expiry > 0 {
+		request.Expiry = uint32(tipHeight + cfg.Expiry)
+	}
+
+	if passphrase != nil && wallet.IsLocked() {
+		lock := make(chan time.Time, 1)
+		defer func() {
+			lock <- time.Time{} // send matters, not the value
+		}()
+		err = wallet.internal.Unlock(ctx, passphrase, lock)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+
+	log.Infof("[%d] Ticket buyer purchasing %d ticket(s) at %v", wallet.ID, numTickets, ticketPrice)
+	var ticketHashes []string
+	if cfg.VSPHost == "" {
+		ticketHashes, err = wallet.purchaseTickets(ctx, request, "")
+	} else {
+		ticketHashes, err = wallet.buyTicketsWithVSP(ctx, cfg, request)
+	}
+	if len(ticketHashes) > 0 {
+		wallet.publishTicketsPurchased(ticketHashes)
+	}
+	return err
+}
+
+// buyTicketsWithVSP checks that the VSP is available before purchasing any
+// tickets and registers the purchased tickets with the VSP.
+func (wallet *Wallet) buyTicketsWithVSP(ctx context.Context, cfg *TicketBuyerConfig, request *PurchaseTicketsRequest) ([]string, error) {
+	client, err := wallet.vspClient(ctx, cfg.VSPHost, cfg.VSPPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet.purchaseTicketsWithVSP(ctx, request, client, cfg.VSPHost, cfg.PurchaseAccount)
+}
+
+// estimateTicketFeeRate returns the median fee rate paid by the tickets mined
+// in the main chain tip block, which is the rate that the ticket buyer's
+// tickets compete with. The wallet's ticket fee is returned if the block has
+// no tickets.
+func (wallet *Wallet) estimateTicketFeeRate(ctx context.Context) (dcrutil.Amount, error) {
+	n, err := wallet.internal.NetworkBackend()
+	if err != nil {
+		return 0, err
+	}
+
+	tipHash, _ := wallet.internal.MainChainTip(ctx)
+	blocks, err := n.Blocks(ctx, []*chainhash.Hash{&tipHash})
+	if err != nil {
+		return 0, err
+	}
+	if len(blocks) == 0 {
+		return 0, errors.New(ErrNotExist)
+	}
+
+	var feeRates []dcrutil.Amount
+	for _, tx := range blocks[0].STransactions {
+		if !stake.IsSStx(tx) {
+			continue
+		}
+
+		// ticket inputs commit to the amount they spend so the fee is
+		// known without looking up the previous outputs.
+		var fee int64
+		for _, txIn := range tx.TxIn {
+			fee += txIn.ValueIn
+		}
+		for _, txOut := range tx.TxOut {
+			fee -= txOut.Value
+		}
+		feeRates = append(feeRates, dcrutil.Amount(fee*1000/int64(tx.SerializeSize())))
+	}
+
+	if len(feeRates) == 0 {
+		return wallet.internal.TicketFeeIncrement(), nil
+	}
+
+	sort.Slice(feeRates, func(i, j int) bool { return feeRates[i] < feeRates[j] })
+	return feeRates[len(feeRates)/2], nil
+}
+
+func (wallet *Wallet) ticketBuyerListeners() []TicketBuyerNotificationListener {
+	wallet.ticketBuyer.mu.RLock()
+	defer wallet.ticketBuyer.mu.RUnlock()
+
+	listeners := make([]TicketBuyerNotificationListener, 0, len(wallet.ticketBuyer.listeners))
+	for _, listener := range wallet.ticketBuyer.listeners {
+		listeners = append(listeners, listener)
+	}
+
+	return listeners
+}
+
+func (wallet *Wallet) publishTicketBuyerStarted() {
+	for _, listener := range wallet.ticketBuyerListeners() {
+		listener.OnTicketBuyerStarted(wallet.ID)
+	}
+}
+
+func (wallet *Wallet) publishTicketsPurchased(ticketHashes []string) {
+	jsonEncoded, _ := json.Marshal(&ticketHashes)
+	for _, listener := range wallet.ticketBuyerListeners() {
+		listener.OnTicketsPurchased(wallet.ID, string(jsonEncoded))
+	}
+}
+
+func (wallet *Wallet) publishTicketBuyerError(err error) {
+	for _, listener := range wallet.ticketBuyerListeners() {
+		listener.OnTicketBuyerError(wallet.ID, err)
+	}
+}
+
+func (wallet *Wallet) publishTicketBuyerStopped() {
+	for _, listener := range wallet.ticketBuyerListeners() {
+		listener.OnTicketBuyerStopped(wallet.ID)
+	}
+}
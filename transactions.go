@@ -1,10 +1,11 @@
 package dcrlibwallet
 
 import (
+	"container/heap"
 	"encoding/json"
-	"sort"
 
 	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrwallet/errors/v2"
 	"github.com/raedahgroup/dcrlibwallet/txhelper"
 	"github.com/raedahgroup/dcrlibwallet/txindex"
 )
@@ -82,27 +83,39 @@ func (wallet *Wallet) GetTransactionsRaw(offset, limit, txFilter int32, newestFi
 	return
 }
 
+// GetTransactions returns `limit` transactions of all opened wallets from
+// `offset` in the combined, ordered transaction history of the wallets.
+// GetTransactionsPage should be preferred for paging through transactions as
+// it does not read the `offset` skipped transactions of every wallet.
 func (mw *MultiWallet) GetTransactions(offset, limit, txFilter int32, newestFirst bool) (string, error) {
-	transactions := make([]Transaction, 0)
-	for _, wallet := range mw.wallets {
-		walletTransactions, err := wallet.GetTransactionsRaw(offset, limit, txFilter, newestFirst)
-		if err != nil {
-			return "", nil
-		}
+	if offset < 0 {
+		offset = 0
+	}
 
-		transactions = append(transactions, walletTransactions...)
+	// any wallet may hold all of the first offset+limit transactions.
+	var walletLimit int32
+	if limit > 0 {
+		walletLimit = offset + limit
 	}
 
-	// sort transaction by timestamp in descending order
-	sort.Slice(transactions[:], func(i, j int) bool {
-		if newestFirst {
-			return transactions[i].Timestamp > transactions[j].Timestamp
+	walletTransactions := make([][]Transaction, 0, len(mw.wallets))
+	for _, wallet := range mw.wallets {
+		if !wallet.WalletOpened() {
+			continue
+		}
+
+		transactions, err := wallet.GetTransactionsRaw(0, walletLimit, txFilter, newestFirst)
+		if err != nil {
+			return "", err
 		}
-		return transactions[i].Timestamp < transactions[j].Timestamp
-	})
+		walletTransactions = append(walletTransactions, transactions)
+	}
 
-	if len(transactions) > int(limit) && limit > 0 {
-		transactions = transactions[:limit]
+	transactions := mergeTransactions(walletTransactions, newestFirst, int(walletLimit))
+	if int(offset) < len(transactions) {
+		transactions = transactions[offset:]
+	} else {
+		transactions = []Transaction{}
 	}
 
 	jsonEncodedTransactions, err := json.Marshal(&transactions)
@@ -113,6 +126,160 @@ func (mw *MultiWallet) GetTransactions(offset, limit, txFilter int32, newestFirs
 	return string(jsonEncodedTransactions), nil
 }
 
+// GetTransactionsPage returns up to `limit` transactions of the wallets with
+// the specified ids, or of all opened wallets if `walletIDs` is empty, that
+// come after `cursor` in the combined transaction history of the wallets.
+// Pass an empty cursor to get the first page and the returned NextCursor to
+// get the next page.
+func (mw *MultiWallet) GetTransactionsPage(cursor string, limit, txFilter int32, newestFirst bool, walletIDs []int) (*TxQueryResult, error) {
+	query := txindex.TxQueryForFilter(txFilter)
+	query.Cursor = cursor
+	query.Limit = limit
+	query.NewestFirst = newestFirst
+	query.WalletIDs = walletIDs
+	return mw.QueryTransactions(query)
+}
+
+// TxQuery describes the transactions returned by QueryTransactions.
+type TxQuery = txindex.TxQuery
+
+// QueryTransactions returns a page of the transactions of this wallet that
+// match `query`. Pass the returned NextCursor as `query.Cursor` to get the
+// next page.
+func (wallet *Wallet) QueryTransactions(query *TxQuery) (*TxQueryResult, error) {
+	transactions := make([]Transaction, 0)
+	nextCursor, err := wallet.txDB.Query(query, &transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TxQueryResult{
+		Transactions: transactions,
+		NextCursor:   nextCursor,
+	}, nil
+}
+
+// QueryTransactions returns a page of the transactions of all opened wallets
+// that match `query`, ordered across wallets. Only the wallets in
+// `query.WalletIDs` are queried if it is set.
+func (mw *MultiWallet) QueryTransactions(query *TxQuery) (*TxQueryResult, error) {
+	wallets := make([]*Wallet, 0, len(mw.wallets))
+	if len(query.WalletIDs) > 0 {
+		for _, walletID := range query.WalletIDs {
+			wallet := mw.WalletWithID(walletID)
+			if wallet == nil {
+				return nil, errors.New(ErrNotExist)
+			}
+			wallets = append(wallets, wallet)
+		}
+	} else {
+		for _, wallet := range mw.wallets {
+			wallets = append(wallets, wallet)
+		}
+	}
+
+	walletTransactions := make([][]Transaction, 0, len(wallets))
+	var hasMore bool
+	for _, wallet := range wallets {
+		if !wallet.WalletOpened() {
+			continue
+		}
+
+		// each wallet returns up to query.Limit transactions after the
+		// cursor, enough to fill the page after merging.
+		result, err := wallet.QueryTransactions(query)
+		if err != nil {
+			return nil, err
+		}
+
+		walletTransactions = append(walletTransactions, result.Transactions)
+		hasMore = hasMore || result.NextCursor != ""
+	}
+
+	transactions := mergeTransactions(walletTransactions, query.NewestFirst, 0)
+	if query.Limit > 0 && len(transactions) > int(query.Limit) {
+		transactions = transactions[:query.Limit]
+		hasMore = true
+	}
+
+	var nextCursor string
+	if hasMore && len(transactions) > 0 {
+		nextCursor = txindex.CursorFor(&transactions[len(transactions)-1]).Encode()
+	}
+
+	return &TxQueryResult{
+		Transactions: transactions,
+		NextCursor:   nextCursor,
+	}, nil
+}
+
+// mergeTransactions merges lists of transactions, each ordered as returned by
+// txindex queries, into one ordered list of up to `limit` transactions. All
+// transactions are returned if `limit` is not positive.
+func mergeTransactions(lists [][]Transaction, newestFirst bool, limit int) []Transaction {
+	txHeap := &transactionsHeap{newestFirst: newestFirst}
+	var total int
+	for _, list := range lists {
+		if len(list) > 0 {
+			txHeap.lists = append(txHeap.lists, list)
+			total += len(list)
+		}
+	}
+	heap.Init(txHeap)
+
+	if limit <= 0 || limit > total {
+		limit = total
+	}
+
+	merged := make([]Transaction, 0, limit)
+	for len(merged) < limit {
+		list := txHeap.lists[0]
+		merged = append(merged, list[0])
+
+		if len(list) > 1 {
+			txHeap.lists[0] = list[1:]
+			heap.Fix(txHeap, 0)
+		} else {
+			heap.Pop(txHeap)
+		}
+	}
+
+	return merged
+}
+
+// transactionsHeap implements heap.Interface for ordered lists of
+// transactions, ordered by the first transaction of each list.
+type transactionsHeap struct {
+	lists       [][]Transaction
+	newestFirst bool
+}
+
+func (h *transactionsHeap) Len() int {
+	return len(h.lists)
+}
+
+func (h *transactionsHeap) Less(i, j int) bool {
+	before := txindex.CursorFor(&h.lists[i][0]).Before(txindex.CursorFor(&h.lists[j][0]))
+	if h.newestFirst {
+		return !before
+	}
+	return before
+}
+
+func (h *transactionsHeap) Swap(i, j int) {
+	h.lists[i], h.lists[j] = h.lists[j], h.lists[i]
+}
+
+func (h *transactionsHeap) Push(list interface{}) {
+	h.lists = append(h.lists, list.([]Transaction))
+}
+
+func (h *transactionsHeap) Pop() interface{} {
+	last := h.lists[len(h.lists)-1]
+	h.lists = h.lists[:len(h.lists)-1]
+	return last
+}
+
 func (wallet *Wallet) CountTransactions(txFilter int32) (int, error) {
 	return wallet.txDB.Count(txFilter, &Transaction{})
 }
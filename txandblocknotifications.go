@@ -1,9 +1,11 @@
 package dcrlibwallet
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/raedahgroup/dcrlibwallet/txhelper"
 )
 
 func (mw *MultiWallet) listenForTransactions(walletID int) {
@@ -11,85 +13,171 @@ func (mw *MultiWallet) listenForTransactions(walletID int) {
 	n := wallet.internal.NtfnServer.TransactionNotifications()
 	defer n.Done() // disassociate this notification client from server when this function exits.
 
-	for {
-		v := <-n.C
-
+	for v := range n.C {
 		for _, transaction := range v.UnminedTransactions {
 			tempTransaction, err := wallet.decodeTransactionWithTxSummary(&transaction, nil)
 			if err != nil {
-				log.Errorf("[%d] Error ntfn parse tx: %v", wallet.ID, err)
-				return
+				log.Errorf("[%d] Error ntfn parse tx %v: %v", wallet.ID, transaction.Hash, err)
+				continue
 			}
 
 			overwritten, err := wallet.txDB.SaveOrUpdate(&Transaction{}, tempTransaction)
 			if err != nil {
 				log.Errorf("[%d] New Tx save err: %v", wallet.ID, err)
-				return
+				continue
 			}
 
 			if !overwritten {
 				log.Infof("[%d] New Transaction %s", wallet.ID, tempTransaction.Hash)
 
-				result, err := json.Marshal(tempTransaction)
-				if err != nil {
-					log.Error(err)
-				} else {
-					mw.mempoolTransactionNotification(string(result))
+				mw.publishEvent(&Event{
+					Type:        EventNewTransaction,
+					WalletID:    wallet.ID,
+					Transaction: tempTransaction,
+				})
+				mw.publishTicketStatusChanged(tempTransaction)
+			}
+		}
+
+		if len(v.DetachedBlocks) > 0 {
+			lowestHeight := int32(v.DetachedBlocks[0].Height)
+			for _, header := range v.DetachedBlocks {
+				if int32(header.Height) < lowestHeight {
+					lowestHeight = int32(header.Height)
 				}
 			}
+
+			mw.publishEvent(&Event{
+				Type:        EventReorg,
+				WalletID:    wallet.ID,
+				BlockHeight: lowestHeight,
+				ReorgDepth:  int32(len(v.DetachedBlocks)),
+			})
 		}
 
 		for _, block := range v.AttachedBlocks {
 			blockHash := block.Header.BlockHash()
+			blockHeight := int32(block.Header.Height)
 			for _, transaction := range block.Transactions {
 				tempTransaction, err := wallet.decodeTransactionWithTxSummary(&transaction, &blockHash)
 				if err != nil {
-					log.Errorf("[%d] Error ntfn parse tx: %v", wallet.ID, err)
-					return
+					log.Errorf("[%d] Error ntfn parse tx %v: %v", wallet.ID, transaction.Hash, err)
+					continue
 				}
 
 				_, err = wallet.txDB.SaveOrUpdate(&Transaction{}, tempTransaction)
 				if err != nil {
 					log.Errorf("[%d] Incoming block replace tx error :%v", wallet.ID, err)
-					return
+					continue
 				}
-				mw.publishTransactionConfirmed(wallet.ID, transaction.Hash.String(), int32(block.Header.Height))
+
+				mw.publishEvent(&Event{
+					Type:        EventTransactionConfirmed,
+					WalletID:    wallet.ID,
+					Transaction: tempTransaction,
+					BlockHeight: blockHeight,
+				})
+				mw.publishTicketStatusChanged(tempTransaction)
 			}
 
-			mw.publishBlockAttached(wallet.ID, int32(block.Header.Height))
+			mw.publishEvent(&Event{
+				Type:        EventBlockAttached,
+				WalletID:    wallet.ID,
+				BlockHeight: blockHeight,
+			})
+		}
+
+		for _, balance := range v.NewBalances {
+			mw.publishEvent(&Event{
+				Type:         EventBalanceChanged,
+				WalletID:     wallet.ID,
+				Account:      int32(balance.Account),
+				TotalBalance: int64(balance.TotalBalance),
+			})
 		}
 	}
 }
 
+// publishTicketStatusChanged publishes an EventTicketStatusChanged event if
+// `tx` is a ticket purchase, vote or revocation.
+func (mw *MultiWallet) publishTicketStatusChanged(tx *Transaction) {
+	var ticketHash string
+	switch tx.Type {
+	case txhelper.TxTypeTicketPurchase:
+		ticketHash = tx.Hash
+	case txhelper.TxTypeVote:
+		// the ticket is spent by the second input of a vote.
+		if len(tx.Inputs) > 1 {
+			ticketHash = tx.Inputs[1].PreviousTransactionHash
+		}
+	case txhelper.TxTypeRevocation:
+		if len(tx.Inputs) > 0 {
+			ticketHash = tx.Inputs[0].PreviousTransactionHash
+		}
+	}
+
+	if ticketHash == "" {
+		return
+	}
+
+	mw.publishEvent(&Event{
+		Type:        EventTicketStatusChanged,
+		WalletID:    tx.WalletID,
+		Transaction: tx,
+		BlockHeight: tx.BlockHeight,
+		TicketHash:  ticketHash,
+		TxType:      tx.Type,
+	})
+}
+
+// AddTxAndBlockNotificationListener subscribes `txAndBlockNotificationListener`
+// to new transaction, transaction confirmed and block attached events.
 func (mw *MultiWallet) AddTxAndBlockNotificationListener(txAndBlockNotificationListener TxAndBlockNotificationListener, uniqueIdentifier string) error {
+	mw.notificationListenersMu.Lock()
+	defer mw.notificationListenersMu.Unlock()
+
 	_, ok := mw.txAndBlockNotificationListeners[uniqueIdentifier]
 	if ok {
 		return errors.New(ErrListenerAlreadyExist)
 	}
 
-	mw.txAndBlockNotificationListeners[uniqueIdentifier] = txAndBlockNotificationListener
+	ctx, cancel := context.WithCancel(context.Background())
+	// listeners added using this method expect every notification, so events
+	// are queued instead of dropped while the listener is busy.
+	events := mw.Subscribe(ctx, &EventFilter{
+		Types:              []EventType{EventNewTransaction, EventTransactionConfirmed, EventBlockAttached},
+		SlowConsumerPolicy: Unbounded,
+	})
+
+	mw.txAndBlockNotificationListeners[uniqueIdentifier] = cancel
+
+	go func() {
+		for event := range events {
+			switch event.Type {
+			case EventNewTransaction:
+				result, err := json.Marshal(event.Transaction)
+				if err != nil {
+					log.Error(err)
+				} else {
+					txAndBlockNotificationListener.OnTransaction(string(result))
+				}
+			case EventTransactionConfirmed:
+				txAndBlockNotificationListener.OnTransactionConfirmed(event.WalletID, event.Transaction.Hash, event.BlockHeight)
+			case EventBlockAttached:
+				txAndBlockNotificationListener.OnBlockAttached(event.WalletID, event.BlockHeight)
+			}
+		}
+	}()
 
 	return nil
 }
 
 func (mw *MultiWallet) RemoveTxAndBlockNotificationListener(uniqueIdentifier string) {
-	delete(mw.txAndBlockNotificationListeners, uniqueIdentifier)
-}
-
-func (mw *MultiWallet) mempoolTransactionNotification(transaction string) {
-	for _, txAndBlockNotifcationListener := range mw.txAndBlockNotificationListeners {
-		txAndBlockNotifcationListener.OnTransaction(transaction)
-	}
-}
-
-func (mw *MultiWallet) publishTransactionConfirmed(walletID int, transactionHash string, blockHeight int32) {
-	for _, txAndBlockNotifcationListener := range mw.txAndBlockNotificationListeners {
-		txAndBlockNotifcationListener.OnTransactionConfirmed(walletID, transactionHash, blockHeight)
-	}
-}
+	mw.notificationListenersMu.Lock()
+	defer mw.notificationListenersMu.Unlock()
 
-func (mw *MultiWallet) publishBlockAttached(walletID int, blockHeight int32) {
-	for _, txAndBlockNotifcationListener := range mw.txAndBlockNotificationListeners {
-		txAndBlockNotifcationListener.OnBlockAttached(walletID, blockHeight)
+	if cancel, ok := mw.txAndBlockNotificationListeners[uniqueIdentifier]; ok {
+		cancel()
+		delete(mw.txAndBlockNotificationListeners, uniqueIdentifier)
 	}
 }
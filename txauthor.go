@@ -19,22 +19,97 @@ type TxAuthor struct {
 	sendFromAccount       uint32
 	destinations          []TransactionDestination
 	requiredConfirmations int32
+	inputs                []string
+	lockedInputs          []wire.OutPoint
+	feeRate               dcrutil.Amount
+	allowHighFees         bool
 	wallet                *Wallet
+
+	// multisig is set for TxAuthors created with NewUnsignedMultisigTx to
+	// spend the outputs of a multisig address.
+	multisig *MultisigAddress
+
+	// multiWallet is set for TxAuthors created with MultiWallet.NewUnsignedTx
+	// to look up destinations in the address book.
+	multiWallet *MultiWallet
 }
 
+// NewUnsignedTx creates a TxAuthor that uses the fee rate of the fee rate
+// preset selected in the MultiWallet config, or the relay fee if the wallet
+// was not loaded by a MultiWallet.
 func (wallet *Wallet) NewUnsignedTx(sourceAccountNumber, requiredConfirmations int32) *TxAuthor {
+	feeRate := txrules.DefaultRelayFeePerKb
+	if wallet.selectedFeeRate != nil {
+		feeRate = dcrutil.Amount(wallet.selectedFeeRate())
+	}
+
 	return &TxAuthor{
 		sendFromAccount:       uint32(sourceAccountNumber),
 		destinations:          make([]TransactionDestination, 0),
 		requiredConfirmations: requiredConfirmations,
+		feeRate:               feeRate,
 		wallet:                wallet,
 	}
 }
 
+// SetFeeRate sets the fee rate in atoms/kB used to estimate the fee of, and
+// to create this transaction. The rate must not be below the relay fee and
+// must not be above MaxFeeRateAtomsPerKB unless high fees are allowed.
+func (tx *TxAuthor) SetFeeRate(atomsPerKB int64) error {
+	if err := validateFeeRate(atomsPerKB, tx.allowHighFees); err != nil {
+		return err
+	}
+
+	tx.feeRate = dcrutil.Amount(atomsPerKB)
+	return nil
+}
+
+func (tx *TxAuthor) FeeRate() int64 {
+	return int64(tx.feeRate)
+}
+
+// AllowHighFees permits fee rates above MaxFeeRateAtomsPerKB to be used.
+func (tx *TxAuthor) AllowHighFees(allow bool) {
+	tx.allowHighFees = allow
+}
+
 func (tx *TxAuthor) SetSourceAccount(accountNumber int32) {
 	tx.sendFromAccount = uint32(accountNumber)
 }
 
+// UseInputs restricts the inputs of this transaction to the provided
+// outpoints. Each outpoint is identified by the `hash:index` key returned
+// in UnspentOutput.OutputKey and must be an unspent output of the source
+// account. All the selected outputs are spent by the transaction. Calling
+// UseInputs with no outpoints lets the wallet select inputs again.
+//
+// The selected outputs are locked once the transaction is estimated or
+// constructed so that they are not spent by other transactions. They are
+// unlocked when the transaction is broadcast or when UseInputs is called
+// again, callers that discard this transaction should call UseInputs with
+// no outpoints to unlock them.
+func (tx *TxAuthor) UseInputs(outpointKeys []string) error {
+	inputs := make([]string, 0, len(outpointKeys))
+	seen := make(map[string]bool, len(outpointKeys))
+	for _, key := range outpointKeys {
+		outpoint, err := parseOutpointKey(key)
+		if err != nil {
+			return err
+		}
+
+		key = outpointKey(outpoint)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		inputs = append(inputs, key)
+	}
+
+	tx.releaseInputs()
+	tx.inputs = inputs
+	return nil
+}
+
 func (tx *TxAuthor) AddSendDestination(address string, atomAmount int64, sendMax bool) {
 	tx.destinations = append(tx.destinations, TransactionDestination{
 		Address:    address,
@@ -57,13 +132,23 @@ func (tx *TxAuthor) RemoveSendDestination(index int) {
 	}
 }
 
+// DestinationContactName returns the address book name of the destination
+// address at `index`, or an empty string if the address is not in the
+// address book.
+func (tx *TxAuthor) DestinationContactName(index int) string {
+	if tx.multiWallet == nil || index < 0 || index >= len(tx.destinations) {
+		return ""
+	}
+	return tx.multiWallet.ContactNameForAddress(tx.destinations[index].Address)
+}
+
 func (tx *TxAuthor) EstimateFeeAndSize() (*TxFeeAndSize, error) {
 	unsignedTx, err := tx.constructTransaction()
 	if err != nil {
 		return nil, translateError(err)
 	}
 
-	feeToSendTx := txrules.FeeForSerializeSize(txrules.DefaultRelayFeePerKb, unsignedTx.EstimatedSignedSerializeSize)
+	feeToSendTx := txrules.FeeForSerializeSize(tx.feeRate, unsignedTx.EstimatedSignedSerializeSize)
 	feeAmount := &Amount{
 		AtomValue: int64(feeToSendTx),
 		DcrValue:  feeToSendTx.ToCoin(),
@@ -81,12 +166,27 @@ func (tx *TxAuthor) EstimateMaxSendAmount() (*Amount, error) {
 		return nil, err
 	}
 
-	spendableAccountBalance, err := tx.wallet.SpendableForAccount(int32(tx.sendFromAccount), tx.requiredConfirmations)
-	if err != nil {
-		return nil, err
+	var spendableAmount int64
+	if tx.multisig != nil {
+		_, multisigAmount, err := tx.multisigInputsSource(true)
+		if err != nil {
+			return nil, err
+		}
+		spendableAmount = int64(multisigAmount)
+	} else if len(tx.inputs) > 0 {
+		_, selectedAmount, err := tx.selectedInputsSource()
+		if err != nil {
+			return nil, err
+		}
+		spendableAmount = int64(selectedAmount)
+	} else {
+		spendableAmount, err = tx.wallet.SpendableForAccount(int32(tx.sendFromAccount), tx.requiredConfirmations)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	maxSendableAmount := spendableAccountBalance - txFeeAndSize.Fee.AtomValue
+	maxSendableAmount := spendableAmount - txFeeAndSize.Fee.AtomValue
 
 	return &Amount{
 		AtomValue: maxSendableAmount,
@@ -101,6 +201,16 @@ func (tx *TxAuthor) Broadcast(privatePassphrase []byte) ([]byte, error) {
 		}
 	}()
 
+	if tx.multisig != nil {
+		return nil, fmt.Errorf("multisig transactions must be signed by the cosigners, use ExportUnsigned")
+	}
+
+	// the fee rate may have been set before high fees were disallowed.
+	err := validateFeeRate(int64(tx.feeRate), tx.allowHighFees)
+	if err != nil {
+		return nil, err
+	}
+
 	n, err := tx.wallet.internal.NetworkBackend()
 	if err != nil {
 		log.Error(err)
@@ -176,6 +286,18 @@ func (tx *TxAuthor) Broadcast(privatePassphrase []byte) ([]byte, error) {
 	if err != nil {
 		return nil, translateError(err)
 	}
+
+	// the selected inputs are spent by the published transaction.
+	tx.releaseInputs()
+
+	if tx.multiWallet != nil {
+		addresses := make([]string, len(tx.destinations))
+		for i, destination := range tx.destinations {
+			addresses[i] = destination.Address
+		}
+		tx.multiWallet.markAddressBookEntriesUsed(addresses)
+	}
+
 	return txHash[:], nil
 }
 
@@ -210,6 +332,14 @@ func (tx *TxAuthor) constructTransaction() (*txauthor.AuthoredTx, error) {
 			}
 
 			continue // do not prepare a tx output for this destination
+		} else if tx.multisig != nil {
+			// return change to the multisig address so that it remains
+			// controlled by the cosigners.
+			changeSource, err = txhelper.MakeTxChangeSource(tx.multisig.Address, tx.wallet.chainParams)
+			if err != nil {
+				log.Errorf("constructTransaction: error preparing change source: %v", err)
+				return nil, fmt.Errorf("change source error: %v", err)
+			}
 		} else {
 			address, err := tx.wallet.internal.NewChangeAddress(ctx, tx.sendFromAccount)
 			if err != nil {
@@ -232,6 +362,24 @@ func (tx *TxAuthor) constructTransaction() (*txauthor.AuthoredTx, error) {
 		outputs = append(outputs, output)
 	}
 
-	return tx.wallet.internal.NewUnsignedTransaction(ctx, outputs, txrules.DefaultRelayFeePerKb, tx.sendFromAccount,
+	if tx.multisig != nil {
+		inputSource, _, err := tx.multisigInputsSource(outputSelectionAlgorithm == w.OutputSelectionAlgorithmAll)
+		if err != nil {
+			return nil, err
+		}
+
+		return txauthor.NewUnsignedTransaction(outputs, tx.feeRate, inputSource, changeSource)
+	}
+
+	if len(tx.inputs) > 0 {
+		inputSource, _, err := tx.selectedInputsSource()
+		if err != nil {
+			return nil, err
+		}
+
+		return txauthor.NewUnsignedTransaction(outputs, tx.feeRate, inputSource, changeSource)
+	}
+
+	return tx.wallet.internal.NewUnsignedTransaction(ctx, outputs, tx.feeRate, tx.sendFromAccount,
 		tx.requiredConfirmations, outputSelectionAlgorithm, changeSource)
 }
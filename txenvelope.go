@@ -0,0 +1,279 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+// ExportUnsigned creates this transaction without signing it and returns a
+// json encoded UnsignedTransaction envelope that can be signed by a wallet
+// that has no network backend using `SignTransactionEnvelope`. The
+// transaction can be created from a watch-only wallet.
+func (tx *TxAuthor) ExportUnsigned() (string, error) {
+	err := validateFeeRate(int64(tx.feeRate), tx.allowHighFees)
+	if err != nil {
+		return "", err
+	}
+
+	unsignedTx, err := tx.constructTransaction()
+	if err != nil {
+		return "", translateError(err)
+	}
+
+	if unsignedTx.ChangeIndex >= 0 {
+		unsignedTx.RandomizeChangePosition()
+	}
+
+	var txBuf bytes.Buffer
+	txBuf.Grow(unsignedTx.Tx.SerializeSize())
+	err = unsignedTx.Tx.Serialize(&txBuf)
+	if err != nil {
+		log.Error(err)
+		return "", err
+	}
+
+	var totalOutputAmount int64
+	for _, txOut := range unsignedTx.Tx.TxOut {
+		totalOutputAmount += txOut.Value
+	}
+
+	envelope := &UnsignedTransaction{
+		UnsignedTransaction:       txBuf.Bytes(),
+		EstimatedSignedSize:       unsignedTx.EstimatedSignedSerializeSize,
+		ChangeIndex:               unsignedTx.ChangeIndex,
+		TotalOutputAmount:         totalOutputAmount,
+		TotalPreviousOutputAmount: int64(unsignedTx.TotalInput),
+		Network:                   tx.wallet.chainParams.Name,
+		PreviousOutputScripts:     unsignedTx.PrevScripts,
+	}
+
+	if tx.multisig != nil {
+		envelope.MultisigRedeemScript, err = hex.DecodeString(tx.multisig.RedeemScript)
+		if err != nil {
+			return "", err
+		}
+		envelope.MultisigRequiredSignatures = tx.multisig.RequiredSignatures
+	}
+
+	result, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
+
+// SignTransactionEnvelope signs the transaction in a json encoded
+// UnsignedTransaction envelope created by `TxAuthor.ExportUnsigned` and
+// returns the serialized signed transaction. The previous output scripts in
+// the envelope are used for signing so this wallet does not need to be
+// synced or connected to the network.
+//
+// If the envelope spends multisig outputs, this wallet's signatures are
+// added to the transaction and the updated json encoded envelope is
+// returned instead, to be signed by the next cosigner or published with
+// `PublishTransactionEnvelope` once it has the required signatures.
+func (wallet *Wallet) SignTransactionEnvelope(envelope string, privPass []byte) ([]byte, error) {
+	defer func() {
+		for i := range privPass {
+			privPass[i] = 0
+		}
+	}()
+
+	if wallet.IsWatchingOnlyWallet() {
+		return nil, errors.New(ErrWalletIsWatchOnly)
+	}
+
+	var unsignedTx UnsignedTransaction
+	err := json.Unmarshal([]byte(envelope), &unsignedTx)
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	if unsignedTx.Network != wallet.chainParams.Name {
+		return nil, fmt.Errorf("transaction is for %s, wallet is on %s", unsignedTx.Network, wallet.chainParams.Name)
+	}
+
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(unsignedTx.UnsignedTransaction))
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	if len(unsignedTx.PreviousOutputScripts) != len(msgTx.TxIn) {
+		return nil, fmt.Errorf("expected %d previous output scripts, envelope has %d",
+			len(msgTx.TxIn), len(unsignedTx.PreviousOutputScripts))
+	}
+
+	additionalPkScripts := make(map[wire.OutPoint][]byte, len(msgTx.TxIn))
+	for i, txIn := range msgTx.TxIn {
+		additionalPkScripts[txIn.PreviousOutPoint] = unsignedTx.PreviousOutputScripts[i]
+	}
+
+	lock := make(chan time.Time, 1)
+	defer func() {
+		lock <- time.Time{}
+	}()
+
+	ctx := wallet.shutdownContext()
+	err = wallet.internal.Unlock(ctx, privPass, lock)
+	if err != nil {
+		log.Error(err)
+		return nil, errors.New(ErrInvalidPassphrase)
+	}
+
+	if unsignedTx.MultisigRedeemScript != nil {
+		// the redeem script must be imported for the wallet to sign the
+		// multisig inputs.
+		err = wallet.internal.ImportScript(ctx, unsignedTx.MultisigRedeemScript)
+		if err != nil && !errors.Is(errors.Exist, err) {
+			return nil, fmt.Errorf("error importing multisig redeem script: %v", err)
+		}
+	}
+
+	signaturesBefore, err := envelopeSignatureCounts(&msgTx, &unsignedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	invalidSigs, err := wallet.internal.SignTransaction(ctx, &msgTx, txscript.SigHashAll, additionalPkScripts, nil, nil)
+	if err != nil {
+		log.Error(err)
+		return nil, translateError(err)
+	}
+
+	if unsignedTx.MultisigRedeemScript != nil {
+		return wallet.updateMultisigEnvelope(&msgTx, &unsignedTx, signaturesBefore)
+	}
+
+	if len(invalidSigs) > 0 {
+		invalidInputIndexes := make([]uint32, len(invalidSigs))
+		for i, e := range invalidSigs {
+			invalidInputIndexes[i] = e.InputIndex
+		}
+		return nil, fmt.Errorf("unable to sign inputs %v", invalidInputIndexes)
+	}
+
+	var signedTx bytes.Buffer
+	signedTx.Grow(msgTx.SerializeSize())
+	err = msgTx.Serialize(&signedTx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return signedTx.Bytes(), nil
+}
+
+// PublishSignedTransaction publishes a serialized signed transaction and
+// returns its hash. It can be used with watch-only wallets to publish
+// transactions signed using `SignTransactionEnvelope`.
+func (wallet *Wallet) PublishSignedTransaction(signedTx []byte) ([]byte, error) {
+	n, err := wallet.internal.NetworkBackend()
+	if err != nil {
+		log.Error(err)
+		return nil, errors.New(ErrNotConnected)
+	}
+
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(signedTx))
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	txHash, err := wallet.internal.PublishTransaction(wallet.shutdownContext(), &msgTx, signedTx, n)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return txHash[:], nil
+}
+
+// updateMultisigEnvelope returns `envelope` json encoded with its
+// transaction replaced by the partially signed `msgTx`. An error is returned
+// if this wallet did not add a signature to every input that still needed
+// signatures.
+func (wallet *Wallet) updateMultisigEnvelope(msgTx *wire.MsgTx, envelope *UnsignedTransaction, signaturesBefore []int) ([]byte, error) {
+	signaturesAfter, err := envelopeSignatureCounts(msgTx, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	required := int(envelope.MultisigRequiredSignatures)
+	for i := range signaturesAfter {
+		if signaturesBefore[i] < required && signaturesAfter[i] <= signaturesBefore[i] {
+			return nil, fmt.Errorf("wallet has no key to sign input %d", i)
+		}
+	}
+
+	var partiallySignedTx bytes.Buffer
+	partiallySignedTx.Grow(msgTx.SerializeSize())
+	err = msgTx.Serialize(&partiallySignedTx)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	envelope.UnsignedTransaction = partiallySignedTx.Bytes()
+	return json.Marshal(envelope)
+}
+
+// envelopeSignatureCounts returns the number of multisig signatures of each
+// input of `msgTx`. Inputs of envelopes that do not spend multisig outputs
+// have no signatures.
+func envelopeSignatureCounts(msgTx *wire.MsgTx, envelope *UnsignedTransaction) ([]int, error) {
+	counts := make([]int, len(msgTx.TxIn))
+	if envelope.MultisigRedeemScript == nil {
+		return counts, nil
+	}
+
+	for i, txIn := range msgTx.TxIn {
+		count, err := multisigSignatureCount(txIn.SignatureScript)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature script for input %d: %v", i, err)
+		}
+		counts[i] = count
+	}
+
+	return counts, nil
+}
+
+// PublishTransactionEnvelope publishes the transaction of a multisig
+// envelope once it has been signed by the required number of cosigners
+// using `SignTransactionEnvelope`, and returns its hash.
+func (wallet *Wallet) PublishTransactionEnvelope(envelope string) ([]byte, error) {
+	var signedTx UnsignedTransaction
+	err := json.Unmarshal([]byte(envelope), &signedTx)
+	if err != nil || signedTx.MultisigRedeemScript == nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	if signedTx.Network != wallet.chainParams.Name {
+		return nil, fmt.Errorf("transaction is for %s, wallet is on %s", signedTx.Network, wallet.chainParams.Name)
+	}
+
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(signedTx.UnsignedTransaction))
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	signatures, err := envelopeSignatureCounts(&msgTx, &signedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, count := range signatures {
+		if count < int(signedTx.MultisigRequiredSignatures) {
+			return nil, fmt.Errorf("input %d has %d of %d required signatures", i, count, signedTx.MultisigRequiredSignatures)
+		}
+	}
+
+	return wallet.PublishSignedTransaction(signedTx.UnsignedTransaction)
+}
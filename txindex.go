@@ -79,8 +79,11 @@ func (wallet *Wallet) IndexTransactions() error {
 	return wallet.internal.GetTransactions(ctx, rangeFn, startBlock, endBlock)
 }
 
-func (wallet *Wallet) reindexTransactions() error {
-	err := wallet.txDB.ClearSavedTransactions(&Transaction{})
+// reindexTransactionsFromHeight deletes the indexed transactions from
+// `startHeight` (or all indexed transactions if `startHeight` is 0) and
+// indexes the transactions in those blocks again.
+func (wallet *Wallet) reindexTransactionsFromHeight(startHeight int32) error {
+	err := wallet.txDB.ClearSavedTransactionsFromHeight(&Transaction{}, startHeight)
 	if err != nil {
 		return err
 	}
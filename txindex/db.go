@@ -16,9 +16,14 @@ const (
 
 	// Necessary to force re-indexing if changes are made to the structure of data being stored.
 	// Increment this version number if db structure changes such that client apps need to re-index.
-	TxDbVersion uint32 = 1
+	TxDbVersion uint32 = 2
 )
 
+// dbVersion is the version new databases are created with and existing
+// databases are upgraded to. It is only set to a different version than
+// TxDbVersion by tests.
+var dbVersion = TxDbVersion
+
 type DB struct {
 	txDB  *storm.DB
 	Close func() error
@@ -26,15 +31,17 @@ type DB struct {
 
 // Initialize opens the existing storm db at `dbPath`
 // and checks the database version for compatibility.
-// If there is a version mismatch or the db does not exist at `dbPath`,
-// a new db is created and the current db version number saved to the db.
-func Initialize(dbPath string, data interface{}) (*DB, error) {
+// Older databases are upgraded to the current version, `upgradeProgress`
+// (if not nil) is called as the upgrades progress. If the db cannot be
+// upgraded or does not exist at `dbPath`, a new db is created and the
+// current db version number saved to the db.
+func Initialize(dbPath string, data interface{}, upgradeProgress UpgradeProgressFn) (*DB, error) {
 	txDB, err := openOrCreateDB(dbPath)
 	if err != nil {
 		return nil, err
 	}
 
-	txDB, err = ensureDatabaseVersion(txDB, dbPath)
+	txDB, err = ensureDatabaseVersion(txDB, dbPath, data, upgradeProgress)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +52,11 @@ func Initialize(dbPath string, data interface{}) (*DB, error) {
 		return nil, fmt.Errorf("error initializing tx database for wallet: %s", err.Error())
 	}
 
+	err = txDB.Init(&TxMetadata{})
+	if err != nil {
+		return nil, fmt.Errorf("error initializing tx metadata database for wallet: %s", err.Error())
+	}
+
 	return &DB{
 		txDB,
 		txDB.Close,
@@ -75,7 +87,7 @@ func openOrCreateDB(dbPath string) (*storm.DB, error) {
 	}
 
 	if isNewDbFile {
-		err = txDB.Set(TxBucketName, KeyDbVersion, TxDbVersion)
+		err = txDB.Set(TxBucketName, KeyDbVersion, dbVersion)
 		if err != nil {
 			os.RemoveAll(dbPath)
 			return nil, fmt.Errorf("error initializing tx index db: %s", err.Error())
@@ -86,8 +98,11 @@ func openOrCreateDB(dbPath string) (*storm.DB, error) {
 }
 
 // ensureDatabaseVersion checks the version of the existing db against `TxDbVersion`.
-// If there's a difference, the current tx index db file is deleted and a new one created.
-func ensureDatabaseVersion(txDB *storm.DB, dbPath string) (*storm.DB, error) {
+// If the db is older, it is upgraded to `TxDbVersion`. If the db cannot be upgraded,
+// the current tx index db file is deleted and a new one created. Errors reading
+// or writing the db while upgrading are returned instead as re-creating the db
+// is unlikely to succeed.
+func ensureDatabaseVersion(txDB *storm.DB, dbPath string, data interface{}, upgradeProgress UpgradeProgressFn) (*storm.DB, error) {
 	var currentDbVersion uint32
 	err := txDB.Get(TxBucketName, KeyDbVersion, &currentDbVersion)
 	if err != nil && err != storm.ErrNotFound {
@@ -95,12 +110,48 @@ func ensureDatabaseVersion(txDB *storm.DB, dbPath string) (*storm.DB, error) {
 		return nil, fmt.Errorf("error checking tx index database version: %s", err.Error())
 	}
 
-	if currentDbVersion != TxDbVersion {
-		if err = os.RemoveAll(dbPath); err != nil {
-			return nil, fmt.Errorf("error deleting outdated tx index database: %s", err.Error())
+	if currentDbVersion == dbVersion {
+		return txDB, nil
+	}
+
+	if canUpgrade(currentDbVersion) {
+		err = upgradeDatabase(txDB, currentDbVersion, data, upgradeProgress)
+		if err == nil {
+			return txDB, nil
+		}
+
+		if _, ok := err.(*upgradeRunError); !ok {
+			txDB.Close()
+			return nil, err
 		}
-		return openOrCreateDB(dbPath)
+		log.Errorf("%v, re-creating the tx index database", err)
+	} else {
+		log.Infof("Tx index database version %d cannot be upgraded to version %d, re-creating it",
+			currentDbVersion, dbVersion)
 	}
 
+	// the db cannot be upgraded, delete it so that transactions are indexed afresh.
+	// Transaction metadata cannot be recreated by indexing, keep it.
+	var metadata []TxMetadata
+	err = txDB.All(&metadata)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, fmt.Errorf("error reading tx metadata: %s", err.Error())
+	}
+
+	txDB.Close()
+	if err = os.RemoveAll(dbPath); err != nil {
+		return nil, fmt.Errorf("error deleting outdated tx index database: %s", err.Error())
+	}
+
+	txDB, err = openOrCreateDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range metadata {
+		if err = txDB.Save(&metadata[i]); err != nil {
+			return nil, fmt.Errorf("error restoring tx metadata: %s", err.Error())
+		}
+	}
 	return txDB, nil
 }
@@ -0,0 +1,184 @@
+package txindex
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm"
+)
+
+type testTx struct {
+	Hash   string `storm:"id,unique"`
+	Amount int64
+}
+
+// createTestDB creates a tx index db at version `version` containing a
+// transaction and its metadata.
+func createTestDB(t *testing.T, version uint32) string {
+	dir, err := ioutil.TempDir("", "txindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, DbName)
+
+	defer setDbVersion(version)()
+	db, err := Initialize(dbPath, &testTx{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.txDB.Save(&testTx{Hash: "tx", Amount: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.txDB.Save(&TxMetadata{Hash: "tx", Label: "label", Note: "note"}); err != nil {
+		t.Fatal(err)
+	}
+
+	return dbPath
+}
+
+// setDbVersion sets the db version and returns a function that restores it.
+func setDbVersion(version uint32) func() {
+	previousVersion := dbVersion
+	dbVersion = version
+	return func() {
+		dbVersion = previousVersion
+	}
+}
+
+// setUpgrades sets the db upgrades and returns a function that restores
+// them.
+func setUpgrades(u []upgrade) func() {
+	previousUpgrades := upgrades
+	upgrades = u
+	return func() {
+		upgrades = previousUpgrades
+	}
+}
+
+func checkDbVersion(t *testing.T, db *DB, expectedVersion uint32) {
+	var version uint32
+	if err := db.txDB.Get(TxBucketName, KeyDbVersion, &version); err != nil {
+		t.Fatal(err)
+	}
+	if version != expectedVersion {
+		t.Fatalf("db version is %d, expected %d", version, expectedVersion)
+	}
+}
+
+func checkMetadata(t *testing.T, db *DB) {
+	metadata, err := db.TransactionMetadata("tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Label != "label" || metadata.Note != "note" {
+		t.Fatalf("unexpected tx metadata %+v", metadata)
+	}
+}
+
+func TestUpgradeDatabase(t *testing.T) {
+	dbPath := createTestDB(t, 1)
+	defer os.RemoveAll(filepath.Dir(dbPath))
+
+	defer setDbVersion(2)()
+	defer setUpgrades([]upgrade{{
+		version:     2,
+		description: "double amounts",
+		run: func(tx storm.Node, data interface{}, progress func(done, total int)) error {
+			var txs []testTx
+			if err := tx.All(&txs); err != nil {
+				return err
+			}
+			for i := range txs {
+				txs[i].Amount *= 2
+				if err := tx.Save(&txs[i]); err != nil {
+					return err
+				}
+				progress(i+1, len(txs))
+			}
+			return nil
+		},
+	}})()
+
+	var progressVersions []uint32
+	db, err := Initialize(dbPath, &testTx{}, func(version uint32, description string, done, total int) {
+		progressVersions = append(progressVersions, version)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	checkDbVersion(t, db, 2)
+	checkMetadata(t, db)
+
+	var tx testTx
+	if err = db.txDB.One("Hash", "tx", &tx); err != nil {
+		t.Fatalf("upgraded tx not found: %v", err)
+	}
+	if tx.Amount != 10 {
+		t.Fatalf("tx amount is %d after the upgrade, expected 10", tx.Amount)
+	}
+
+	if len(progressVersions) == 0 {
+		t.Fatal("upgrade progress was not reported")
+	}
+	for _, version := range progressVersions {
+		if version != 2 {
+			t.Fatalf("progress reported for version %d, expected 2", version)
+		}
+	}
+}
+
+func TestFailedUpgradeKeepsMetadata(t *testing.T) {
+	dbPath := createTestDB(t, 1)
+	defer os.RemoveAll(filepath.Dir(dbPath))
+
+	defer setDbVersion(2)()
+	defer setUpgrades([]upgrade{{
+		version: 2,
+		run: func(tx storm.Node, data interface{}, progress func(done, total int)) error {
+			return errors.New("upgrade failed")
+		},
+	}})()
+
+	db, err := Initialize(dbPath, &testTx{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the db is re-created, the indexed transactions are removed but the
+	// metadata is kept.
+	checkDbVersion(t, db, 2)
+	checkMetadata(t, db)
+
+	var tx testTx
+	if err = db.txDB.One("Hash", "tx", &tx); err != storm.ErrNotFound {
+		t.Fatalf("expected indexed txs to be removed, got %v", err)
+	}
+}
+
+func TestUnupgradableDatabaseKeepsMetadata(t *testing.T) {
+	// databases created before versions were saved have no upgrade path.
+	dbPath := createTestDB(t, 0)
+	defer os.RemoveAll(filepath.Dir(dbPath))
+
+	db, err := Initialize(dbPath, &testTx{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	checkDbVersion(t, db, TxDbVersion)
+	checkMetadata(t, db)
+
+	var tx testTx
+	if err = db.txDB.One("Hash", "tx", &tx); err != storm.ErrNotFound {
+		t.Fatalf("expected indexed txs to be removed, got %v", err)
+	}
+}
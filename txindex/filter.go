@@ -77,3 +77,27 @@ func (db *DB) prepareTxQuery(txFilter int32) (query storm.Query) {
 
 	return
 }
+
+// TxQueryForFilter returns a TxQuery that matches the transactions selected
+// by `txFilter`.
+func TxQueryForFilter(txFilter int32) *TxQuery {
+	query := &TxQuery{}
+	switch txFilter {
+	case TxFilterSent:
+		query.Types = []string{txhelper.TxTypeRegular}
+		query.Directions = []int32{txhelper.TxDirectionSent}
+	case TxFilterReceived:
+		query.Types = []string{txhelper.TxTypeRegular}
+		query.Directions = []int32{txhelper.TxDirectionReceived}
+	case TxFilterTransferred:
+		query.Types = []string{txhelper.TxTypeRegular}
+		query.Directions = []int32{txhelper.TxDirectionTransferred}
+	case TxFilterStaking:
+		query.Types = []string{txhelper.TxTypeTicketPurchase, txhelper.TxTypeVote, txhelper.TxTypeRevocation}
+	case TxFilterCoinBase:
+		query.Types = []string{txhelper.TxTypeCoinBase}
+	case TxFilterRegular:
+		query.Types = []string{txhelper.TxTypeRegular}
+	}
+	return query
+}
@@ -0,0 +1,143 @@
+package txindex
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/asdine/storm"
+)
+
+// TxMetadata is user provided data attached to a transaction. Metadata is
+// saved in its own bucket, separate from the indexed transactions, so it is
+// not lost when transactions are cleared and indexed again. The label and
+// note are also copied to the indexed transaction so that they can be
+// queried along with other transaction fields.
+type TxMetadata struct {
+	Hash  string `storm:"id" json:"hash"`
+	Label string `json:"label"`
+	Note  string `json:"note"`
+}
+
+// SetTransactionLabel sets the label of the transaction with hash `txHash`.
+// `emptyTxPointer` is used to update the indexed transaction if it exists.
+func (db *DB) SetTransactionLabel(emptyTxPointer interface{}, txHash, label string) error {
+	return db.updateMetadata(emptyTxPointer, txHash, func(metadata *TxMetadata) {
+		metadata.Label = label
+	})
+}
+
+// SetTransactionNote sets the note of the transaction with hash `txHash`.
+// `emptyTxPointer` is used to update the indexed transaction if it exists.
+func (db *DB) SetTransactionNote(emptyTxPointer interface{}, txHash, note string) error {
+	return db.updateMetadata(emptyTxPointer, txHash, func(metadata *TxMetadata) {
+		metadata.Note = note
+	})
+}
+
+// TransactionMetadata returns the metadata saved for the transaction with
+// hash `txHash`. Empty metadata is returned if none is saved.
+func (db *DB) TransactionMetadata(txHash string) (*TxMetadata, error) {
+	metadata := &TxMetadata{}
+	err := db.txDB.One("Hash", txHash, metadata)
+	if err == storm.ErrNotFound {
+		return &TxMetadata{Hash: txHash}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// AllTransactionMetadata returns the metadata saved for all transactions.
+func (db *DB) AllTransactionMetadata() ([]TxMetadata, error) {
+	var metadata []TxMetadata
+	err := db.txDB.All(&metadata)
+	if err != nil && err != storm.ErrNotFound {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// RestoreTransactionMetadata saves each of `metadata`, replacing any
+// metadata previously saved for the same transactions.
+func (db *DB) RestoreTransactionMetadata(emptyTxPointer interface{}, metadata []TxMetadata) error {
+	for i := range metadata {
+		restored := metadata[i]
+		err := db.updateMetadata(emptyTxPointer, restored.Hash, func(metadata *TxMetadata) {
+			metadata.Label = restored.Label
+			metadata.Note = restored.Note
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateMetadata applies `update` to the saved metadata of a transaction and
+// copies the label and note to the indexed transaction if it exists. Metadata
+// with neither a label nor a note is deleted.
+func (db *DB) updateMetadata(emptyTxPointer interface{}, txHash string, update func(*TxMetadata)) error {
+	metadata, err := db.TransactionMetadata(txHash)
+	if err != nil {
+		return fmt.Errorf("error reading tx metadata: %s", err.Error())
+	}
+
+	update(metadata)
+
+	dbTx, err := db.txDB.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	if metadata.Label == "" && metadata.Note == "" {
+		err = dbTx.DeleteStruct(metadata)
+		if err == storm.ErrNotFound {
+			err = nil
+		}
+	} else {
+		err = dbTx.Save(metadata)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving tx metadata: %s", err.Error())
+	}
+
+	err = dbTx.One("Hash", txHash, emptyTxPointer)
+	if err == nil {
+		setMetadataFields(emptyTxPointer, metadata)
+		err = dbTx.Save(emptyTxPointer)
+	} else if err == storm.ErrNotFound {
+		// the transaction is not indexed yet, the metadata is copied to
+		// it by SaveOrUpdate when it is indexed.
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("error updating indexed tx: %s", err.Error())
+	}
+
+	return dbTx.Commit()
+}
+
+// copyMetadataToTx sets the label and note fields of `tx` from the metadata
+// saved for it.
+func (db *DB) copyMetadataToTx(tx interface{}) error {
+	txHash := reflect.Indirect(reflect.ValueOf(tx)).FieldByName("Hash").String()
+	metadata, err := db.TransactionMetadata(txHash)
+	if err != nil {
+		return err
+	}
+
+	setMetadataFields(tx, metadata)
+	return nil
+}
+
+func setMetadataFields(tx interface{}, metadata *TxMetadata) {
+	v := reflect.Indirect(reflect.ValueOf(tx))
+	if field := v.FieldByName("Label"); field.IsValid() && field.CanSet() {
+		field.SetString(metadata.Label)
+	}
+	if field := v.FieldByName("Note"); field.IsValid() && field.CanSet() {
+		field.SetString(metadata.Note)
+	}
+}
@@ -0,0 +1,385 @@
+package txindex
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/index"
+	"github.com/asdine/storm/q"
+)
+
+// queryBatchSize is the number of transactions read from the db at a time
+// while looking for the transactions that match a query.
+const queryBatchSize = 100
+
+// TxQuery describes the transactions to return from a query. Zero values
+// are not used to filter transactions.
+type TxQuery struct {
+	// StartTime and EndTime limit transactions to those with a timestamp
+	// within [StartTime, EndTime).
+	StartTime int64
+	EndTime   int64
+
+	// MinBlockHeight and MaxBlockHeight limit transactions to those mined
+	// within [MinBlockHeight, MaxBlockHeight]. Unmined transactions are
+	// excluded if either is set.
+	MinBlockHeight int32
+	MaxBlockHeight int32
+
+	// Accounts limits transactions to those with an input or output
+	// belonging to one of the accounts.
+	Accounts []int32
+
+	// MinAmount and MaxAmount limit transactions to those whose amount is
+	// within [MinAmount, MaxAmount].
+	MinAmount int64
+	MaxAmount int64
+
+	Directions []int32
+	Types      []string
+
+	// WalletIDs limits transactions to those of the specified wallets.
+	WalletIDs []int
+
+	// Address limits transactions to those with an output paying to the
+	// address.
+	Address string
+
+	// Label limits transactions to those whose label contains Label,
+	// ignoring case.
+	Label string
+
+	// HashPrefix limits transactions to those whose hash starts with the
+	// prefix.
+	HashPrefix string
+
+	NewestFirst bool
+
+	// Limit is the maximum number of transactions returned, all matching
+	// transactions are returned if it is not positive.
+	Limit int32
+
+	// Cursor is the NextCursor returned by a previous query. Transactions
+	// that were returned by the previous query are not returned again.
+	Cursor string
+}
+
+// TxCursor is the position of a transaction in the order used by queries.
+// Transactions are ordered by timestamp, then hash, then wallet id.
+type TxCursor struct {
+	Timestamp int64
+	Hash      string
+	WalletID  int
+}
+
+// Encode returns an opaque string representation of the cursor.
+func (c *TxCursor) Encode() string {
+	raw := fmt.Sprintf("%d:%s:%d", c.Timestamp, c.Hash, c.WalletID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor decodes a cursor created with TxCursor.Encode.
+func DecodeCursor(cursor string) (*TxCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	timestamp, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	walletID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &TxCursor{Timestamp: timestamp, Hash: parts[1], WalletID: walletID}, nil
+}
+
+// CursorFor returns the cursor for a transaction object. `tx` must have the
+// Timestamp, Hash and WalletID fields.
+func CursorFor(tx interface{}) *TxCursor {
+	v := reflect.Indirect(reflect.ValueOf(tx))
+	return &TxCursor{
+		Timestamp: v.FieldByName("Timestamp").Int(),
+		Hash:      v.FieldByName("Hash").String(),
+		WalletID:  int(v.FieldByName("WalletID").Int()),
+	}
+}
+
+// Before returns true if `c` comes before `other` in ascending order.
+func (c *TxCursor) Before(other *TxCursor) bool {
+	if c.Timestamp != other.Timestamp {
+		return c.Timestamp < other.Timestamp
+	}
+	if c.Hash != other.Hash {
+		return c.Hash < other.Hash
+	}
+	return c.WalletID < other.WalletID
+}
+
+// Query saves the transactions matching `query` to the received
+// `transactions` object and returns the cursor to use to query the next
+// page of transactions, or an empty string if there are no more
+// transactions. `transactions` should be a pointer to a slice of
+// Transaction objects.
+//
+// Transactions are read in batches in the order of the Timestamp index,
+// starting from the cursor, until enough matching transactions are found
+// so that only a page of transactions is held in memory. Each batch starts
+// at the timestamp of the last transaction read, only the transactions read
+// with that timestamp are skipped.
+func (db *DB) Query(query *TxQuery, transactions interface{}) (string, error) {
+	matchers, err := queryMatchers(query)
+	if err != nil {
+		return "", err
+	}
+	matcher := q.And(matchers...)
+
+	minTimestamp, maxTimestamp, err := queryTimestampRange(query)
+	if err != nil {
+		return "", err
+	}
+
+	options := []func(*index.Options){storm.Limit(queryBatchSize)}
+	if query.NewestFirst {
+		options = append(options, storm.Reverse())
+	}
+
+	// find one extra transaction to know if there are more transactions.
+	wanted := int(query.Limit) + 1
+
+	txs := reflect.ValueOf(transactions).Elem()
+	txs.SetLen(0)
+
+	// skip is the number of transactions with the timestamp that the next
+	// batch starts at which were read in previous batches.
+	var skip int
+	for query.Limit <= 0 || txs.Len() < wanted {
+		batch := reflect.New(txs.Type())
+		err = db.txDB.Range("Timestamp", minTimestamp, maxTimestamp, batch.Interface(), append(options, storm.Skip(skip))...)
+		if err != nil && err != storm.ErrNotFound {
+			return "", err
+		}
+
+		batchTxs := batch.Elem()
+		for i := 0; i < batchTxs.Len(); i++ {
+			tx := batchTxs.Index(i)
+			match, err := matcher.Match(reflect.Indirect(tx).Addr().Interface())
+			if err != nil {
+				return "", err
+			}
+			if !match {
+				continue
+			}
+
+			txs.Set(reflect.Append(txs, tx))
+			if query.Limit > 0 && txs.Len() == wanted {
+				break
+			}
+		}
+
+		if batchTxs.Len() < queryBatchSize {
+			break
+		}
+
+		// start the next batch at the timestamp of the last transaction
+		// read, skipping the transactions with that timestamp that were
+		// read already.
+		lastTimestamp := CursorFor(batchTxs.Index(batchTxs.Len() - 1).Interface()).Timestamp
+		var tied int
+		for i := batchTxs.Len() - 1; i >= 0 && CursorFor(batchTxs.Index(i).Interface()).Timestamp == lastTimestamp; i-- {
+			tied++
+		}
+
+		if query.NewestFirst {
+			if lastTimestamp != maxTimestamp {
+				skip = 0
+			}
+			maxTimestamp = lastTimestamp
+		} else {
+			if lastTimestamp != minTimestamp {
+				skip = 0
+			}
+			minTimestamp = lastTimestamp
+		}
+		skip += tied
+	}
+
+	if query.Limit <= 0 || txs.Len() <= int(query.Limit) {
+		return "", nil
+	}
+
+	txs.SetLen(int(query.Limit))
+	lastTx := txs.Index(txs.Len() - 1).Interface()
+	return CursorFor(lastTx).Encode(), nil
+}
+
+// queryTimestampRange returns the inclusive range of the Timestamp index that
+// contains the transactions that may match `query`.
+func queryTimestampRange(query *TxQuery) (int64, int64, error) {
+	minTimestamp, maxTimestamp := int64(0), int64(math.MaxInt64)
+	if query.StartTime > 0 {
+		minTimestamp = query.StartTime
+	}
+	if query.EndTime > 0 {
+		maxTimestamp = query.EndTime - 1
+	}
+
+	// transactions before the cursor were returned by a previous query.
+	if query.Cursor != "" {
+		cursor, err := DecodeCursor(query.Cursor)
+		if err != nil {
+			return 0, 0, err
+		}
+		if query.NewestFirst && cursor.Timestamp < maxTimestamp {
+			maxTimestamp = cursor.Timestamp
+		} else if !query.NewestFirst && cursor.Timestamp > minTimestamp {
+			minTimestamp = cursor.Timestamp
+		}
+	}
+
+	return minTimestamp, maxTimestamp, nil
+}
+
+func queryMatchers(query *TxQuery) ([]q.Matcher, error) {
+	matchers := []q.Matcher{q.True()}
+
+	if query.StartTime > 0 {
+		matchers = append(matchers, q.Gte("Timestamp", query.StartTime))
+	}
+	if query.EndTime > 0 {
+		matchers = append(matchers, q.Lt("Timestamp", query.EndTime))
+	}
+
+	if query.MinBlockHeight > 0 || query.MaxBlockHeight > 0 {
+		matchers = append(matchers, q.Gte("BlockHeight", query.MinBlockHeight))
+	}
+	if query.MaxBlockHeight > 0 {
+		matchers = append(matchers, q.Lte("BlockHeight", query.MaxBlockHeight))
+	}
+
+	if query.MinAmount > 0 {
+		matchers = append(matchers, q.Gte("Amount", query.MinAmount))
+	}
+	if query.MaxAmount > 0 {
+		matchers = append(matchers, q.Lte("Amount", query.MaxAmount))
+	}
+
+	if len(query.Directions) > 0 {
+		matchers = append(matchers, q.In("Direction", query.Directions))
+	}
+	if len(query.Types) > 0 {
+		matchers = append(matchers, q.In("Type", query.Types))
+	}
+
+	if len(query.WalletIDs) > 0 {
+		matchers = append(matchers, q.In("WalletID", query.WalletIDs))
+	}
+
+	if len(query.Accounts) > 0 {
+		accounts := make(map[int64]bool, len(query.Accounts))
+		for _, account := range query.Accounts {
+			accounts[int64(account)] = true
+		}
+		matchAccount := func(v reflect.Value) bool {
+			return accounts[v.Int()]
+		}
+
+		matchers = append(matchers, q.Or(
+			q.NewFieldMatcher("Inputs", &sliceFieldMatcher{field: "AccountNumber", match: matchAccount}),
+			q.NewFieldMatcher("Outputs", &sliceFieldMatcher{field: "AccountNumber", match: matchAccount}),
+		))
+	}
+
+	if query.Address != "" {
+		matchers = append(matchers, q.NewFieldMatcher("Outputs", &sliceFieldMatcher{
+			field: "Address",
+			match: func(v reflect.Value) bool {
+				return v.String() == query.Address
+			},
+		}))
+	}
+
+	if query.Label != "" {
+		matchers = append(matchers, q.Re("Label", "(?i)"+regexp.QuoteMeta(query.Label)))
+	}
+
+	if query.HashPrefix != "" {
+		matchers = append(matchers, q.Re("Hash", "^"+regexp.QuoteMeta(strings.ToLower(query.HashPrefix))))
+	}
+
+	if query.Cursor != "" {
+		cursor, err := DecodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, afterCursor(cursor, query.NewestFirst))
+	}
+
+	return matchers, nil
+}
+
+// afterCursor matches transactions that come after `cursor` in the order
+// used by queries.
+func afterCursor(cursor *TxCursor, newestFirst bool) q.Matcher {
+	after := q.Gt
+	if newestFirst {
+		after = q.Lt
+	}
+
+	return q.Or(
+		after("Timestamp", cursor.Timestamp),
+		q.And(
+			q.Eq("Timestamp", cursor.Timestamp),
+			q.Or(
+				after("Hash", cursor.Hash),
+				q.And(
+					q.Eq("Hash", cursor.Hash),
+					after("WalletID", cursor.WalletID),
+				),
+			),
+		),
+	)
+}
+
+// sliceFieldMatcher matches a slice of structs if the `field` of any of the
+// structs matches.
+type sliceFieldMatcher struct {
+	field string
+	match func(v reflect.Value) bool
+}
+
+func (m *sliceFieldMatcher) MatchField(v interface{}) (bool, error) {
+	slice := reflect.ValueOf(v)
+	if slice.Kind() != reflect.Slice {
+		return false, nil
+	}
+
+	for i := 0; i < slice.Len(); i++ {
+		item := reflect.Indirect(slice.Index(i))
+		if !item.IsValid() || item.Kind() != reflect.Struct {
+			continue
+		}
+
+		field := item.FieldByName(m.field)
+		if field.IsValid() && m.match(field) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
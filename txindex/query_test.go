@@ -0,0 +1,135 @@
+package txindex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type queryTestTx struct {
+	WalletID  int
+	Hash      string `storm:"id,unique"`
+	Timestamp int64  `storm:"index"`
+	Amount    int64
+}
+
+func TestQueryPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Initialize(filepath.Join(dir, DbName), &queryTestTx{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// more transactions than are read in a batch, with several
+	// transactions sharing each timestamp.
+	var matching []queryTestTx
+	for i := 0; i < 3*queryBatchSize; i++ {
+		tx := queryTestTx{
+			Hash:      fmt.Sprintf("%064x", i*7919%1000),
+			Timestamp: int64(1000 + i/4),
+			Amount:    int64(i % 3),
+		}
+		if err = db.txDB.Save(&tx); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Amount >= 1 {
+			matching = append(matching, tx)
+		}
+	}
+
+	for _, newestFirst := range []bool{false, true} {
+		expected := append([]queryTestTx(nil), matching...)
+		sort.Slice(expected, func(i, j int) bool {
+			before := CursorFor(&expected[i]).Before(CursorFor(&expected[j]))
+			if newestFirst {
+				return !before
+			}
+			return before
+		})
+
+		var found []queryTestTx
+		query := &TxQuery{MinAmount: 1, NewestFirst: newestFirst, Limit: 7}
+		for pages := 0; ; pages++ {
+			if pages > len(expected) {
+				t.Fatal("query did not stop returning cursors")
+			}
+
+			var txs []queryTestTx
+			cursor, err := db.Query(query, &txs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txs) > int(query.Limit) {
+				t.Fatalf("query returned %d transactions, limit is %d", len(txs), query.Limit)
+			}
+
+			found = append(found, txs...)
+			if cursor == "" {
+				break
+			}
+			query.Cursor = cursor
+		}
+
+		if len(found) != len(expected) {
+			t.Fatalf("newest first %v: found %d transactions, expected %d", newestFirst, len(found), len(expected))
+		}
+		for i := range expected {
+			if found[i] != expected[i] {
+				t.Fatalf("newest first %v: transaction %d is %+v, expected %+v", newestFirst, i, found[i], expected[i])
+			}
+		}
+	}
+}
+
+func TestQueryTiedTimestamps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Initialize(filepath.Join(dir, DbName), &queryTestTx{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// more transactions share a timestamp than are read in a batch.
+	count := 5*queryBatchSize/2 + 3
+	for i := 0; i < count; i++ {
+		tx := queryTestTx{
+			Hash:      fmt.Sprintf("%064x", i),
+			Timestamp: int64(1000 + i/(2*queryBatchSize)),
+		}
+		if err = db.txDB.Save(&tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, newestFirst := range []bool{false, true} {
+		var txs []queryTestTx
+		if _, err = db.Query(&TxQuery{NewestFirst: newestFirst}, &txs); err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[string]bool, len(txs))
+		for _, tx := range txs {
+			if seen[tx.Hash] {
+				t.Fatalf("newest first %v: transaction %s returned twice", newestFirst, tx.Hash)
+			}
+			seen[tx.Hash] = true
+		}
+		if len(seen) != count {
+			t.Fatalf("newest first %v: found %d transactions, expected %d", newestFirst, len(seen), count)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 
 	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
 	"github.com/decred/dcrwallet/errors/v2"
 )
 
@@ -29,6 +30,12 @@ func (db *DB) SaveOrUpdate(emptyTxPointer, tx interface{}) (overwritten bool, er
 		db.txDB.DeleteStruct(emptyTxPointer)
 	}
 
+	err = db.copyMetadataToTx(tx)
+	if err != nil {
+		err = errors.Errorf("error reading tx metadata: %s", err.Error())
+		return
+	}
+
 	err = db.txDB.Save(tx)
 	return
 }
@@ -49,3 +56,23 @@ func (db *DB) ClearSavedTransactions(emptyTxPointer interface{}) error {
 
 	return db.SaveLastIndexPoint(0)
 }
+
+// ClearSavedTransactionsFromHeight deletes unmined transactions and transactions
+// mined at or after `height` and sets the last index point to `height` so that
+// only the deleted range is indexed again.
+func (db *DB) ClearSavedTransactionsFromHeight(emptyTxPointer interface{}, height int32) error {
+	if height <= 0 {
+		return db.ClearSavedTransactions(emptyTxPointer)
+	}
+
+	query := db.txDB.Select(q.Or(
+		q.Gte("BlockHeight", height),
+		q.Lt("BlockHeight", 0),
+	))
+	err := query.Delete(emptyTxPointer)
+	if err != nil && err != storm.ErrNotFound {
+		return fmt.Errorf("error deleting transactions from height %d: %s", height, err.Error())
+	}
+
+	return db.SaveLastIndexPoint(height)
+}
@@ -0,0 +1,134 @@
+package txindex
+
+import (
+	"fmt"
+
+	"github.com/asdine/storm"
+)
+
+// minUpgradableDbVersion is the oldest tx index db version that can be
+// upgraded to TxDbVersion. Databases older than this version are deleted
+// and re-created, requiring all transactions to be indexed again.
+const minUpgradableDbVersion uint32 = 1
+
+// upgradeRunError is returned by upgradeDatabase if an upgrade fails, as
+// opposed to an error reading or writing the db.
+type upgradeRunError struct {
+	version uint32
+	err     error
+}
+
+func (e *upgradeRunError) Error() string {
+	return fmt.Sprintf("error upgrading tx index database to version %d: %s", e.version, e.err.Error())
+}
+
+// UpgradeProgressFn is called as db upgrades progress. `version` is the db
+// version being upgraded to and `done` is the number of records processed
+// out of `total`.
+type UpgradeProgressFn func(version uint32, description string, done, total int)
+
+// upgrade changes the structure of the db from version-1 to version.
+type upgrade struct {
+	version     uint32
+	description string
+
+	// run performs the upgrade using `tx`. `data` is the type of the
+	// transaction objects saved in the db. `progress` should be called
+	// periodically for long running upgrades.
+	run func(tx storm.Node, data interface{}, progress func(done, total int)) error
+}
+
+// upgrades must be ordered by version and have no gaps. The version of the
+// last upgrade must equal TxDbVersion.
+//
+// To change the structure of the db, increment TxDbVersion and add an
+// upgrade for the new version instead of forcing client apps to re-index.
+var upgrades = []upgrade{
+	{
+		version:     2,
+		description: "index transaction timestamps",
+		run: func(tx storm.Node, data interface{}, progress func(done, total int)) error {
+			return tx.ReIndex(data)
+		},
+	},
+}
+
+// upgradeDatabase runs the upgrades required to bring the db from
+// `currentVersion` to TxDbVersion. Each upgrade is run in its own db
+// transaction along with the update of the db version, so a failed upgrade
+// leaves the db at the last successfully upgraded version.
+func upgradeDatabase(txDB *storm.DB, currentVersion uint32, data interface{}, progress UpgradeProgressFn) error {
+	if !canUpgrade(currentVersion) {
+		return fmt.Errorf("cannot upgrade tx index database from version %d to %d", currentVersion, dbVersion)
+	}
+
+	for _, u := range upgrades {
+		if u.version <= currentVersion {
+			continue
+		}
+
+		if err := runUpgrade(txDB, u, data, progress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// canUpgrade checks that there is an upgrade path from `currentVersion` to
+// TxDbVersion.
+func canUpgrade(currentVersion uint32) bool {
+	if currentVersion < minUpgradableDbVersion || currentVersion > dbVersion {
+		return false
+	}
+
+	expectedVersion := currentVersion + 1
+	for _, u := range upgrades {
+		if u.version < expectedVersion {
+			continue
+		}
+		if u.version != expectedVersion {
+			return false
+		}
+		expectedVersion++
+	}
+
+	return expectedVersion == dbVersion+1
+}
+
+func runUpgrade(txDB *storm.DB, u upgrade, data interface{}, progress UpgradeProgressFn) (err error) {
+	dbTx, err := txDB.Begin(true)
+	if err != nil {
+		return fmt.Errorf("error starting tx index database upgrade: %s", err.Error())
+	}
+
+	// Commit or rollback the transaction after the upgrade returns or panics.
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			dbTx.Rollback()
+			return
+		}
+
+		err = dbTx.Commit()
+	}()
+
+	upgradeProgress := func(done, total int) {
+		if progress != nil {
+			progress(u.version, u.description, done, total)
+		}
+	}
+
+	upgradeProgress(0, 0)
+	err = u.run(dbTx, data, upgradeProgress)
+	panicked = false
+	if err != nil {
+		return &upgradeRunError{version: u.version, err: err}
+	}
+
+	err = dbTx.Set(TxBucketName, KeyDbVersion, u.version)
+	if err != nil {
+		return fmt.Errorf("error saving tx index database version %d: %s", u.version, err.Error())
+	}
+	return nil
+}
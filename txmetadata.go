@@ -0,0 +1,73 @@
+package dcrlibwallet
+
+import (
+	"encoding/json"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/raedahgroup/dcrlibwallet/txindex"
+)
+
+// TxMetadata is the label and note saved for a transaction.
+type TxMetadata = txindex.TxMetadata
+
+// SetTransactionLabel sets the label of the transaction with hash `txHash`.
+// Pass an empty label to remove the label. Labels are kept when the wallet's
+// transactions are indexed again and can be searched with TxQuery.Label.
+func (wallet *Wallet) SetTransactionLabel(txHash, label string) error {
+	if _, err := chainhash.NewHashFromStr(txHash); err != nil {
+		return errors.New(ErrInvalid)
+	}
+	return wallet.txDB.SetTransactionLabel(&Transaction{}, txHash, label)
+}
+
+// SetTransactionNote sets the note of the transaction with hash `txHash`.
+// Pass an empty note to remove the note.
+func (wallet *Wallet) SetTransactionNote(txHash, note string) error {
+	if _, err := chainhash.NewHashFromStr(txHash); err != nil {
+		return errors.New(ErrInvalid)
+	}
+	return wallet.txDB.SetTransactionNote(&Transaction{}, txHash, note)
+}
+
+// TransactionMetadata returns the label and note of the transaction with
+// hash `txHash`.
+func (wallet *Wallet) TransactionMetadata(txHash string) (*TxMetadata, error) {
+	return wallet.txDB.TransactionMetadata(txHash)
+}
+
+// ExportTransactionMetadata returns the labels and notes of all transactions
+// of this wallet as json, for backup with ImportTransactionMetadata.
+func (wallet *Wallet) ExportTransactionMetadata() (string, error) {
+	metadata, err := wallet.txDB.AllTransactionMetadata()
+	if err != nil {
+		return "", err
+	}
+	if metadata == nil {
+		metadata = []TxMetadata{}
+	}
+
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(metadataJSON), nil
+}
+
+// ImportTransactionMetadata saves the labels and notes exported with
+// ExportTransactionMetadata, replacing the label and note of transactions
+// that are in both the export and this wallet.
+func (wallet *Wallet) ImportTransactionMetadata(metadataJSON string) error {
+	var metadata []TxMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return errors.New(ErrInvalid)
+	}
+
+	for _, txMetadata := range metadata {
+		if _, err := chainhash.NewHashFromStr(txMetadata.Hash); err != nil {
+			return errors.New(ErrInvalid)
+		}
+	}
+
+	return wallet.txDB.RestoreTransactionMetadata(&Transaction{}, metadata)
+}
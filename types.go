@@ -7,6 +7,14 @@ type WalletsIterator struct {
 	wallets      []*Wallet
 }
 
+// WalletBirthday is the time or block height at which a restored wallet was
+// created. Blocks before the birthday are skipped when restoring the wallet.
+// If Height is set, Timestamp is ignored.
+type WalletBirthday struct {
+	Timestamp int64
+	Height    int32
+}
+
 type BlockInfo struct {
 	Height    int32
 	Timestamp int64
@@ -28,6 +36,19 @@ type UnsignedTransaction struct {
 	ChangeIndex               int
 	TotalOutputAmount         int64
 	TotalPreviousOutputAmount int64
+
+	// Network and PreviousOutputScripts are required to sign the transaction
+	// on a wallet that is not synced. PreviousOutputScripts[i] is the script
+	// of the output spent by input i, the amounts spent are encoded in the
+	// transaction inputs.
+	Network               string
+	PreviousOutputScripts [][]byte
+
+	// MultisigRedeemScript and MultisigRequiredSignatures are set if the
+	// transaction spends the outputs of a multisig address. The transaction
+	// is partially signed until each input has the required signatures.
+	MultisigRedeemScript       []byte
+	MultisigRequiredSignatures int32
 }
 
 type Balance struct {
@@ -135,7 +156,7 @@ type Transaction struct {
 	Hash        string `storm:"id,unique" json:"hash"`
 	Type        string `storm:"index" json:"type"`
 	Hex         string `json:"hex"`
-	Timestamp   int64  `json:"timestamp"`
+	Timestamp   int64  `storm:"index" json:"timestamp"`
 	BlockHeight int32  `json:"block_height"`
 
 	Version  int32 `json:"version"`
@@ -154,6 +175,18 @@ type Transaction struct {
 	VoteVersion    int32  `json:"vote_version"`
 	LastBlockValid bool   `json:"last_block_valid"`
 	VoteBits       string `json:"vote_bits"`
+
+	// Label and Note are copied from the transaction's metadata, see
+	// Wallet.SetTransactionLabel and Wallet.SetTransactionNote.
+	Label string `json:"label"`
+	Note  string `json:"note"`
+}
+
+// TxQueryResult is a page of transactions returned by QueryTransactions.
+// NextCursor is empty if there are no more transactions to query.
+type TxQueryResult struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor"`
 }
 
 type TxInput struct {
@@ -206,6 +239,53 @@ type WalletAccount struct {
 	AccountName   string `json:"account_name"`
 }
 
+type UnspentOutput struct {
+	TransactionHash []byte `json:"transaction_hash"`
+	OutputIndex     uint32 `json:"output_index"`
+	OutputKey       string `json:"output_key"`
+	Tree            int8   `json:"tree"`
+	Amount          int64  `json:"amount"`
+	Address         string `json:"address"`
+	Account         int32  `json:"account"`
+	Confirmations   int32  `json:"confirmations"`
+	BlockHeight     int32  `json:"block_height"`
+	ReceiveTime     int64  `json:"receive_time"`
+}
+
+// AddressBookEntry is a contact saved to the address book. LastUsed is the
+// unix timestamp of the last transaction sent to Address using a TxAuthor
+// created with MultiWallet.NewUnsignedTx.
+type AddressBookEntry struct {
+	ID       int    `storm:"id,increment" json:"id"`
+	Name     string `json:"name"`
+	Address  string `storm:"unique" json:"address"`
+	Note     string `json:"note"`
+	LastUsed int64  `storm:"index" json:"last_used"`
+}
+
+// PeerInfo describes a peer connected to during SPV sync. HeadersRoundTrip
+// is the time in milliseconds taken by the peer to respond to the last
+// headers request made to it.
+type PeerInfo struct {
+	ID               int64  `json:"id"`
+	Addr             string `json:"addr"`
+	UserAgent        string `json:"user_agent"`
+	Services         int64  `json:"services"`
+	ProtocolVersion  int32  `json:"protocol_version"`
+	InitialHeight    int32  `json:"initial_height"`
+	ConnectTime      int64  `json:"connect_time"`
+	BytesSent        int64  `json:"bytes_sent"`
+	BytesReceived    int64  `json:"bytes_received"`
+	HeadersRoundTrip int64  `json:"headers_round_trip"`
+}
+
+// BannedPeer is a peer host that is not connected to during SPV sync until
+// the unix timestamp BannedUntil.
+type BannedPeer struct {
+	Host        string `storm:"id" json:"host"`
+	BannedUntil int64  `json:"banned_until"`
+}
+
 type TransactionDestination struct {
 	Address    string
 	AtomAmount int64
@@ -249,6 +329,31 @@ type TicketPriceResponse struct {
 	Height      int32
 }
 
+type TicketBuyerConfig struct {
+	// VSPHost is the VSP that purchased tickets are registered with using
+	// version 3 of the vspd API, empty to purchase solo voting tickets.
+	// VSPPubKey may be nil if a key is pinned for the VSP, see
+	// Wallet.PurchaseTicketsWithVSP. The VSP fee is paid from the purchase
+	// account.
+	VSPHost   string
+	VSPPubKey []byte
+
+	PurchaseAccount   int32
+	BalanceToMaintain int64
+	Expiry            int32
+
+	// MaxFeeRate is the highest ticket fee rate in atoms/kB that tickets
+	// are purchased at, zero for no limit.
+	MaxFeeRate int64
+}
+
+type TicketBuyerNotificationListener interface {
+	OnTicketBuyerStarted(walletID int)
+	OnTicketsPurchased(walletID int, ticketHashes string)
+	OnTicketBuyerError(walletID int, err error)
+	OnTicketBuyerStopped(walletID int)
+}
+
 type VSPTicketPurchaseInfo struct {
 	PoolAddress   string
 	PoolFees      float64
@@ -256,4 +361,27 @@ type VSPTicketPurchaseInfo struct {
 	TicketAddress string
 }
 
+type VSPInfo struct {
+	Host          string
+	PubKey        []byte
+	FeePercentage float64
+	Closed        bool
+	Network       string
+}
+
+// VSPTicketInfo records the VSP that a ticket was registered with.
+type VSPTicketInfo struct {
+	VSPHost   string
+	VSPPubKey []byte
+	FeeTxHash string
+}
+
+type VSPTicketStatus struct {
+	TicketHash      string
+	TicketConfirmed bool
+	FeeTxStatus     string
+	FeeTxHash       string
+	VoteChoices     map[string]string
+}
+
 /** end ticket-related types */
@@ -0,0 +1,222 @@
+package dcrlibwallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+	w "github.com/decred/dcrwallet/wallet/v3"
+	"github.com/decred/dcrwallet/wallet/v3/txauthor"
+	"github.com/decred/dcrwallet/wallet/v3/txsizes"
+)
+
+// UnspentOutputs returns the unspent outputs of `account` that have at least
+// `requiredConfirmations` confirmations.
+func (wallet *Wallet) UnspentOutputs(account, requiredConfirmations int32) ([]*UnspentOutput, error) {
+	outputs, err := wallet.unspentOutputs(uint32(account), requiredConfirmations)
+	if err != nil {
+		return nil, err
+	}
+
+	tipHeight := wallet.GetBestBlock()
+	unspentOutputs := make([]*UnspentOutput, len(outputs))
+	for i, output := range outputs {
+		var confirmations int32
+		blockHeight := output.ContainingBlock.Height
+		if blockHeight != -1 {
+			confirmations = tipHeight - blockHeight + 1
+		}
+
+		var address string
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(output.Output.Version, output.Output.PkScript, wallet.chainParams)
+		if err == nil && len(addrs) > 0 {
+			address = addrs[0].Address()
+		}
+
+		unspentOutputs[i] = &UnspentOutput{
+			TransactionHash: output.OutPoint.Hash[:],
+			OutputIndex:     output.OutPoint.Index,
+			OutputKey:       outpointKey(&output.OutPoint),
+			Tree:            output.OutPoint.Tree,
+			Amount:          output.Output.Value,
+			Address:         address,
+			Account:         account,
+			Confirmations:   confirmations,
+			BlockHeight:     blockHeight,
+			ReceiveTime:     output.ReceiveTime.Unix(),
+		}
+	}
+
+	return unspentOutputs, nil
+}
+
+// UnspentOutputsJSON returns the json encoded result of UnspentOutputs.
+func (wallet *Wallet) UnspentOutputsJSON(account, requiredConfirmations int32) (string, error) {
+	unspentOutputs, err := wallet.UnspentOutputs(account, requiredConfirmations)
+	if err != nil {
+		return "", err
+	}
+
+	result, _ := json.Marshal(unspentOutputs)
+	return string(result), nil
+}
+
+func (wallet *Wallet) unspentOutputs(account uint32, requiredConfirmations int32) ([]*w.TransactionOutput, error) {
+	policy := w.OutputSelectionPolicy{
+		Account:               account,
+		RequiredConfirmations: requiredConfirmations,
+	}
+
+	outputs, err := wallet.internal.UnspentOutputs(wallet.shutdownContext(), policy)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return outputs, nil
+}
+
+// outpointKey returns the `hash:index` string used to identify an outpoint
+// in UnspentOutput.OutputKey and TxAuthor.UseInputs.
+func outpointKey(outpoint *wire.OutPoint) string {
+	return fmt.Sprintf("%s:%d", outpoint.Hash.String(), outpoint.Index)
+}
+
+func parseOutpointKey(key string) (*wire.OutPoint, error) {
+	parts := strings.Split(key, ":")
+	if len(parts) != 2 {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	hash, err := chainhash.NewHashFromStr(parts[0])
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	// the tree is not part of the key, it is set from the matching
+	// wallet output when the inputs are fetched.
+	return wire.NewOutPoint(hash, uint32(index), wire.TxTreeUnknown), nil
+}
+
+// redeemScriptSize returns the estimated size of the signature script used to
+// spend `output`, matching the sizes used by the wallet's own input source.
+// Only outputs paying to a public key or public key hash, and stake outputs
+// paying to a public key hash, are supported.
+func redeemScriptSize(output *wire.TxOut) (int, error) {
+	scriptClass := txscript.GetScriptClass(output.Version, output.PkScript)
+	switch scriptClass {
+	case txscript.PubKeyHashTy:
+		return txsizes.RedeemP2PKHSigScriptSize, nil
+
+	case txscript.PubKeyTy:
+		return txsizes.RedeemP2PKSigScriptSize, nil
+
+	case txscript.StakeGenTy, txscript.StakeRevocationTy, txscript.StakeSubChangeTy:
+		subClass, err := txscript.GetStakeOutSubclass(output.PkScript)
+		if err == nil && subClass == txscript.PubKeyHashTy {
+			return txsizes.RedeemP2PKHSigScriptSize, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unsupported script class %v", scriptClass)
+}
+
+// selectedInputsSource returns the selected unspent outputs of the source
+// account as an input source that always returns all of the selected
+// outputs regardless of the target amount. An error is returned if any of
+// the selected outputs is not an unspent output of the source account with
+// enough confirmations, or is locked by another transaction. The selected
+// outputs are locked until this transaction is broadcast or other inputs
+// are selected.
+func (tx *TxAuthor) selectedInputsSource() (txauthor.InputSource, dcrutil.Amount, error) {
+	outputs, err := tx.wallet.unspentOutputs(tx.sendFromAccount, tx.requiredConfirmations)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unspent := make(map[string]*w.TransactionOutput, len(outputs))
+	for _, output := range outputs {
+		unspent[outpointKey(&output.OutPoint)] = output
+	}
+
+	inputDetail := &txauthor.InputDetail{}
+	for _, key := range tx.inputs {
+		output, ok := unspent[key]
+		if !ok {
+			return nil, 0, fmt.Errorf("selected input %s is not spendable from account %d", key, tx.sendFromAccount)
+		}
+
+		outpoint := output.OutPoint
+		if tx.wallet.internal.LockedOutpoint(outpoint) && !tx.holdsInputLock(outpoint) {
+			return nil, 0, fmt.Errorf("selected input %s is locked", key)
+		}
+
+		scriptSize, err := redeemScriptSize(&output.Output)
+		if err != nil {
+			return nil, 0, fmt.Errorf("selected input %s cannot be spent: %v", key, err)
+		}
+
+		amount := dcrutil.Amount(output.Output.Value)
+		inputDetail.Amount += amount
+		inputDetail.Inputs = append(inputDetail.Inputs, wire.NewTxIn(&outpoint, int64(amount), nil))
+		inputDetail.Scripts = append(inputDetail.Scripts, output.Output.PkScript)
+		inputDetail.RedeemScriptSizes = append(inputDetail.RedeemScriptSizes, scriptSize)
+	}
+
+	// lock the selected outputs so that they are not used by other
+	// transactions while this transaction is being prepared.
+	lockedInputs := make([]wire.OutPoint, len(inputDetail.Inputs))
+	for i, input := range inputDetail.Inputs {
+		lockedInputs[i] = input.PreviousOutPoint
+	}
+	tx.lockInputs(lockedInputs)
+
+	inputSource := func(dcrutil.Amount) (*txauthor.InputDetail, error) {
+		return inputDetail, nil
+	}
+
+	return inputSource, inputDetail.Amount, nil
+}
+
+// holdsInputLock returns true if `outpoint` was locked by this transaction.
+func (tx *TxAuthor) holdsInputLock(outpoint wire.OutPoint) bool {
+	for _, lockedInput := range tx.lockedInputs {
+		if lockedInput == outpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// lockInputs locks `outpoints` for use by this transaction and unlocks the
+// outputs previously locked by this transaction that are not in `outpoints`.
+func (tx *TxAuthor) lockInputs(outpoints []wire.OutPoint) {
+	locked := make(map[wire.OutPoint]bool, len(outpoints))
+	for _, outpoint := range outpoints {
+		locked[outpoint] = true
+		tx.wallet.internal.LockOutpoint(outpoint)
+	}
+
+	for _, outpoint := range tx.lockedInputs {
+		if !locked[outpoint] {
+			tx.wallet.internal.UnlockOutpoint(outpoint)
+		}
+	}
+
+	tx.lockedInputs = outpoints
+}
+
+// releaseInputs unlocks the outputs locked by this transaction.
+func (tx *TxAuthor) releaseInputs() {
+	tx.lockInputs(nil)
+}
@@ -0,0 +1,51 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/wallet/v3/txsizes"
+)
+
+func TestRedeemScriptSize(t *testing.T) {
+	hash160 := bytes.Repeat([]byte{0x01}, 20)
+	pubKey := append([]byte{0x02}, bytes.Repeat([]byte{0x01}, 32)...)
+
+	p2pkh := append([]byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20}, hash160...)
+	p2pkh = append(p2pkh, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)
+	p2pk := append(append([]byte{txscript.OP_DATA_33}, pubKey...), txscript.OP_CHECKSIG)
+	p2sh := append(append([]byte{txscript.OP_HASH160, txscript.OP_DATA_20}, hash160...), txscript.OP_EQUAL)
+
+	tests := []struct {
+		name     string
+		pkScript []byte
+		size     int
+	}{
+		{"p2pkh", p2pkh, txsizes.RedeemP2PKHSigScriptSize},
+		{"p2pk", p2pk, txsizes.RedeemP2PKSigScriptSize},
+		{"stakegen p2pkh", append([]byte{txscript.OP_SSGEN}, p2pkh...), txsizes.RedeemP2PKHSigScriptSize},
+		{"revocation p2pkh", append([]byte{txscript.OP_SSRTX}, p2pkh...), txsizes.RedeemP2PKHSigScriptSize},
+		{"stake change p2pkh", append([]byte{txscript.OP_SSTXCHANGE}, p2pkh...), txsizes.RedeemP2PKHSigScriptSize},
+		{"p2sh", p2sh, 0},
+		{"stake change p2sh", append([]byte{txscript.OP_SSTXCHANGE}, p2sh...), 0},
+	}
+
+	for _, test := range tests {
+		size, err := redeemScriptSize(&wire.TxOut{PkScript: test.pkScript})
+		if test.size == 0 {
+			if err == nil {
+				t.Errorf("%s: expected an error, got size %d", test.name, size)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if size != test.size {
+			t.Errorf("%s: size %d, expected %d", test.name, size, test.size)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/blockchain/stake/v2"
+	"github.com/decred/dcrd/chaincfg/chainhash"
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrd/txscript/v2"
+	"github.com/decred/dcrd/wire"
+	"github.com/decred/dcrwallet/errors/v2"
+	w "github.com/decred/dcrwallet/wallet/v3"
+	"github.com/decred/dcrwallet/wallet/v3/txrules"
+	"github.com/raedahgroup/dcrlibwallet/txhelper"
+	"github.com/raedahgroup/dcrlibwallet/vsp"
+)
+
+// VSPInfo fetches the info of the VSP at `vspHost`. Responses are checked
+// against the key pinned for the VSP when a ticket was first registered with
+// it, or against `vspPubKey` if no key is pinned yet. If neither is known, the
+// key reported by the VSP is returned in VSPInfo.PubKey unconfirmed, the user
+// should confirm it before it is passed to PurchaseTicketsWithVSP.
+func (wallet *Wallet) VSPInfo(vspHost string, vspPubKey []byte) (*VSPInfo, error) {
+	ctx := wallet.shutdownContext()
+	pubKey, err := wallet.vspPubKey(vspHost, vspPubKey)
+	if err != nil && err.Error() != ErrVSPPubKeyRequired {
+		return nil, err
+	}
+	if pubKey == nil {
+		pubKey, err = vsp.FetchPubKey(ctx, wallet.httpClient(), vspHost)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := vsp.NewClient(vspHost, pubKey, wallet.internal)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHTTPClient(wallet.httpClient())
+
+	info, err := client.VspInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VSPInfo{
+		Host:          vspHost,
+		PubKey:        info.PubKey,
+		FeePercentage: info.FeePercentage,
+		Closed:        info.VspClosed,
+		Network:       info.Network,
+	}, nil
+}
+
+// PurchaseTicketsWithVSP purchases tickets using the wallet's own voting
+// addresses and registers each purchased ticket with the VSP at `vspHost`
+// using version 3 of the vspd API. The VSP fee for each ticket is paid from
+// `feeAccount`. Tickets that were purchased but could not be registered are
+// returned along with the error and can be registered later using
+// RegisterTicketWithVSP.
+// `vspPubKey` may be nil if a key is pinned for the VSP, otherwise it is
+// pinned once a ticket is registered with the VSP. ErrVSPPubKeyMismatch is
+// returned if it does not match the pinned key.
+func (wallet *Wallet) PurchaseTicketsWithVSP(ctx context.Context, request *PurchaseTicketsRequest, vspHost string,
+	vspPubKey []byte, feeAccount int32) ([]string, error) {
+
+	lock := make(chan time.Time, 1)
+	defer func() {
+		lock <- time.Time{} // send matters, not the value
+	}()
+	err := wallet.internal.Unlock(ctx, request.Passphrase, lock)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	client, err := wallet.vspClient(ctx, vspHost, vspPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet.purchaseTicketsWithVSP(ctx, request, client, vspHost, feeAccount)
+}
+
+// purchaseTicketsWithVSP purchases tickets and registers them with the VSP
+// using `client`. The wallet must be unlocked before this is called.
+func (wallet *Wallet) purchaseTicketsWithVSP(ctx context.Context, request *PurchaseTicketsRequest, client *vsp.Client,
+	vspHost string, feeAccount int32) ([]string, error) {
+
+	// tickets registered with a vsp using the v3 api are purchased using the
+	// wallet's voting address, the vsp receives the voting key after the fee
+	// is paid.
+	request.TicketAddress = ""
+	request.PoolAddress = ""
+	request.PoolFees = 0
+
+	ticketHashes, err := wallet.purchaseTickets(ctx, request, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ticketHash := range ticketHashes {
+		hash, _ := chainhash.NewHashFromStr(ticketHash)
+		err = wallet.registerTicketWithVSP(ctx, client, vspHost, hash, uint32(feeAccount))
+		if err != nil {
+			log.Errorf("[%d] Failed to register ticket %s with vsp: %v", wallet.ID, ticketHash, err)
+			return ticketHashes, fmt.Errorf("ticket %s was purchased but not registered with the vsp: %v", ticketHash, err)
+		}
+	}
+
+	return ticketHashes, nil
+}
+
+// RegisterTicketWithVSP registers a previously purchased ticket with the VSP
+// at `vspHost`, paying the VSP fee from `feeAccount`. `vspPubKey` is used as
+// in PurchaseTicketsWithVSP.
+func (wallet *Wallet) RegisterTicketWithVSP(ticketHash, vspHost string, vspPubKey []byte, feeAccount int32, privPass []byte) error {
+	hash, err := chainhash.NewHashFromStr(ticketHash)
+	if err != nil {
+		return errors.New(ErrInvalid)
+	}
+
+	lock := make(chan time.Time, 1)
+	defer func() {
+		for i := range privPass {
+			privPass[i] = 0
+		}
+		lock <- time.Time{} // send matters, not the value
+	}()
+
+	ctx := wallet.shutdownContext()
+	err = wallet.internal.Unlock(ctx, privPass, lock)
+	if err != nil {
+		return translateError(err)
+	}
+
+	client, err := wallet.vspClient(ctx, vspHost, vspPubKey)
+	if err != nil {
+		return err
+	}
+
+	return wallet.registerTicketWithVSP(ctx, client, vspHost, hash, uint32(feeAccount))
+}
+
+// VSPTicketStatus queries the VSP that the ticket was registered with for
+// the current status of the ticket and its fee payment.
+func (wallet *Wallet) VSPTicketStatus(ticketHash string) (*VSPTicketStatus, error) {
+	hash, err := chainhash.NewHashFromStr(ticketHash)
+	if err != nil {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	vspTicketInfo := wallet.TicketVSPInfo(ticketHash)
+	if vspTicketInfo == nil || vspTicketInfo.VSPPubKey == nil {
+		return nil, errors.New(ErrNotExist)
+	}
+
+	ctx := wallet.shutdownContext()
+	client, err := vsp.NewClient(vspTicketInfo.VSPHost, vspTicketInfo.VSPPubKey, wallet.internal)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHTTPClient(wallet.httpClient())
+
+	ticket, _, err := wallet.ticketAndParent(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	commitmentAddr, err := stake.AddrFromSStxPkScrCommitment(ticket.TxOut[1].PkScript, wallet.chainParams)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := client.TicketStatus(ctx, commitmentAddr, &vsp.TicketStatusRequest{TicketHash: ticketHash})
+	if err != nil {
+		return nil, err
+	}
+
+	return &VSPTicketStatus{
+		TicketHash:      ticketHash,
+		TicketConfirmed: status.TicketConfirmed,
+		FeeTxStatus:     status.FeeTxStatus,
+		FeeTxHash:       status.FeeTxHash,
+		VoteChoices:     status.VoteChoices,
+	}, nil
+}
+
+// TicketVSPInfo returns the VSP that the ticket was registered with or nil if
+// the ticket was not purchased through a VSP.
+func (wallet *Wallet) TicketVSPInfo(ticketHash string) *VSPTicketInfo {
+	wallet.vspTicketsMu.Lock()
+	defer wallet.vspTicketsMu.Unlock()
+
+	return wallet.readVSPTickets()[ticketHash]
+}
+
+func (wallet *Wallet) saveVSPTicketInfo(ticketHash string, vspTicketInfo *VSPTicketInfo) {
+	wallet.vspTicketsMu.Lock()
+	defer wallet.vspTicketsMu.Unlock()
+
+	vspTickets := wallet.readVSPTickets()
+	vspTickets[ticketHash] = vspTicketInfo
+	wallet.SaveUserConfigValue(VSPTicketsConfigKey, vspTickets)
+}
+
+// readVSPTickets must be called with wallet.vspTicketsMu held.
+func (wallet *Wallet) readVSPTickets() map[string]*VSPTicketInfo {
+	vspTickets := make(map[string]*VSPTicketInfo)
+	wallet.ReadUserConfigValue(VSPTicketsConfigKey, &vspTickets)
+	return vspTickets
+}
+
+// vspClient creates a vsp client for `vspHost` that checks responses against
+// the key pinned for the VSP or `vspPubKey`, and checks that the VSP is open
+// and on the same network as this wallet.
+func (wallet *Wallet) vspClient(ctx context.Context, vspHost string, vspPubKey []byte) (*vsp.Client, error) {
+	pubKey, err := wallet.vspPubKey(vspHost, vspPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := vsp.NewClient(vspHost, pubKey, wallet.internal)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHTTPClient(wallet.httpClient())
+
+	info, err := client.VspInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("vsp connection error: %v", err)
+	}
+
+	if info.Network != wallet.chainParams.Name {
+		return nil, fmt.Errorf("vsp is on %s, wallet is on %s", info.Network, wallet.chainParams.Name)
+	}
+
+	if info.VspClosed {
+		return nil, errors.New(ErrUnavailable)
+	}
+
+	return client, nil
+}
+
+// vspPubKey returns the key pinned for `vspHost`, or `vspPubKey` if no key is
+// pinned for the VSP yet. ErrVSPPubKeyRequired is returned if neither is
+// known and ErrVSPPubKeyMismatch if `vspPubKey` is not the pinned key.
+func (wallet *Wallet) vspPubKey(vspHost string, vspPubKey []byte) ([]byte, error) {
+	wallet.vspPubKeysMu.Lock()
+	defer wallet.vspPubKeysMu.Unlock()
+
+	pinnedKey := wallet.readVSPPubKeys()[vspPubKeyHost(vspHost)]
+	switch {
+	case pinnedKey == nil && vspPubKey == nil:
+		return nil, errors.New(ErrVSPPubKeyRequired)
+	case pinnedKey == nil:
+		return vspPubKey, nil
+	case vspPubKey != nil && !bytes.Equal(pinnedKey, vspPubKey):
+		return nil, errors.New(ErrVSPPubKeyMismatch)
+	default:
+		return pinnedKey, nil
+	}
+}
+
+// pinVSPPubKey saves `pubKey` as the key of `vspHost` if no key was pinned for
+// the VSP before.
+func (wallet *Wallet) pinVSPPubKey(vspHost string, pubKey []byte) error {
+	wallet.vspPubKeysMu.Lock()
+	defer wallet.vspPubKeysMu.Unlock()
+
+	vspPubKeys := wallet.readVSPPubKeys()
+	host := vspPubKeyHost(vspHost)
+	if pinnedKey, ok := vspPubKeys[host]; ok {
+		if !bytes.Equal(pinnedKey, pubKey) {
+			return errors.New(ErrVSPPubKeyMismatch)
+		}
+		return nil
+	}
+
+	vspPubKeys[host] = pubKey
+	return wallet.setUserConfigValue(VSPPubKeysConfigKey, vspPubKeys)
+}
+
+// readVSPPubKeys must be called with wallet.vspPubKeysMu held.
+func (wallet *Wallet) readVSPPubKeys() map[string][]byte {
+	vspPubKeys := make(map[string][]byte)
+	wallet.ReadUserConfigValue(VSPPubKeysConfigKey, &vspPubKeys)
+	return vspPubKeys
+}
+
+// vspPubKeyHost returns the host that the key of the VSP at `vspHost` is
+// pinned for, so that keys are pinned for the VSP regardless of the scheme
+// and path used to reach it.
+func vspPubKeyHost(vspHost string) string {
+	if u, err := url.Parse(vspHost); err == nil && u.Host != "" {
+		return strings.ToLower(u.Host)
+	}
+	return strings.ToLower(strings.TrimSuffix(vspHost, "/"))
+}
+
+// registerTicketWithVSP requests a fee address for the ticket, pays the fee
+// from `feeAccount` and records the vsp the ticket was registered with.
+// The wallet must be unlocked before this is called.
+func (wallet *Wallet) registerTicketWithVSP(ctx context.Context, client *vsp.Client, vspHost string,
+	ticketHash *chainhash.Hash, feeAccount uint32) error {
+
+	ticket, parent, err := wallet.ticketAndParent(ctx, ticketHash)
+	if err != nil {
+		return err
+	}
+
+	if !stake.IsSStx(ticket) {
+		return errors.New(ErrInvalid)
+	}
+
+	commitmentAddr, err := stake.AddrFromSStxPkScrCommitment(ticket.TxOut[1].PkScript, wallet.chainParams)
+	if err != nil {
+		return err
+	}
+
+	_, votingAddrs, _, err := txscript.ExtractPkScriptAddrs(ticket.TxOut[0].Version, ticket.TxOut[0].PkScript, wallet.chainParams)
+	if err != nil {
+		return err
+	}
+	if len(votingAddrs) == 0 {
+		return fmt.Errorf("ticket has no voting address")
+	}
+
+	ticketHex, err := txHex(ticket)
+	if err != nil {
+		return err
+	}
+	parentHex, err := txHex(parent)
+	if err != nil {
+		return err
+	}
+
+	feeAddressResponse, err := client.FeeAddress(ctx, commitmentAddr, &vsp.FeeAddressRequest{
+		Timestamp:  time.Now().Unix(),
+		TicketHash: ticketHash.String(),
+		TicketHex:  ticketHex,
+		ParentHex:  parentHex,
+	})
+	if err != nil {
+		return err
+	}
+
+	feeAmount := dcrutil.Amount(feeAddressResponse.FeeAmount)
+	if feeAmount <= 0 || feeAmount > dcrutil.Amount(ticket.TxOut[0].Value) {
+		return fmt.Errorf("vsp requested an invalid fee amount %v", feeAmount)
+	}
+
+	feeTx, err := wallet.createVSPFeeTx(ctx, feeAddressResponse.FeeAddress, feeAmount, feeAccount)
+	if err != nil {
+		return err
+	}
+
+	feeTxHex, err := txHex(feeTx)
+	if err != nil {
+		return err
+	}
+
+	votingKey, err := wallet.internal.DumpWIFPrivateKey(ctx, votingAddrs[0])
+	if err != nil {
+		return translateError(err)
+	}
+
+	_, err = client.PayFee(ctx, commitmentAddr, &vsp.PayFeeRequest{
+		Timestamp:   time.Now().Unix(),
+		TicketHash:  ticketHash.String(),
+		FeeTx:       feeTxHex,
+		VotingKey:   votingKey,
+		VoteChoices: make(map[string]string),
+	})
+	if err != nil {
+		return err
+	}
+
+	wallet.saveVSPTicketInfo(ticketHash.String(), &VSPTicketInfo{
+		VSPHost:   vspHost,
+		VSPPubKey: client.PubKey(),
+		FeeTxHash: feeTx.TxHash().String(),
+	})
+
+	// the key is pinned for the vsp once it has accepted the fee for a
+	// ticket.
+	if err = wallet.pinVSPPubKey(vspHost, client.PubKey()); err != nil {
+		log.Errorf("[%d] Failed to pin the public key of vsp %s: %v", wallet.ID, vspHost, err)
+	}
+
+	log.Infof("[%d] Ticket %v registered with vsp %s", wallet.ID, ticketHash, vspHost)
+	return nil
+}
+
+// ticketAndParent returns the ticket with the provided hash and the
+// transaction that funded the ticket.
+func (wallet *Wallet) ticketAndParent(ctx context.Context, ticketHash *chainhash.Hash) (ticket, parent *wire.MsgTx, err error) {
+	txs, _, err := wallet.internal.GetTransactionsByHashes(ctx, []*chainhash.Hash{ticketHash})
+	if err != nil {
+		return nil, nil, translateError(err)
+	}
+	if len(txs) == 0 {
+		return nil, nil, errors.New(ErrNotExist)
+	}
+	ticket = txs[0]
+
+	parentHash := ticket.TxIn[0].PreviousOutPoint.Hash
+	txs, _, err = wallet.internal.GetTransactionsByHashes(ctx, []*chainhash.Hash{&parentHash})
+	if err != nil {
+		return nil, nil, translateError(err)
+	}
+	if len(txs) == 0 {
+		return nil, nil, errors.New(ErrNotExist)
+	}
+
+	return ticket, txs[0], nil
+}
+
+// createVSPFeeTx creates and signs a transaction that pays `feeAmount` to
+// `feeAddress` from `account`. The transaction is not published, the VSP
+// broadcasts it once the ticket is confirmed. The inputs used are locked to
+// prevent them from being spent by another transaction in the meantime.
+func (wallet *Wallet) createVSPFeeTx(ctx context.Context, feeAddress string, feeAmount dcrutil.Amount, account uint32) (*wire.MsgTx, error) {
+	output, err := txhelper.MakeTxOutput(feeAddress, int64(feeAmount), wallet.chainParams)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vsp fee address: %v", err)
+	}
+
+	changeAddress, err := wallet.internal.NewChangeAddress(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("change address error: %v", err)
+	}
+
+	changeSource, err := txhelper.MakeTxChangeSource(changeAddress.String(), wallet.chainParams)
+	if err != nil {
+		return nil, fmt.Errorf("change source error: %v", err)
+	}
+
+	unsignedTx, err := wallet.internal.NewUnsignedTransaction(ctx, []*wire.TxOut{output}, txrules.DefaultRelayFeePerKb,
+		account, DefaultRequiredConfirmations, w.OutputSelectionAlgorithmDefault, changeSource)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if unsignedTx.ChangeIndex >= 0 {
+		unsignedTx.RandomizeChangePosition()
+	}
+
+	feeTx := unsignedTx.Tx
+	invalidSigs, err := wallet.internal.SignTransaction(ctx, feeTx, txscript.SigHashAll, nil, nil, nil)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if len(invalidSigs) > 0 {
+		return nil, errors.Errorf("failed to sign %d fee tx input(s)", len(invalidSigs))
+	}
+
+	for _, txIn := range feeTx.TxIn {
+		wallet.internal.LockOutpoint(txIn.PreviousOutPoint)
+	}
+
+	return feeTx, nil
+}
+
+func txHex(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,248 @@
+package vsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+	"github.com/decred/dcrwallet/errors/v2"
+)
+
+const (
+	apiPath = "/api/v3"
+
+	clientSignatureHeader = "VSP-Client-Signature"
+	serverSignatureHeader = "VSP-Server-Signature"
+
+	requestTimeout = 30 * time.Second
+)
+
+// Signer signs messages using the private key of the provided address.
+// It is satisfied by *wallet.Wallet.
+type Signer interface {
+	SignMessage(ctx context.Context, message string, address dcrutil.Address) ([]byte, error)
+}
+
+// Client is used to communicate with a VSP using version 3 of the vspd API.
+// Every request that references a ticket is signed with the ticket's
+// commitment address and every response is checked against the VSP's
+// ed25519 public key.
+type Client struct {
+	url        string
+	pubKey     ed25519.PublicKey
+	signer     Signer
+	httpClient *http.Client
+}
+
+// NewClient creates a Client for the VSP at `url`. Every response from the
+// VSP must be signed with `pubKey`, which should be a key that was confirmed
+// for the VSP rather than the key the VSP currently reports, see FetchPubKey.
+func NewClient(url string, pubKey []byte, signer Signer) (*Client, error) {
+	if url == "" || signer == nil {
+		return nil, errors.E(errors.Invalid, "vsp url and signer are required")
+	}
+	if len(pubKey) != ed25519.PublicKeySize {
+		return nil, errors.E(errors.Invalid, "invalid vsp public key")
+	}
+
+	return &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		pubKey:     pubKey,
+		signer:     signer,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// FetchPubKey returns the public key reported by the VSP at `url` after
+// checking that the vspinfo response is signed with it. The key is only
+// proven to belong to whoever answered the request, it must be confirmed,
+// for example by the user, before it is used to create a Client. If
+// `httpClient` is nil, the default client of NewClient is used.
+func FetchPubKey(ctx context.Context, httpClient *http.Client, url string) ([]byte, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: requestTimeout}
+	}
+	c := &Client{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: httpClient,
+	}
+
+	var info VspInfoResponse
+	body, signature, err := c.do(ctx, http.MethodGet, "/vspinfo", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("invalid vspinfo response: %v", err)
+	}
+
+	if len(info.PubKey) != ed25519.PublicKeySize {
+		return nil, errors.E(errors.Invalid, "vsp returned an invalid public key")
+	}
+	if !ed25519.Verify(info.PubKey, body, signature) {
+		return nil, errors.E(errors.Invalid, "invalid vsp server signature")
+	}
+
+	return info.PubKey, nil
+}
+
+// SetHTTPClient replaces the http client used to make requests to the VSP.
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// PubKey returns the public key used to verify responses from the VSP.
+func (c *Client) PubKey() []byte {
+	return c.pubKey
+}
+
+// VspInfo fetches the VSP's info. The response must be signed with the
+// public key the client was created with and must report the same key.
+func (c *Client) VspInfo(ctx context.Context) (*VspInfoResponse, error) {
+	var info VspInfoResponse
+	body, signature, err := c.do(ctx, http.MethodGet, "/vspinfo", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(c.pubKey, body, signature) {
+		return nil, errors.E(errors.Invalid, "invalid vsp server signature")
+	}
+
+	if err = json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("invalid vspinfo response: %v", err)
+	}
+
+	if !bytes.Equal(c.pubKey, info.PubKey) {
+		return nil, errors.E(errors.Invalid, "vsp public key does not match the expected key")
+	}
+
+	return &info, nil
+}
+
+// FeeAddress requests a fee address and the fee amount for the ticket.
+func (c *Client) FeeAddress(ctx context.Context, commitmentAddr dcrutil.Address, req *FeeAddressRequest) (*FeeAddressResponse, error) {
+	var resp FeeAddressResponse
+	requestBody, err := c.post(ctx, "/feeaddress", commitmentAddr, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(requestBody, resp.Request) {
+		return nil, errors.E(errors.Invalid, "vsp response does not match request")
+	}
+	return &resp, nil
+}
+
+// PayFee submits the signed fee transaction and the ticket's voting key to
+// the VSP.
+func (c *Client) PayFee(ctx context.Context, commitmentAddr dcrutil.Address, req *PayFeeRequest) (*PayFeeResponse, error) {
+	var resp PayFeeResponse
+	requestBody, err := c.post(ctx, "/payfee", commitmentAddr, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(requestBody, resp.Request) {
+		return nil, errors.E(errors.Invalid, "vsp response does not match request")
+	}
+	return &resp, nil
+}
+
+// TicketStatus queries the VSP for the status of the ticket and its fee.
+func (c *Client) TicketStatus(ctx context.Context, commitmentAddr dcrutil.Address, req *TicketStatusRequest) (*TicketStatusResponse, error) {
+	var resp TicketStatusResponse
+	requestBody, err := c.post(ctx, "/ticketstatus", commitmentAddr, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(requestBody, resp.Request) {
+		return nil, errors.E(errors.Invalid, "vsp response does not match request")
+	}
+	return &resp, nil
+}
+
+// post signs the json encoded `req` with `commitmentAddr` and sends it to the
+// VSP. The verified response is decoded into `resp` and the encoded request
+// is returned so callers can compare it against the request echoed by the VSP.
+func (c *Client) post(ctx context.Context, path string, commitmentAddr dcrutil.Address, req, resp interface{}) ([]byte, error) {
+	requestBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := c.signer.SignMessage(ctx, string(requestBody), commitmentAddr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign vsp request: %v", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type":        "application/json",
+		clientSignatureHeader: base64.StdEncoding.EncodeToString(signature),
+	}
+
+	body, serverSignature, err := c.do(ctx, http.MethodPost, path, requestBody, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(c.pubKey, body, serverSignature) {
+		return nil, errors.E(errors.Invalid, "invalid vsp server signature")
+	}
+
+	if err = json.Unmarshal(body, resp); err != nil {
+		return nil, fmt.Errorf("invalid vsp response: %v", err)
+	}
+
+	return requestBody, nil
+}
+
+// do sends the request to the VSP and returns the response body and the
+// decoded server signature. Non-200 responses are returned as errors.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, headers map[string]string) ([]byte, []byte, error) {
+	req, err := http.NewRequest(method, c.url+apiPath+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResponse ErrorResponse
+		if err = json.Unmarshal(respBody, &errorResponse); err == nil && errorResponse.Message != "" {
+			return nil, nil, &errorResponse
+		}
+		return nil, nil, fmt.Errorf("vsp responded with status %s", resp.Status)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(resp.Header.Get(serverSignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return nil, nil, errors.E(errors.Invalid, "vsp response is not signed")
+	}
+
+	return respBody, signature, nil
+}
@@ -0,0 +1,280 @@
+package vsp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decred/dcrd/dcrutil/v2"
+)
+
+var testClientSignature = []byte("commitment address signature")
+
+// testSigner records the messages it signs and returns a fixed signature.
+type testSigner struct {
+	messages []string
+}
+
+func (s *testSigner) SignMessage(ctx context.Context, message string, address dcrutil.Address) ([]byte, error) {
+	s.messages = append(s.messages, message)
+	return testClientSignature, nil
+}
+
+// testVSP is an in-process VSP that signs its responses with privKey.
+type testVSP struct {
+	t       *testing.T
+	privKey ed25519.PrivateKey
+	pubKey  ed25519.PublicKey
+
+	// signature, if set, is sent instead of the signature of the response.
+	signature string
+	// body, if set, is sent instead of the json encoded response.
+	body []byte
+}
+
+func newTestVSP(t *testing.T) (*testVSP, *httptest.Server) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vsp := &testVSP{t: t, privKey: privKey, pubKey: pubKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPath+"/vspinfo", func(w http.ResponseWriter, r *http.Request) {
+		vsp.respond(w, &VspInfoResponse{
+			APIVersions: []int64{3},
+			PubKey:      vsp.pubKey,
+			Network:     "testnet3",
+		})
+	})
+	mux.HandleFunc(apiPath+"/feeaddress", func(w http.ResponseWriter, r *http.Request) {
+		request := vsp.checkClientRequest(r)
+		vsp.respond(w, &FeeAddressResponse{
+			FeeAddress: "TsfDLrRkk9ciUuwfp2b8PawwnukYD7yAjGd",
+			FeeAmount:  10000,
+			Request:    request,
+		})
+	})
+	mux.HandleFunc(apiPath+"/ticketstatus", func(w http.ResponseWriter, r *http.Request) {
+		vsp.checkClientRequest(r)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&ErrorResponse{Code: 9, Message: "unknown ticket"})
+	})
+
+	server := httptest.NewServer(mux)
+	return vsp, server
+}
+
+// checkClientRequest checks that the request is signed by the test signer and
+// returns the request body.
+func (vsp *testVSP) checkClientRequest(r *http.Request) []byte {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		vsp.t.Errorf("error reading request: %v", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get(clientSignatureHeader))
+	if err != nil || string(signature) != string(testClientSignature) {
+		vsp.t.Errorf("request has invalid client signature %q", r.Header.Get(clientSignatureHeader))
+	}
+	return body
+}
+
+func (vsp *testVSP) respond(w http.ResponseWriter, resp interface{}) {
+	body := vsp.body
+	if body == nil {
+		var err error
+		body, err = json.Marshal(resp)
+		if err != nil {
+			vsp.t.Fatal(err)
+		}
+	}
+
+	signature := vsp.signature
+	if signature == "" {
+		signature = base64.StdEncoding.EncodeToString(ed25519.Sign(vsp.privKey, body))
+	}
+	if signature != "-" {
+		w.Header().Set(serverSignatureHeader, signature)
+	}
+	w.Write(body)
+}
+
+func TestClientValidSignature(t *testing.T) {
+	vsp, server := newTestVSP(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	pubKey, err := FetchPubKey(ctx, http.DefaultClient, server.URL)
+	if err != nil {
+		t.Fatalf("FetchPubKey: %v", err)
+	}
+	if string(pubKey) != string(vsp.pubKey) {
+		t.Fatal("FetchPubKey did not return the vsp public key")
+	}
+
+	signer := new(testSigner)
+	client, err := NewClient(server.URL, pubKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := client.VspInfo(ctx)
+	if err != nil {
+		t.Fatalf("VspInfo: %v", err)
+	}
+	if string(info.PubKey) != string(vsp.pubKey) {
+		t.Fatal("VspInfo did not return the vsp public key")
+	}
+
+	req := &FeeAddressRequest{TicketHash: "ticket hash", TicketHex: "ticket", ParentHex: "parent"}
+	resp, err := client.FeeAddress(ctx, nil, req)
+	if err != nil {
+		t.Fatalf("FeeAddress: %v", err)
+	}
+	if resp.FeeAmount != 10000 {
+		t.Fatalf("unexpected fee amount %d", resp.FeeAmount)
+	}
+
+	// the request must be signed by the commitment address.
+	requestBody, _ := json.Marshal(req)
+	if len(signer.messages) != 1 || signer.messages[0] != string(requestBody) {
+		t.Fatalf("signer signed %q, expected the request %q", signer.messages, requestBody)
+	}
+}
+
+func TestClientBadServerSignature(t *testing.T) {
+	vsp, server := newTestVSP(t)
+	defer server.Close()
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSignature := base64.StdEncoding.EncodeToString(ed25519.Sign(otherKey, []byte("other message")))
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"missing", "-"},
+		{"not base64", "not a signature!"},
+		{"wrong key", otherSignature},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+
+		// responses must be signed with the key the client was created with.
+		vsp.signature = test.signature
+		client, err := NewClient(server.URL, vsp.pubKey, new(testSigner))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = client.VspInfo(ctx); err == nil {
+			t.Errorf("%s signature: VspInfo accepted the response", test.name)
+		}
+		if _, err = client.FeeAddress(ctx, nil, &FeeAddressRequest{}); err == nil {
+			t.Errorf("%s signature: FeeAddress accepted the response", test.name)
+		}
+	}
+
+	// the vsp key must match the key the client was created with.
+	vsp.signature = ""
+	otherPubKey := otherKey.Public().(ed25519.PublicKey)
+	client, err := NewClient(server.URL, otherPubKey, new(testSigner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.VspInfo(context.Background()); err == nil {
+		t.Error("VspInfo accepted a response signed with an unexpected key")
+	}
+}
+
+func TestClientPinnedKey(t *testing.T) {
+	vsp, server := newTestVSP(t)
+	defer server.Close()
+
+	// a client can not be created without a key to check responses with.
+	if _, err := NewClient(server.URL, nil, new(testSigner)); err == nil {
+		t.Fatal("NewClient accepted a missing vsp public key")
+	}
+
+	ctx := context.Background()
+	client, err := NewClient(server.URL, vsp.pubKey, new(testSigner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.VspInfo(ctx); err != nil {
+		t.Fatalf("VspInfo: %v", err)
+	}
+
+	// the server switches to another key that it reports and signs its
+	// responses with. The responses are consistent but must be rejected as
+	// they are not signed with the pinned key.
+	vsp.pubKey, vsp.privKey, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubKey, err := FetchPubKey(ctx, http.DefaultClient, server.URL); err != nil || string(pubKey) != string(vsp.pubKey) {
+		t.Fatalf("FetchPubKey did not return the new key: %v", err)
+	}
+	if _, err = client.VspInfo(ctx); err == nil {
+		t.Error("VspInfo accepted a response signed with another key")
+	}
+	if _, err = client.FeeAddress(ctx, nil, &FeeAddressRequest{}); err == nil {
+		t.Error("FeeAddress accepted a response signed with another key")
+	}
+}
+
+func TestClientMalformedResponse(t *testing.T) {
+	vsp, server := newTestVSP(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := NewClient(server.URL, vsp.pubKey, new(testSigner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a correctly signed response that is not valid json must be rejected
+	// without panicking.
+	vsp.body = []byte(`{"pubkey": [1, 2`)
+	if _, err = client.VspInfo(ctx); err == nil {
+		t.Error("VspInfo accepted a malformed response")
+	}
+	if _, err = client.FeeAddress(ctx, nil, &FeeAddressRequest{}); err == nil {
+		t.Error("FeeAddress accepted a malformed response")
+	}
+
+	// the request echoed by the vsp must match the request sent.
+	vsp.body = []byte(`{"feeaddress": "address", "request": "cmVxdWVzdA=="}`)
+	if _, err = client.FeeAddress(ctx, nil, &FeeAddressRequest{}); err == nil {
+		t.Error("FeeAddress accepted a response for another request")
+	}
+}
+
+func TestClientErrorResponse(t *testing.T) {
+	vsp, server := newTestVSP(t)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, vsp.pubKey, new(testSigner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.TicketStatus(context.Background(), nil, &TicketStatusRequest{TicketHash: "ticket hash"})
+	errorResponse, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("expected an ErrorResponse, got %v", err)
+	}
+	if errorResponse.Code != 9 || errorResponse.Message != "unknown ticket" {
+		t.Fatalf("unexpected error response %+v", errorResponse)
+	}
+}
@@ -0,0 +1,57 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/asdine/storm"
+)
+
+// jsonConfig is an in-memory wallet config that encodes values as the
+// config database does.
+type jsonConfig map[string][]byte
+
+func (config jsonConfig) set(key string, value interface{}) (err error) {
+	config[key], err = json.Marshal(value)
+	return err
+}
+
+func (config jsonConfig) read(key string, valueOut interface{}) error {
+	value, ok := config[key]
+	if !ok {
+		return storm.ErrNotFound
+	}
+	return json.Unmarshal(value, valueOut)
+}
+
+func TestVSPPubKeyPinned(t *testing.T) {
+	config := make(jsonConfig)
+	wallet := &Wallet{ID: 1, setUserConfigValue: config.set, readUserConfigValue: config.read}
+	pubKey := bytes.Repeat([]byte{1}, 32)
+	otherPubKey := bytes.Repeat([]byte{2}, 32)
+
+	// a key is required until one is pinned for the vsp.
+	if _, err := wallet.vspPubKey("https://vsp.example.com", nil); err == nil || err.Error() != ErrVSPPubKeyRequired {
+		t.Fatalf("expected %s, got %v", ErrVSPPubKeyRequired, err)
+	}
+	if key, err := wallet.vspPubKey("https://vsp.example.com", otherPubKey); err != nil || !bytes.Equal(key, otherPubKey) {
+		t.Fatalf("provided key was not used: %v", err)
+	}
+
+	if err := wallet.pinVSPPubKey("https://vsp.example.com/", pubKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pinned key is used for the host regardless of the scheme and
+	// path, and other keys are rejected.
+	if key, err := wallet.vspPubKey("http://VSP.example.com", nil); err != nil || !bytes.Equal(key, pubKey) {
+		t.Fatalf("pinned key was not used: %v", err)
+	}
+	if _, err := wallet.vspPubKey("https://vsp.example.com", otherPubKey); err == nil || err.Error() != ErrVSPPubKeyMismatch {
+		t.Fatalf("expected %s, got %v", ErrVSPPubKeyMismatch, err)
+	}
+	if err := wallet.pinVSPPubKey("https://vsp.example.com", otherPubKey); err == nil || err.Error() != ErrVSPPubKeyMismatch {
+		t.Fatalf("pinned key was replaced: %v", err)
+	}
+}
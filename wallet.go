@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 
 	"github.com/decred/dcrd/chaincfg/v2"
 	"github.com/decred/dcrwallet/errors/v2"
@@ -19,20 +20,57 @@ type Wallet struct {
 	ID                    int    `storm:"id,increment"`
 	Name                  string `storm:"unique"`
 	DbDriver              string
-	Seed                  string
+	EncryptedSeed         []byte
 	IsRestored            bool
 	HasDiscoveredAccounts bool
 	PrivatePassphraseType int32
 
+	// BirthdayTimestamp and BirthdayHeight are set for restored wallets if
+	// the time the wallet was created is known. BirthdayHeight is set from
+	// BirthdayTimestamp once the block headers are synced.
+	BirthdayTimestamp int64
+	BirthdayHeight    int32
+
+	// Seed holds the plaintext seed of wallets created by older versions
+	// of this library. It is replaced with WrappedSeed, the seed encrypted
+	// with the device key, when the wallets database is opened and with
+	// EncryptedSeed the first time the wallet is unlocked.
+	// Seed, WrappedSeed and EncryptedSeed are protected by seedMu.
+	Seed        string
+	WrappedSeed []byte
+
+	seedMu        sync.Mutex
+	seedDeviceKey *[seedKeySize]byte
+
 	internal    *w.Wallet
 	chainParams *chaincfg.Params
 	dataDir     string
 	loader      *loader.Loader
 	txDB        *txindex.DB
 
-	synced  bool
-	syncing bool
-	waiting bool
+	// synced, syncing and waiting are the sync state of the wallet and are
+	// protected by syncStateMu.
+	syncStateMu sync.RWMutex
+	synced      bool
+	syncing     bool
+	waiting     bool
+
+	// syncPaused is set for wallets paused with MultiWallet.PauseWalletSync
+	// and is saved in the wallet's config. syncSkipped is set for wallets
+	// left out of the current sync by MultiWallet.SpvSyncWallets. Both are
+	// protected by syncPausedMu.
+	syncPausedMu sync.RWMutex
+	syncPaused   bool
+	syncSkipped  bool
+
+	ticketBuyer  *ticketBuyerData
+	vspTicketsMu sync.Mutex
+	vspPubKeysMu sync.Mutex
+
+	multisigAddressesMu sync.Mutex
+
+	fiatRateSource        FiatRateSource
+	sweepProgressListener SweepProgressListener
 
 	shuttingDown chan bool
 	cancelFuncs  []context.CancelFunc
@@ -47,27 +85,51 @@ type Wallet struct {
 	// This function is ideally assigned when the `wallet.prepare` method is
 	// called from a MultiWallet instance.
 	readUserConfigValue configReadFn
+
+	// httpClient returns the http client used for requests made by the
+	// wallet, e.g. to VSPs, so that the requests use the proxy config of
+	// the MultiWallet.
+	httpClient httpClientFn
+
+	// save saves changes to the wallet's record in the wallets database.
+	save walletSaveFn
+
+	// selectedFeeRate returns the fee rate in atoms/kB of the fee rate
+	// preset selected in the MultiWallet config.
+	selectedFeeRate feeRateFn
 }
 
 // prepare gets a wallet ready for use by opening the transactions index database
 // and initializing the wallet loader which can be used subsequently to create,
 // load and unload the wallet.
 func (wallet *Wallet) prepare(rootDir string, chainParams *chaincfg.Params,
-	setUserConfigValueFn configSaveFn, readUserConfigValueFn configReadFn) (err error) {
+	setUserConfigValueFn configSaveFn, readUserConfigValueFn configReadFn, httpClientFn httpClientFn, saveFn walletSaveFn,
+	selectedFeeRateFn feeRateFn) (err error) {
 
 	wallet.chainParams = chainParams
 	wallet.dataDir = filepath.Join(rootDir, strconv.Itoa(wallet.ID))
 	wallet.setUserConfigValue = setUserConfigValueFn
 	wallet.readUserConfigValue = readUserConfigValueFn
+	wallet.httpClient = httpClientFn
+	wallet.save = saveFn
+	wallet.selectedFeeRate = selectedFeeRateFn
+
+	wallet.syncPaused = wallet.ReadBoolConfigValueForKey(SyncPausedConfigKey, false)
 
 	// open database for indexing transactions for faster loading
 	txDBPath := filepath.Join(wallet.dataDir, txindex.DbName)
-	wallet.txDB, err = txindex.Initialize(txDBPath, &Transaction{})
+	wallet.txDB, err = txindex.Initialize(txDBPath, &Transaction{}, func(version uint32, description string, done, total int) {
+		log.Infof("[%d] Upgrading tx index to version %d (%s): %d/%d", wallet.ID, version, description, done, total)
+	})
 	if err != nil {
 		log.Error(err.Error())
 		return err
 	}
 
+	wallet.ticketBuyer = &ticketBuyerData{
+		listeners: make(map[string]TicketBuyerNotificationListener),
+	}
+
 	// init loader
 	wallet.loader = initWalletLoader(wallet.chainParams, wallet.dataDir, wallet.DbDriver)
 
@@ -202,6 +264,7 @@ func (wallet *Wallet) UnlockWallet(privPass []byte) error {
 		return translateError(err)
 	}
 
+	wallet.migratePlaintextSeed(privPass)
 	return nil
 }
 
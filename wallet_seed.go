@@ -0,0 +1,385 @@
+package dcrlibwallet
+
+import (
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/decred/dcrd/hdkeychain/v2"
+	"github.com/decred/dcrwallet/errors/v2"
+	"github.com/decred/dcrwallet/walletseed"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	seedSaltSize  = 32
+	seedKeySize   = 32
+	seedNonceSize = 24
+
+	// scrypt parameters used to derive the seed encryption key from the
+	// private passphrase. Kept moderate so that decryption remains fast
+	// enough on mobile devices.
+	seedScryptN = 1 << 15
+	seedScryptR = 8
+	seedScryptP = 1
+
+	// seedDeviceKeyFile is the file in the root directory that holds the
+	// key used to encrypt plaintext seeds when the wallets database is
+	// opened.
+	seedDeviceKeyFile = "seed.key"
+)
+
+// DecryptSeed returns the seed of a newly created wallet that has not been
+// verified yet. The seed is decrypted using the wallet's private passphrase.
+// ErrNotExist is returned if the seed was already verified and removed.
+func (wallet *Wallet) DecryptSeed(privPass []byte) (string, error) {
+	defer func() {
+		for i := range privPass {
+			privPass[i] = 0
+		}
+	}()
+
+	wallet.seedMu.Lock()
+	encryptedSeed := wallet.EncryptedSeed
+	legacySeed, err := wallet.legacySeed()
+	wallet.seedMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if encryptedSeed == nil {
+		if legacySeed == "" {
+			return "", errors.New(ErrNotExist)
+		}
+
+		// seed saved before seeds were encrypted with the private
+		// passphrase, use the passphrase to unlock the wallet so that the
+		// seed is not returned to whoever asks for it.
+		if err := wallet.verifyPrivatePassphrase(privPass); err != nil {
+			return "", err
+		}
+		return legacySeed, nil
+	}
+
+	return decryptWalletSeed(privPass, encryptedSeed)
+}
+
+// needsSeedBackup returns true if the seed for this wallet was generated by
+// dcrlibwallet and has not been verified by the user yet.
+func (wallet *Wallet) needsSeedBackup() bool {
+	wallet.seedMu.Lock()
+	defer wallet.seedMu.Unlock()
+	return wallet.EncryptedSeed != nil || wallet.Seed != "" || wallet.WrappedSeed != nil
+}
+
+// SeedNeedsPassphrase returns true if the unverified seed of this wallet was
+// saved by an older version of this library and is only encrypted with the
+// device key. Apps should ask for the private passphrase and call
+// UnlockWallet, which encrypts the seed with the passphrase.
+func (wallet *Wallet) SeedNeedsPassphrase() bool {
+	wallet.seedMu.Lock()
+	defer wallet.seedMu.Unlock()
+	return wallet.Seed != "" || wallet.WrappedSeed != nil
+}
+
+// verifyPrivatePassphrase checks that privPass can unlock the wallet. The
+// wallet is left in the lock state it was in before this method was called.
+func (wallet *Wallet) verifyPrivatePassphrase(privPass []byte) error {
+	if wallet.internal == nil {
+		return errors.New(ErrWalletNotLoaded)
+	}
+
+	wasLocked := wallet.internal.Locked()
+	err := wallet.internal.Unlock(wallet.shutdownContext(), privPass, nil)
+	if err != nil {
+		return translateError(err)
+	}
+
+	if wasLocked {
+		wallet.internal.Lock()
+	}
+	return nil
+}
+
+// legacySeed returns the seed saved by an older version of this library,
+// decrypting it with the device key if it was wrapped when the wallets
+// database was opened. An empty string is returned if the wallet has no such
+// seed. Must be called with wallet.seedMu held.
+func (wallet *Wallet) legacySeed() (string, error) {
+	if wallet.Seed != "" {
+		return wallet.Seed, nil
+	}
+	if wallet.WrappedSeed == nil {
+		return "", nil
+	}
+	if wallet.seedDeviceKey == nil {
+		return "", errors.New(ErrFailedPrecondition)
+	}
+	return unwrapWalletSeed(wallet.seedDeviceKey, wallet.WrappedSeed)
+}
+
+// wrapPlaintextSeed replaces a plaintext seed saved by an older version of
+// this library with the same seed encrypted with the device key and saves the
+// wallet. This is done when the wallets database is opened, as the private
+// passphrase needed to encrypt the seed properly is not known then.
+// The device key is saved next to the wallets database, so this only keeps
+// the seed out of copies of the database, such as backups, that do not
+// include the key. Pages of the database that held the plaintext seed may
+// also keep it until they are reused.
+func (wallet *Wallet) wrapPlaintextSeed() error {
+	wallet.seedMu.Lock()
+	defer wallet.seedMu.Unlock()
+
+	if wallet.Seed == "" {
+		return nil
+	}
+
+	wrappedSeed, err := wrapWalletSeed(wallet.seedDeviceKey, wallet.Seed)
+	if err != nil {
+		return err
+	}
+
+	seed := wallet.Seed
+	wallet.WrappedSeed = wrappedSeed
+	wallet.Seed = ""
+	if err = wallet.save(); err != nil {
+		wallet.Seed = seed
+		wallet.WrappedSeed = nil
+		return err
+	}
+
+	return nil
+}
+
+// encryptPlaintextSeed replaces a seed saved by an older version of this
+// library with the same seed encrypted using privPass. Returns true if the
+// wallet was modified and needs to be saved. Must be called with
+// wallet.seedMu held.
+func (wallet *Wallet) encryptPlaintextSeed(privPass []byte) (bool, error) {
+	seed, err := wallet.legacySeed()
+	if err != nil || seed == "" {
+		return false, err
+	}
+
+	encryptedSeed, err := encryptWalletSeed(privPass, seed)
+	if err != nil {
+		return false, err
+	}
+
+	wallet.EncryptedSeed = encryptedSeed
+	wallet.Seed = ""
+	wallet.WrappedSeed = nil
+	return true, nil
+}
+
+// migratePlaintextSeed encrypts a seed saved by an older version of this
+// library with privPass and saves the wallet. It is called by UnlockWallet
+// once the passphrase is verified. Errors are logged rather than returned so
+// that they don't fail the unlock, the migration is retried the next time the
+// wallet is unlocked.
+func (wallet *Wallet) migratePlaintextSeed(privPass []byte) {
+	wallet.seedMu.Lock()
+	defer wallet.seedMu.Unlock()
+
+	seed, wrappedSeed := wallet.Seed, wallet.WrappedSeed
+	updated, err := wallet.encryptPlaintextSeed(privPass)
+	if err != nil || !updated {
+		if err != nil {
+			log.Errorf("[%d] Error encrypting wallet seed: %v", wallet.ID, err)
+		}
+		return
+	}
+
+	if err = wallet.save(); err != nil {
+		log.Errorf("[%d] Error saving encrypted wallet seed: %v", wallet.ID, err)
+		wallet.Seed = seed
+		wallet.WrappedSeed = wrappedSeed
+		wallet.EncryptedSeed = nil
+		return
+	}
+
+	log.Infof("[%d] Encrypted wallet seed", wallet.ID)
+}
+
+// seedMatches checks if seedMnemonic is the seed this wallet was created
+// from by comparing the account 0 extended public key derived from the
+// seed with the one stored in the wallet.
+func (wallet *Wallet) seedMatches(seedMnemonic string) (bool, error) {
+	if wallet.internal == nil {
+		return false, errors.New(ErrWalletNotLoaded)
+	}
+
+	seed, err := walletseed.DecodeUserInput(seedMnemonic)
+	if err != nil {
+		return false, nil
+	}
+	defer func() {
+		for i := range seed {
+			seed[i] = 0
+		}
+	}()
+
+	ctx := wallet.shutdownContext()
+	coinType, err := wallet.internal.CoinType(ctx)
+	if err != nil {
+		return false, translateError(err)
+	}
+
+	walletXPub, err := wallet.internal.MasterPubKey(ctx, 0)
+	if err != nil {
+		return false, translateError(err)
+	}
+
+	key, err := hdkeychain.NewMaster(seed, wallet.chainParams)
+	if err != nil {
+		return false, nil
+	}
+
+	// m/44'/<coin type>'/0'
+	for _, index := range []uint32{44, coinType, 0} {
+		key, err = key.Child(hdkeychain.HardenedKeyStart + index)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	seedXPub, err := key.Neuter()
+	if err != nil {
+		return false, err
+	}
+
+	return seedXPub.String() == walletXPub.String(), nil
+}
+
+// encryptWalletSeed encrypts seed using a key derived from privPass with
+// scrypt. The returned bytes contain the salt and nonce used followed by the
+// encrypted seed.
+func encryptWalletSeed(privPass []byte, seed string) ([]byte, error) {
+	salt := make([]byte, seedSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	var nonce [seedNonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+
+	key, err := deriveSeedKey(privPass, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroSeedKey(key)
+
+	encryptedSeed := make([]byte, 0, seedSaltSize+seedNonceSize+len(seed)+secretbox.Overhead)
+	encryptedSeed = append(encryptedSeed, salt...)
+	encryptedSeed = append(encryptedSeed, nonce[:]...)
+	return secretbox.Seal(encryptedSeed, []byte(seed), &nonce, key), nil
+}
+
+// decryptWalletSeed reverses encryptWalletSeed. ErrInvalidPassphrase is
+// returned if privPass is not the passphrase the seed was encrypted with.
+func decryptWalletSeed(privPass []byte, encryptedSeed []byte) (string, error) {
+	if len(encryptedSeed) < seedSaltSize+seedNonceSize+secretbox.Overhead {
+		return "", errors.New(ErrInvalid)
+	}
+
+	salt := encryptedSeed[:seedSaltSize]
+	var nonce [seedNonceSize]byte
+	copy(nonce[:], encryptedSeed[seedSaltSize:seedSaltSize+seedNonceSize])
+
+	key, err := deriveSeedKey(privPass, salt)
+	if err != nil {
+		return "", err
+	}
+	defer zeroSeedKey(key)
+
+	seed, ok := secretbox.Open(nil, encryptedSeed[seedSaltSize+seedNonceSize:], &nonce, key)
+	if !ok {
+		return "", errors.New(ErrInvalidPassphrase)
+	}
+
+	return string(seed), nil
+}
+
+// loadSeedDeviceKey returns the device key saved in rootDir, creating the
+// key if it does not exist.
+func loadSeedDeviceKey(rootDir string) (*[seedKeySize]byte, error) {
+	keyPath := filepath.Join(rootDir, seedDeviceKeyFile)
+	keyBytes, err := ioutil.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		keyBytes = make([]byte, seedKeySize)
+		if _, err = io.ReadFull(rand.Reader, keyBytes); err != nil {
+			return nil, err
+		}
+		err = ioutil.WriteFile(keyPath, keyBytes, 0600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keyBytes) != seedKeySize {
+		return nil, errors.New(ErrInvalid)
+	}
+
+	var key [seedKeySize]byte
+	copy(key[:], keyBytes)
+	for i := range keyBytes {
+		keyBytes[i] = 0
+	}
+
+	return &key, nil
+}
+
+// wrapWalletSeed encrypts seed with the device key. The returned bytes
+// contain the nonce used followed by the encrypted seed.
+func wrapWalletSeed(key *[seedKeySize]byte, seed string) ([]byte, error) {
+	var nonce [seedNonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+
+	wrappedSeed := make([]byte, 0, seedNonceSize+len(seed)+secretbox.Overhead)
+	wrappedSeed = append(wrappedSeed, nonce[:]...)
+	return secretbox.Seal(wrappedSeed, []byte(seed), &nonce, key), nil
+}
+
+// unwrapWalletSeed reverses wrapWalletSeed.
+func unwrapWalletSeed(key *[seedKeySize]byte, wrappedSeed []byte) (string, error) {
+	if len(wrappedSeed) < seedNonceSize+secretbox.Overhead {
+		return "", errors.New(ErrInvalid)
+	}
+
+	var nonce [seedNonceSize]byte
+	copy(nonce[:], wrappedSeed[:seedNonceSize])
+
+	seed, ok := secretbox.Open(nil, wrappedSeed[seedNonceSize:], &nonce, key)
+	if !ok {
+		return "", errors.New(ErrInvalid)
+	}
+
+	return string(seed), nil
+}
+
+func deriveSeedKey(privPass, salt []byte) (*[seedKeySize]byte, error) {
+	derivedKey, err := scrypt.Key(privPass, salt, seedScryptN, seedScryptR, seedScryptP, seedKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	var key [seedKeySize]byte
+	copy(key[:], derivedKey)
+	for i := range derivedKey {
+		derivedKey[i] = 0
+	}
+
+	return &key, nil
+}
+
+func zeroSeedKey(key *[seedKeySize]byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
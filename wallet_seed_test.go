@@ -0,0 +1,138 @@
+package dcrlibwallet
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const testSeed = "reform aftermath printer warranty gremlin paragraph beehive stethoscope regain disruptive regain Bradbury chisel October trouble forever Algol applicant island infancy physique paragraph woodlark hydraulic snapshot backwater ratchet surrender revenge customer retouch intention minnow"
+
+func TestSeedEncryption(t *testing.T) {
+	encryptedSeed, err := encryptWalletSeed([]byte("passphrase"), testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, err := decryptWalletSeed([]byte("passphrase"), encryptedSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != testSeed {
+		t.Fatalf("decrypted seed %q, expected %q", seed, testSeed)
+	}
+
+	_, err = decryptWalletSeed([]byte("other passphrase"), encryptedSeed)
+	if err == nil || err.Error() != ErrInvalidPassphrase {
+		t.Fatalf("expected %s decrypting with the wrong passphrase, got %v", ErrInvalidPassphrase, err)
+	}
+
+	_, err = decryptWalletSeed([]byte("passphrase"), encryptedSeed[:seedSaltSize])
+	if err == nil || err.Error() != ErrInvalid {
+		t.Fatalf("expected %s decrypting a truncated seed, got %v", ErrInvalid, err)
+	}
+}
+
+func TestMigratePlaintextSeed(t *testing.T) {
+	var saves int
+	wallet := &Wallet{
+		ID:   1,
+		Seed: testSeed,
+		save: func() error {
+			saves++
+			return nil
+		},
+	}
+
+	wallet.migratePlaintextSeed([]byte("passphrase"))
+	if wallet.Seed != "" {
+		t.Fatal("plaintext seed was not removed")
+	}
+	if saves != 1 {
+		t.Fatalf("wallet saved %d times, expected 1", saves)
+	}
+
+	seed, err := wallet.DecryptSeed([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != testSeed {
+		t.Fatalf("decrypted seed %q, expected %q", seed, testSeed)
+	}
+
+	// an encrypted seed is not migrated again.
+	wallet.migratePlaintextSeed([]byte("passphrase"))
+	if saves != 1 {
+		t.Fatalf("wallet saved %d times, expected 1", saves)
+	}
+}
+
+func TestMigratePlaintextSeedSaveError(t *testing.T) {
+	wallet := &Wallet{
+		ID:   1,
+		Seed: testSeed,
+		save: func() error {
+			return errors.New("save failed")
+		},
+	}
+
+	// the plaintext seed must be kept if the encrypted seed could not be
+	// saved so that the wallet matches its saved record.
+	wallet.migratePlaintextSeed([]byte("passphrase"))
+	if wallet.Seed != testSeed || wallet.EncryptedSeed != nil {
+		t.Fatal("wallet seed changed although the wallet was not saved")
+	}
+}
+
+func TestWrapPlaintextSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dcrlibwallet-seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	deviceKey, err := loadSeedDeviceKey(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloadedKey, err := loadSeedDeviceKey(dir); err != nil || *reloadedKey != *deviceKey {
+		t.Fatalf("device key changed when it was loaded again: %v", err)
+	}
+
+	var saves int
+	wallet := &Wallet{
+		ID:            1,
+		Seed:          testSeed,
+		seedDeviceKey: deviceKey,
+		save: func() error {
+			saves++
+			return nil
+		},
+	}
+
+	// the seed is not saved in plaintext once the database is opened.
+	if err = wallet.wrapPlaintextSeed(); err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Seed != "" || bytes.Contains(wallet.WrappedSeed, []byte(testSeed)) || saves != 1 {
+		t.Fatal("plaintext seed was not wrapped and saved")
+	}
+	if !wallet.needsSeedBackup() || !wallet.SeedNeedsPassphrase() {
+		t.Fatal("wrapped seed is not reported")
+	}
+
+	wallet.migratePlaintextSeed([]byte("passphrase"))
+	if wallet.WrappedSeed != nil || wallet.SeedNeedsPassphrase() || saves != 2 {
+		t.Fatal("wrapped seed was not migrated")
+	}
+
+	seed, err := wallet.DecryptSeed([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != testSeed {
+		t.Fatalf("decrypted seed %q, expected %q", seed, testSeed)
+	}
+}